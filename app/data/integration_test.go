@@ -43,7 +43,6 @@ var resource *dockertest.Resource
 var pool *dockertest.Pool
 
 func TestMain(m *testing.M) {
-	os.Setenv("DATABASE_TYPE", "postgres")
 	os.Setenv("UPPER_DB_LOG", "ERROR")
 
 	p, err := dockertest.NewPool("")
@@ -92,7 +91,7 @@ func TestMain(m *testing.M) {
 		log.Fatalf("error creating tables: %s", err)
 	}
 
-	models = New(testDB)
+	models = New(testDB, "postgres")
 
 	code := m.Run()
 
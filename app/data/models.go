@@ -3,7 +3,6 @@ package data
 import (
 	"database/sql"
 	"fmt"
-	"os"
 
 	db2 "github.com/upper/db/v4"
 	"github.com/upper/db/v4/adapter/mysql"
@@ -19,12 +18,13 @@ type Models struct {
 	Users         User
 	Tokens        Token
 	RememberToken RememberToken
+	Roles         Role
 }
 
-func New(dbPool *sql.DB) Models {
+func New(dbPool *sql.DB, dbType string) Models {
 	db = dbPool
 
-	switch os.Getenv("DATABASE_TYPE") {
+	switch dbType {
 	case "mysql", "mariadb":
 		upper, _ = mysql.New(dbPool)
 	case "postgres", "postgresql":
@@ -37,6 +37,7 @@ func New(dbPool *sql.DB) Models {
 		Users:         User{},
 		Tokens:        Token{},
 		RememberToken: RememberToken{},
+		Roles:         Role{},
 	}
 }
 
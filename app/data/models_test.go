@@ -2,7 +2,6 @@ package data
 
 import (
 	"fmt"
-	"os"
 	"testing"
 
 	db2 "github.com/upper/db/v4"
@@ -14,14 +13,12 @@ func TestNew(t *testing.T) {
 	fakeDB, _, _ := sqlmock.New()
 	defer fakeDB.Close()
 
-	_ = os.Setenv("DATABASE_TYPE", "postgres")
-	m := New(fakeDB)
+	m := New(fakeDB, "postgres")
 	if fmt.Sprintf("%T", m) != "data.Models" {
 		t.Error("wrong type", fmt.Sprintf("%T", m))
 	}
 
-	_ = os.Setenv("DATABASE_TYPE", "mysql")
-	m = New(fakeDB)
+	m = New(fakeDB, "mysql")
 	if fmt.Sprintf("%T", m) != "data.Models" {
 		t.Error("wrong type", fmt.Sprintf("%T", m))
 	}
@@ -42,3 +42,11 @@ func (t *RememberToken) Delete(rToken string) error {
 	}
 	return nil
 }
+
+// DeleteForUser removes every remember token of the user with userID, so no browser can sign them
+// in again without their password
+func (t *RememberToken) DeleteForUser(userID int) error {
+	collection := upper.Collection(t.Table())
+	res := collection.Find(up.Cond{"user_id": userID})
+	return res.Delete()
+}
@@ -0,0 +1,90 @@
+package data
+
+import (
+	"time"
+
+	up "github.com/upper/db/v4"
+)
+
+// Role is a named set of permissions given to users, such as admin or editor
+type Role struct {
+	ID        int       `db:"id,omitempty"`
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// Permission lets the users of a role do something, such as posts.edit
+type Permission struct {
+	ID        int       `db:"id,omitempty"`
+	RoleID    int       `db:"role_id"`
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func (r *Role) Table() string {
+	return "roles"
+}
+
+func (r *Role) GetByName(name string) (*Role, error) {
+	var role Role
+	collection := upper.Collection(r.Table())
+	res := collection.Find(up.Cond{"name =": name})
+	err := res.One(&role)
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *Role) Insert(role Role) (int, error) {
+	role.CreatedAt = time.Now()
+	role.UpdatedAt = time.Now()
+
+	collection := upper.Collection(r.Table())
+	res, err := collection.Insert(role)
+	if err != nil {
+		return 0, err
+	}
+	return getInsertID(res.ID()), nil
+}
+
+func (r *Role) Delete(id int) error {
+	collection := upper.Collection(r.Table())
+	res := collection.Find(id)
+	return res.Delete()
+}
+
+// Grant gives the users of the role with roleID the permissions
+func (r *Role) Grant(roleID int, permissions ...string) error {
+	collection := upper.Collection("permissions")
+	for _, name := range permissions {
+		_, err := collection.Insert(Permission{
+			RoleID:    roleID,
+			Name:      name,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Assign gives the user with userID the role with roleID
+func (r *Role) Assign(roleID, userID int) error {
+	_, err := upper.SQL().InsertInto("role_user").
+		Columns("role_id", "user_id", "created_at").
+		Values(roleID, userID, time.Now()).
+		Exec()
+	return err
+}
+
+// Unassign takes the role with roleID from the user with userID
+func (r *Role) Unassign(roleID, userID int) error {
+	collection := upper.Collection("role_user")
+	res := collection.Find(up.Cond{"role_id": roleID, "user_id": userID})
+	return res.Delete()
+}
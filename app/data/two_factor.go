@@ -0,0 +1,159 @@
+package data
+
+import (
+	"time"
+
+	"github.com/wtran29/fenix/fenix/auth"
+
+	up "github.com/upper/db/v4"
+)
+
+// RecoveryCodeCount is how many recovery codes a user gets when they turn on two-factor authentication
+const RecoveryCodeCount = 10
+
+// RecoveryCode is a one-time code that lets a user with two-factor authentication log in without their
+// authenticator. Only the hash of the code is stored.
+type RecoveryCode struct {
+	ID        int       `db:"id,omitempty"`
+	UserID    int       `db:"user_id"`
+	CodeHash  string    `db:"code_hash"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (c *RecoveryCode) Table() string {
+	return "recovery_codes"
+}
+
+// HasTwoFactor reports whether the user must enter a code from their authenticator to log in
+func (u *User) HasTwoFactor() bool {
+	return u.TOTPEnabled == 1 && u.TOTPSecret != ""
+}
+
+// EnableTwoFactor turns on two-factor authentication with secret, once the user entered the code of
+// step for it, and returns their new recovery codes. They are not stored anywhere, so show them once.
+func (u *User) EnableTwoFactor(secret string, step int64) ([]string, error) {
+	_, err := upper.SQL().
+		Update(u.Table()).
+		Set("totp_secret", secret, "totp_enabled", 1, "totp_last_step", step, "updated_at", time.Now()).
+		Where("id = ?", u.ID).
+		Exec()
+	if err != nil {
+		return nil, err
+	}
+
+	u.TOTPSecret = secret
+	u.TOTPEnabled = 1
+	u.TOTPLastStep = step
+
+	return u.NewRecoveryCodes()
+}
+
+// DisableTwoFactor turns off two-factor authentication and deletes the user's recovery codes
+func (u *User) DisableTwoFactor() error {
+	err := upper.Tx(func(sess up.Session) error {
+		_, err := sess.SQL().
+			Update(u.Table()).
+			Set("totp_secret", "", "totp_enabled", 0, "totp_last_step", 0, "updated_at", time.Now()).
+			Where("id = ?", u.ID).
+			Exec()
+		if err != nil {
+			return err
+		}
+
+		var code RecoveryCode
+		return sess.Collection(code.Table()).Find(up.Cond{"user_id": u.ID}).Delete()
+	})
+	if err != nil {
+		return err
+	}
+
+	u.TOTPSecret = ""
+	u.TOTPEnabled = 0
+	u.TOTPLastStep = 0
+	return nil
+}
+
+// NewRecoveryCodes replaces the user's recovery codes with new ones, and returns them
+func (u *User) NewRecoveryCodes() ([]string, error) {
+	codes, err := auth.NewRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	err = upper.Tx(func(sess up.Session) error {
+		var code RecoveryCode
+		collection := sess.Collection(code.Table())
+
+		err := collection.Find(up.Cond{"user_id": u.ID}).Delete()
+		if err != nil {
+			return err
+		}
+
+		for _, c := range codes {
+			_, err = collection.Insert(RecoveryCode{
+				UserID:    u.ID,
+				CodeHash:  auth.HashRecoveryCode(c),
+				CreatedAt: time.Now(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// RecoveryCodesLeft returns how many unused recovery codes the user has
+func (u *User) RecoveryCodesLeft() (int, error) {
+	var code RecoveryCode
+	n, err := upper.Collection(code.Table()).Find(up.Cond{"user_id": u.ID}).Count()
+	return int(n), err
+}
+
+// VerifyTwoFactor checks code, from the user's authenticator or one of their recovery codes. Either
+// can be used only once.
+func (u *User) VerifyTwoFactor(code string) (bool, error) {
+	if !u.HasTwoFactor() {
+		return false, nil
+	}
+
+	if step, ok := auth.VerifyTOTP(u.TOTPSecret, code, time.Now()); ok {
+		// only move the last step forward, so the same code cannot log in twice even at once
+		res, err := upper.SQL().
+			Update(u.Table()).
+			Set("totp_last_step", step).
+			Where("id = ? and totp_last_step < ?", u.ID, step).
+			Exec()
+		if err != nil {
+			return false, err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil || n == 0 {
+			return false, err
+		}
+
+		u.TOTPLastStep = step
+		return true, nil
+	}
+
+	var rc RecoveryCode
+	res, err := upper.SQL().
+		DeleteFrom(rc.Table()).
+		Where("user_id = ? and code_hash = ?", u.ID, auth.HashRecoveryCode(code)).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
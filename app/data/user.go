@@ -2,6 +2,7 @@ package data
 
 import (
 	"errors"
+	"strconv"
 	"time"
 
 	"github.com/wtran29/fenix/fenix"
@@ -20,6 +21,14 @@ type User struct {
 	CreatedAt time.Time `db:"created_at"`
 	UpdatedAt time.Time `db:"updated_at"`
 	Token     Token     `db:"-"`
+	// TOTPSecret is the user's authenticator secret, used once TOTPEnabled is 1. TOTPLastStep is the
+	// time step of the last code they used, so that no code works twice.
+	TOTPSecret   string `db:"totp_secret"`
+	TOTPEnabled  int    `db:"totp_enabled"`
+	TOTPLastStep int64  `db:"totp_last_step"`
+	// roles and permissions are loaded by Roles and Permissions, once per User
+	roles       []string
+	permissions []string
 }
 
 // Returns the table name associated to the model of db
@@ -181,3 +190,122 @@ func (u *User) CheckRememberToken(id int, token string) bool {
 	err := res.One(&rToken)
 	return err == nil
 }
+
+// UserByID, UserByToken, UserByRememberToken and UserByCredentials make User a fenix auth.UserProvider
+
+func (u *User) UserByID(id string) (interface{}, error) {
+	userID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+	return u.Get(userID)
+}
+
+func (u *User) UserByToken(token string) (interface{}, error) {
+	// the GenerateToken() hash uses base64 encoding with random bytes of 16
+	if len(token) != 22 {
+		return nil, errors.New("token wrong size")
+	}
+
+	var t Token
+	user, err := t.GetUserByToken(token)
+	if err != nil {
+		return nil, errors.New("no matching user found")
+	}
+	if user.Token.Expires.Before(time.Now()) {
+		return nil, errors.New("expired token")
+	}
+	return user, nil
+}
+
+func (u *User) UserByRememberToken(id, token string) (interface{}, error) {
+	userID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+	if !u.CheckRememberToken(userID, token) {
+		return nil, errors.New("no matching remember token found")
+	}
+	return u.Get(userID)
+}
+
+func (u *User) UserByCredentials(email, password string) (interface{}, error) {
+	user, err := u.GetByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	match, err := user.IsPasswordMatch(password)
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		return nil, errors.New("wrong password")
+	}
+	return user, nil
+}
+
+// AuthID makes User a fenix auth.Identifiable, so API rate limits can count requests per user
+func (u *User) AuthID() string {
+	return strconv.Itoa(u.ID)
+}
+
+// ForgetRememberTokens makes User a fenix auth.RememberForgetter, so revoking the sessions of the
+// user with id also stops their remember-me cookies from signing them back in
+func (u *User) ForgetRememberTokens(id string) error {
+	userID, err := strconv.Atoi(id)
+	if err != nil {
+		return err
+	}
+
+	var t RememberToken
+	return t.DeleteForUser(userID)
+}
+
+// Roles and Permissions make User a fenix auth.Authorizable
+
+func (u *User) Roles() ([]string, error) {
+	if u.roles != nil {
+		return u.roles, nil
+	}
+
+	var rows []struct {
+		Name string `db:"name"`
+	}
+	err := upper.SQL().Select("r.name").From("roles r").
+		Join("role_user ru").On("ru.role_id = r.id").
+		Where("ru.user_id = ?", u.ID).
+		All(&rows)
+	if err != nil {
+		return nil, err
+	}
+
+	u.roles = make([]string, 0, len(rows))
+	for _, row := range rows {
+		u.roles = append(u.roles, row.Name)
+	}
+	return u.roles, nil
+}
+
+func (u *User) Permissions() ([]string, error) {
+	if u.permissions != nil {
+		return u.permissions, nil
+	}
+
+	var rows []struct {
+		Name string `db:"name"`
+	}
+	err := upper.SQL().Select("p.name").Distinct().From("permissions p").
+		Join("role_user ru").On("ru.role_id = p.role_id").
+		Where("ru.user_id = ?", u.ID).
+		All(&rows)
+	if err != nil {
+		return nil, err
+	}
+
+	u.permissions = make([]string, 0, len(rows))
+	for _, row := range rows {
+		u.permissions = append(u.permissions, row.Name)
+	}
+	return u.permissions, nil
+}
@@ -6,10 +6,9 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"log"
 	"myapp/data"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -63,23 +62,46 @@ func (h *Handlers) PostUserLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// check remember me?
-	if r.Form.Get("remember") == "remember" {
+	remember := r.Form.Get("remember") == "remember"
+
+	// users with two-factor authentication prove it is them before they are logged in, unless they
+	// trusted this browser before
+	if user.HasTwoFactor() && !h.trustedDevice(r, user) {
+		h.startTwoFactor(w, r, user, remember)
+		return
+	}
+
+	err = h.logUserIn(w, r, user, remember)
+	if err != nil {
+		h.App.ErrorStatus(w, http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+
+}
+
+// logUserIn puts the user in a new session, and sets the remember me cookie if they asked for it
+func (h *Handlers) logUserIn(w http.ResponseWriter, r *http.Request, user *data.User, remember bool) error {
+	err := h.sessionRenew(r.Context())
+	if err != nil {
+		return err
+	}
+
+	if remember {
 		randStr, _ := h.randomString(12)
 
 		sha := sha256.New()
 		_, err := sha.Write([]byte(randStr))
 		if err != nil {
-			h.App.ErrorStatus(w, http.StatusBadRequest)
-			return
+			return err
 		}
 
 		hash := base64.URLEncoding.EncodeToString(sha.Sum(nil))
 		rToken := data.RememberToken{}
 		err = rToken.InsertToken(user.ID, hash)
 		if err != nil {
-			h.App.ErrorStatus(w, http.StatusBadRequest)
-			return
+			return err
 		}
 
 		// set cookie - default 30 days
@@ -97,13 +119,11 @@ func (h *Handlers) PostUserLogin(w http.ResponseWriter, r *http.Request) {
 		}
 		http.SetCookie(w, &cookie)
 		// save hash in session
-		h.App.Session.Put(r.Context(), "userID", user.ID)
+		h.App.Session.Put(r.Context(), "remember_token", hash)
 	}
 
 	h.App.Session.Put(r.Context(), "userID", user.ID)
-
-	http.Redirect(w, r, "/", http.StatusSeeOther)
-
+	return nil
 }
 
 func (h *Handlers) Logout(w http.ResponseWriter, r *http.Request) {
@@ -112,7 +132,7 @@ func (h *Handlers) Logout(w http.ResponseWriter, r *http.Request) {
 		rToken := data.RememberToken{}
 		err := rToken.Delete(h.App.Session.GetString(r.Context(), "remember_token"))
 		if err != nil {
-			log.Printf("Failed to delete remember token: %s", err)
+			h.App.Logger.ErrorContext(r.Context(), "failed to delete remember token", "error", err)
 		}
 	}
 
@@ -189,11 +209,11 @@ func (h *Handlers) PostForgot(w http.ResponseWriter, r *http.Request) {
 		Data:     data,
 		From:     "admin@example.com",
 	}
-	h.App.Mail.Jobs <- msg
-	res := <-h.App.Mail.Results
-	if res.Error != nil {
-		fmt.Println("error processing email:", res.Error)
-		h.App.ErrorStatus(w, http.StatusBadRequest)
+	// the mail is retried in the background until it is sent
+	_, err = h.App.Mail.Queue(r.Context(), msg)
+	if err != nil {
+		h.App.Logger.ErrorContext(r.Context(), "error processing email", "error", err)
+		h.App.ErrorStatus(w, http.StatusInternalServerError)
 		return
 	}
 
@@ -267,6 +287,18 @@ func (h *Handlers) PostResetPassword(w http.ResponseWriter, r *http.Request) {
 		h.App.ErrorIntServerErr(w, r)
 		return
 	}
+	// sign the user out everywhere, in case the old password was stolen
+	// the new password is saved, so a failure here is logged rather than reported to the user
+	err = h.App.Sessions.RevokeAll(r.Context(), strconv.Itoa(user.ID))
+	if err != nil {
+		h.App.Logger.ErrorContext(r.Context(), "failed to revoke sessions after password reset", "user", user.ID, "error", err)
+	}
+	var rToken data.RememberToken
+	err = rToken.DeleteForUser(user.ID)
+	if err != nil {
+		h.App.ErrorIntServerErr(w, r)
+		return
+	}
 	// redirect
 	h.App.Session.Put(r.Context(), "flash", "Password has been reset. You can now log in.")
 	http.Redirect(w, r, "/users/login", http.StatusSeeOther)
@@ -278,11 +310,11 @@ func (h *Handlers) InitSocialAuth() {
 	gScope := []string{"email", "profile"}
 
 	goth.UseProviders(
-		github.New(os.Getenv("GITHUB_KEY"), os.Getenv("GITHUB_SECRET"), os.Getenv("GITHUB_CALLBACK"), scope...),
-		google.New(os.Getenv("GOOGLE_KEY"), os.Getenv("GOOGLE_SECRET"), os.Getenv("GOOGLE_CALLBACK"), gScope...),
+		github.New(h.App.Env("GITHUB_KEY"), h.App.Env("GITHUB_SECRET"), h.App.Env("GITHUB_CALLBACK"), scope...),
+		google.New(h.App.Env("GOOGLE_KEY"), h.App.Env("GOOGLE_SECRET"), h.App.Env("GOOGLE_CALLBACK"), gScope...),
 	)
 
-	key := os.Getenv("KEY")
+	key := h.App.Env("KEY")
 	maxAge := 86400 * 30
 
 	st := sessions.NewCookieStore([]byte(key))
@@ -324,7 +356,7 @@ func (h *Handlers) SocialMediaCallback(w http.ResponseWriter, r *http.Request) {
 
 	testUser, err = u.GetByEmail(oAuthUser.Email)
 	if err != nil {
-		log.Println(err)
+		h.App.Logger.InfoContext(r.Context(), "no user for social login, creating one", "email", oAuthUser.Email, "error", err)
 		provider := h.App.Session.Get(r.Context(), "social_provider").(string)
 		// user does not exist, so we create a new user
 		var newUser data.User
@@ -358,10 +390,18 @@ func (h *Handlers) SocialMediaCallback(w http.ResponseWriter, r *http.Request) {
 		testUser, _ = u.GetByEmail(oAuthUser.Email)
 
 	}
-	h.App.Session.Put(r.Context(), "userID", testUser.ID)
 	h.App.Session.Put(r.Context(), "social_token", oAuthUser.AccessToken)
 	h.App.Session.Put(r.Context(), "social_email", oAuthUser.Email)
 
+	// the provider vouches for the email address, not for the second factor, so users with two-factor
+	// authentication still enter their code unless they trusted this browser before
+	if testUser.HasTwoFactor() && !h.trustedDevice(r, testUser) {
+		h.startTwoFactor(w, r, testUser, false)
+		return
+	}
+
+	h.App.Session.Put(r.Context(), "userID", testUser.ID)
+
 	h.App.Session.Put(r.Context(), "flash", "You have been sucessfully logged in.")
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 
@@ -378,8 +418,8 @@ func (h *Handlers) socialLogout(w http.ResponseWriter, r *http.Request) {
 
 	switch provider {
 	case "github":
-		clientID := os.Getenv("GITHUB_KEY")
-		clientSecret := os.Getenv("GITHUB_SECRET")
+		clientID := h.App.Env("GITHUB_KEY")
+		clientSecret := h.App.Env("GITHUB_SECRET")
 		token := h.App.Session.Get(r.Context(), "social_token").(string)
 
 		var payload struct {
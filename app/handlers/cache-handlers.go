@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/justinas/nosurf"
+	"github.com/wtran29/fenix/fenix/cache"
 )
 
 func (h *Handlers) CachePage(w http.ResponseWriter, r *http.Request) {
@@ -31,7 +32,7 @@ func (h *Handlers) SaveInCache(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.App.Cache.Set(userInput.Name, userInput.Value)
+	err = cache.Set(r.Context(), h.App.Cache, userInput.Name, userInput.Value, 0)
 	if err != nil {
 		h.App.ErrorIntServerErr(w, r)
 		return
@@ -69,7 +70,7 @@ func (h *Handlers) GetFromCache(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fromCache, err := h.App.Cache.Get(userInput.Name)
+	fromCache, err := cache.Get[string](r.Context(), h.App.Cache, userInput.Name)
 	if err != nil {
 		msg = "Not found in cache"
 		inCache = false
@@ -84,7 +85,7 @@ func (h *Handlers) GetFromCache(w http.ResponseWriter, r *http.Request) {
 	if inCache {
 		resp.Error = false
 		resp.Message = "Success"
-		resp.Value = fromCache.(string)
+		resp.Value = fromCache
 	} else {
 		resp.Error = true
 		resp.Message = msg
@@ -110,7 +111,7 @@ func (h *Handlers) DeleteFromCache(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.App.Cache.Remove(userInput.Name)
+	err = h.App.Cache.RemoveContext(r.Context(), userInput.Name)
 	if err != nil {
 		h.App.ErrorIntServerErr(w, r)
 		return
@@ -2,20 +2,15 @@ package handlers
 
 import (
 	"fmt"
-	"io"
 	"myapp/data"
 	"net/http"
 	"net/url"
-	"os"
+	"path"
 	"time"
 
 	"github.com/CloudyKit/jet/v6"
 	"github.com/wtran29/fenix/fenix"
 	"github.com/wtran29/fenix/fenix/cmd/filesystems"
-	"github.com/wtran29/fenix/fenix/cmd/filesystems/miniofilesystem"
-	"github.com/wtran29/fenix/fenix/cmd/filesystems/s3filesystem"
-	"github.com/wtran29/fenix/fenix/cmd/filesystems/sftpfilesystem"
-	"github.com/wtran29/fenix/fenix/cmd/filesystems/webdavfilesystem"
 )
 
 type Handlers struct {
@@ -145,25 +140,10 @@ func (h *Handlers) ListFS(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if fsType != "" {
-		switch fsType {
-		case "MINIO":
-			f := h.App.FileSystems["MINIO"].(miniofilesystem.Minio)
-			fs = &f
-			fsType = "MINIO"
-
-		case "SFTP":
-			f := h.App.FileSystems["SFTP"].(sftpfilesystem.SFTP)
-			fs = &f
-			fsType = "SFTP"
-
-		case "WEBDAV":
-			f := h.App.FileSystems["WEBDAV"].(webdavfilesystem.WebDAV)
-			fs = &f
-			fsType = "WEBDAV"
-		case "S3":
-			f := h.App.FileSystems["S3"].(s3filesystem.S3)
-			fs = &f
-			fsType = "S3"
+		fs = h.App.Disk(fsType)
+		if fs == nil {
+			h.App.ErrorNotFound(w, r)
+			return
 		}
 
 		l, err := fs.List(curPath)
@@ -198,94 +178,45 @@ func (h *Handlers) UploadToFS(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handlers) PostUploadToFS(w http.ResponseWriter, r *http.Request) {
-	filename, err := getFileToUpload(r, "formFile")
+	err := r.ParseMultipartForm(10 << 20)
 	if err != nil {
-		http.Error(w, "Failed to upload file: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Failed to upload file: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	uploadType := r.Form.Get("upload-type")
-	switch uploadType {
-	case "MINIO":
-		fs := h.App.FileSystems["MINIO"].(miniofilesystem.Minio)
-		err = fs.Put(filename, "")
-		if err != nil {
-			http.Error(w, "Failed to upload file to MINIO: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-	case "SFTP":
-		fs := h.App.FileSystems["SFTP"].(sftpfilesystem.SFTP)
-		err = fs.Put(filename, "")
-		if err != nil {
-			http.Error(w, "Failed to upload file to SFTP: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-	case "WEBDAV":
-		fs := h.App.FileSystems["WEBDAV"].(webdavfilesystem.WebDAV)
-		err = fs.Put(filename, "")
-		if err != nil {
-			http.Error(w, "Failed to upload file to WEBDAV: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-	case "S3":
-		fs := h.App.FileSystems["S3"].(s3filesystem.S3)
-		err = fs.Put(filename, "")
-		if err != nil {
-			http.Error(w, "Failed to upload file to S3: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-	}
-
-	h.App.Session.Put(r.Context(), "flash", "File uploaded!")
-	http.Redirect(w, r, "/files/upload?type="+uploadType, http.StatusSeeOther)
-}
-
-func getFileToUpload(r *http.Request, fieldname string) (string, error) {
-	err := r.ParseMultipartForm(10 << 20)
+	file, header, err := r.FormFile("formFile")
 	if err != nil {
-		fmt.Println("could not parse multipart form:", err)
-	}
-	file, header, err := r.FormFile(fieldname)
-	if err != nil {
-		return "", err
+		http.Error(w, "Failed to upload file: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
-
 	defer file.Close()
 
-	dst, err := os.Create(fmt.Sprintf("./tmp/%s", header.Filename))
-	if err != nil {
-		return "", err
+	uploadType := r.Form.Get("upload-type")
+	fs := h.App.Disk(uploadType)
+	if fs == nil {
+		http.Error(w, "Unknown file system "+uploadType, http.StatusBadRequest)
+		return
 	}
 
-	defer dst.Close()
-
-	_, err = io.Copy(dst, file)
+	// stream the upload straight to the file system
+	err = fs.PutStream(path.Base(header.Filename), file, header.Size, header.Header.Get("Content-Type"))
 	if err != nil {
-		return "", err
+		http.Error(w, "Failed to upload file to "+uploadType+": "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	return fmt.Sprintf("./tmp/%s", header.Filename), nil
+	h.App.Session.Put(r.Context(), "flash", "File uploaded!")
+	http.Redirect(w, r, "/files/upload?type="+uploadType, http.StatusSeeOther)
 }
 
 func (h *Handlers) DeleteFromFS(w http.ResponseWriter, r *http.Request) {
-	var fs filesystems.FS
 	fsType := r.URL.Query().Get("fs_type")
 	item := r.URL.Query().Get("file")
 
-	switch fsType {
-	case "MINIO":
-		f := h.App.FileSystems["MINIO"].(miniofilesystem.Minio)
-		fs = &f
-	case "SFTP":
-		f := h.App.FileSystems["SFTP"].(sftpfilesystem.SFTP)
-		fs = &f
-	case "WEBDAV":
-		f := h.App.FileSystems["WEBDAV"].(webdavfilesystem.WebDAV)
-		fs = &f
-
-	case "S3":
-		f := h.App.FileSystems["S3"].(s3filesystem.S3)
-		fs = &f
+	fs := h.App.Disk(fsType)
+	if fs == nil {
+		h.App.ErrorNotFound(w, r)
+		return
 	}
 
 	deleted := fs.Delete([]string{item})
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"testing"
@@ -21,8 +22,9 @@ var testSession *scs.SessionManager
 var testHandlers Handlers
 
 func TestMain(m *testing.M) {
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	errorLog := log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	infoLog := slog.NewLogLogger(logger.Handler(), slog.LevelInfo)
+	errorLog := slog.NewLogLogger(logger.Handler(), slog.LevelError)
 
 	testSession = scs.New()
 	testSession.Lifetime = 24 * time.Hour
@@ -47,6 +49,7 @@ func TestMain(m *testing.M) {
 		AppName:       "myapp",
 		Debug:         true,
 		Version:       "1.0.0",
+		Logger:        logger,
 		ErrorLog:      errorLog,
 		InfoLog:       infoLog,
 		RootPath:      "../",
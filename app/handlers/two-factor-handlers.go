@@ -0,0 +1,410 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"myapp/data"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/wtran29/fenix/fenix/auth"
+	"github.com/wtran29/fenix/fenix/cache"
+	"github.com/wtran29/fenix/fenix/urlsigner"
+)
+
+// session keys of a login waiting for its two-factor code, and of an enrollment waiting for its first code
+const (
+	twoFactorUserKey     = "2fa_user_id"
+	twoFactorRememberKey = "2fa_remember"
+	twoFactorStartedKey  = "2fa_started"
+	twoFactorSetupKey    = "2fa_setup_secret"
+)
+
+// twoFactorTimeout is how long a user has to enter their code after their password
+const twoFactorTimeout = 5 * time.Minute
+
+// twoFactorMaxFailures is how many wrong codes a user may enter within twoFactorLockout. They are
+// counted per user in the cache, not per login or address, so someone who knows the password gets no
+// more guesses by logging in again or from elsewhere; once they are used up, codes are refused until
+// the lockout has passed.
+const twoFactorMaxFailures = 5
+
+// twoFactorLockout is how long wrong codes are counted for, from the first of them
+const twoFactorLockout = 15 * time.Minute
+
+// trustedDeviceDays is how long a browser the user chose to trust skips the two-factor step
+const trustedDeviceDays = 30
+
+// startTwoFactor remembers that user entered their password, and sends them on to enter their code
+func (h *Handlers) startTwoFactor(w http.ResponseWriter, r *http.Request, user *data.User, remember bool) {
+	err := h.sessionRenew(r.Context())
+	if err != nil {
+		h.App.ErrorStatus(w, http.StatusBadRequest)
+		return
+	}
+
+	h.sessionPut(r.Context(), twoFactorUserKey, user.ID)
+	h.sessionPut(r.Context(), twoFactorRememberKey, remember)
+	h.sessionPut(r.Context(), twoFactorStartedKey, time.Now().Unix())
+
+	http.Redirect(w, r, "/users/two-factor", http.StatusSeeOther)
+}
+
+// pendingTwoFactor returns the user who entered their password and still has to enter their code, or
+// nil if there is none or they took too long
+func (h *Handlers) pendingTwoFactor(ctx context.Context) (*data.User, error) {
+	id := h.App.Session.GetInt(ctx, twoFactorUserKey)
+	if id == 0 {
+		return nil, nil
+	}
+
+	started := time.Unix(h.App.Session.GetInt64(ctx, twoFactorStartedKey), 0)
+	if time.Since(started) > twoFactorTimeout {
+		h.clearTwoFactor(ctx)
+		return nil, nil
+	}
+
+	return h.Models.Users.Get(id)
+}
+
+func (h *Handlers) clearTwoFactor(ctx context.Context) {
+	h.sessionRemove(ctx, twoFactorUserKey)
+	h.sessionRemove(ctx, twoFactorRememberKey)
+	h.sessionRemove(ctx, twoFactorStartedKey)
+}
+
+// twoFactorFailuresKey is the cache key counting the wrong codes of user
+func twoFactorFailuresKey(user *data.User) string {
+	return fmt.Sprintf("2fa_failures:%d", user.ID)
+}
+
+// twoFactorLocked reports whether user has used up their wrong codes
+func (h *Handlers) twoFactorLocked(ctx context.Context, user *data.User) (bool, error) {
+	b, err := h.App.Cache.GetContext(ctx, twoFactorFailuresKey(user))
+	if errors.Is(err, cache.ErrCacheMiss) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	n, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return false, err
+	}
+	return n >= twoFactorMaxFailures, nil
+}
+
+// twoFactorFailed counts a wrong code of user, and reports whether it was their last
+func (h *Handlers) twoFactorFailed(ctx context.Context, user *data.User) (bool, error) {
+	counter, ok := h.App.Cache.(cache.Atomic)
+	if !ok {
+		return false, errors.New("the cache cannot count wrong two-factor codes")
+	}
+
+	// the first wrong code starts the lockout; later ones keep its expiry
+	key := twoFactorFailuresKey(user)
+	_, err := counter.SetNX(ctx, key, []byte("0"), twoFactorLockout)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := counter.Increment(ctx, key, 1)
+	if err != nil {
+		return false, err
+	}
+	return n >= twoFactorMaxFailures, nil
+}
+
+func (h *Handlers) TwoFactorChallenge(w http.ResponseWriter, r *http.Request) {
+	user, err := h.pendingTwoFactor(r.Context())
+	if err != nil || user == nil {
+		h.sessionPut(r.Context(), "error", "Your login has expired. Please log in again.")
+		http.Redirect(w, r, "/users/login", http.StatusSeeOther)
+		return
+	}
+
+	err = h.render(w, r, "two-factor", nil, nil)
+	if err != nil {
+		h.App.ErrorLog.Println(err)
+	}
+}
+
+func (h *Handlers) PostTwoFactorChallenge(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		h.App.ErrorBadRequest(w, r)
+		return
+	}
+
+	user, err := h.pendingTwoFactor(r.Context())
+	if err != nil || user == nil {
+		h.sessionPut(r.Context(), "error", "Your login has expired. Please log in again.")
+		http.Redirect(w, r, "/users/login", http.StatusSeeOther)
+		return
+	}
+
+	locked, err := h.twoFactorLocked(r.Context(), user)
+	if err != nil {
+		h.App.Logger.ErrorContext(r.Context(), "failed to count wrong two-factor codes", "user", user.ID, "error", err)
+		h.App.ErrorIntServerErr(w, r)
+		return
+	}
+	if locked {
+		h.clearTwoFactor(r.Context())
+		h.sessionPut(r.Context(), "error", "Too many invalid codes. Please try again later.")
+		http.Redirect(w, r, "/users/login", http.StatusSeeOther)
+		return
+	}
+
+	ok, err := user.VerifyTwoFactor(r.Form.Get("code"))
+	if err != nil {
+		h.App.Logger.ErrorContext(r.Context(), "failed to verify two-factor code", "error", err)
+		h.App.ErrorIntServerErr(w, r)
+		return
+	}
+	if !ok {
+		locked, err := h.twoFactorFailed(r.Context(), user)
+		if err != nil {
+			h.App.Logger.ErrorContext(r.Context(), "failed to count wrong two-factor codes", "user", user.ID, "error", err)
+			h.App.ErrorIntServerErr(w, r)
+			return
+		}
+		if locked {
+			h.clearTwoFactor(r.Context())
+			h.sessionPut(r.Context(), "error", "Too many invalid codes. Please try again later.")
+			http.Redirect(w, r, "/users/login", http.StatusSeeOther)
+			return
+		}
+
+		h.sessionPut(r.Context(), "error", "Invalid code. Please try again.")
+		http.Redirect(w, r, "/users/two-factor", http.StatusSeeOther)
+		return
+	}
+
+	err = h.App.Cache.RemoveContext(r.Context(), twoFactorFailuresKey(user))
+	if err != nil {
+		h.App.Logger.WarnContext(r.Context(), "failed to reset wrong two-factor codes", "user", user.ID, "error", err)
+	}
+
+	remember := h.App.Session.GetBool(r.Context(), twoFactorRememberKey)
+	h.clearTwoFactor(r.Context())
+
+	if r.Form.Get("trust") == "trust" {
+		h.trustDevice(w, user)
+	}
+
+	err = h.logUserIn(w, r, user, remember)
+	if err != nil {
+		h.App.ErrorStatus(w, http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// deviceCookie is the name of the cookie that marks a browser the user trusts
+func (h *Handlers) deviceCookie() string {
+	return fmt.Sprintf("_%s_2fa_device", h.App.AppName)
+}
+
+// deviceData is what the trusted device cookie of user signs. It includes part of a hash of their
+// secret, so that turning two-factor authentication off or enrolling again forgets every device.
+func (h *Handlers) deviceData(user *data.User) string {
+	sum := sha256.Sum256([]byte(user.TOTPSecret))
+	return fmt.Sprintf("2fa-device:%d:%s", user.ID, hex.EncodeToString(sum[:])[:16])
+}
+
+// trustDevice sets the signed cookie that lets this browser skip the two-factor step for user
+func (h *Handlers) trustDevice(w http.ResponseWriter, user *data.User) {
+	signer := urlsigner.Signer{
+		Secret: []byte(h.App.EncryptionKey),
+	}
+
+	cookie := http.Cookie{
+		Name:     h.deviceCookie(),
+		Value:    signer.GenerateTokenFromString(h.deviceData(user)),
+		Path:     "/",
+		Expires:  time.Now().Add(trustedDeviceDays * 24 * time.Hour),
+		HttpOnly: true,
+		Domain:   h.App.Session.Cookie.Domain,
+		MaxAge:   trustedDeviceDays * 24 * 60 * 60,
+		Secure:   h.App.Session.Cookie.Secure,
+		SameSite: h.App.Session.Cookie.SameSite,
+	}
+	http.SetCookie(w, &cookie)
+}
+
+// trustedDevice reports whether the user trusted this browser in the last trustedDeviceDays
+func (h *Handlers) trustedDevice(r *http.Request, user *data.User) bool {
+	cookie, err := r.Cookie(h.deviceCookie())
+	if err != nil {
+		return false
+	}
+
+	signer := urlsigner.Signer{
+		Secret: []byte(h.App.EncryptionKey),
+	}
+
+	d, ok := signer.DataFromToken(cookie.Value)
+	if !ok || signer.Expired(cookie.Value, trustedDeviceDays*24*60) {
+		return false
+	}
+	return d == h.deviceData(user)
+}
+
+// currentUser returns the user the auth middleware found, for pages behind it
+func (h *Handlers) currentUser(ctx context.Context) (*data.User, bool) {
+	return auth.UserAs[*data.User](ctx)
+}
+
+func (h *Handlers) TwoFactorSetup(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(r.Context())
+	if !ok {
+		h.App.ErrorUnauthorized(w, r)
+		return
+	}
+
+	vars := make(jet.VarMap)
+	vars.Set("enabled", user.HasTwoFactor())
+
+	if user.HasTwoFactor() {
+		left, err := user.RecoveryCodesLeft()
+		if err != nil {
+			h.App.ErrorIntServerErr(w, r)
+			return
+		}
+		vars.Set("codesLeft", left)
+	} else {
+		// keep the secret until the user proves their authenticator has it
+		secret := h.App.Session.GetString(r.Context(), twoFactorSetupKey)
+		if secret == "" {
+			var err error
+			secret, err = auth.NewTOTPSecret()
+			if err != nil {
+				h.App.ErrorIntServerErr(w, r)
+				return
+			}
+			h.sessionPut(r.Context(), twoFactorSetupKey, secret)
+		}
+		vars.Set("secret", secret)
+		vars.Set("uri", auth.TOTPURI(h.App.AppName, user.Email, secret))
+	}
+
+	err := h.render(w, r, "two-factor-setup", vars, nil)
+	if err != nil {
+		h.App.ErrorLog.Println(err)
+	}
+}
+
+func (h *Handlers) PostTwoFactorSetup(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		h.App.ErrorBadRequest(w, r)
+		return
+	}
+
+	user, ok := h.currentUser(r.Context())
+	if !ok {
+		h.App.ErrorUnauthorized(w, r)
+		return
+	}
+
+	secret := h.App.Session.GetString(r.Context(), twoFactorSetupKey)
+	if secret == "" || user.HasTwoFactor() {
+		http.Redirect(w, r, "/users/two-factor/setup", http.StatusSeeOther)
+		return
+	}
+
+	step, ok := auth.VerifyTOTP(secret, r.Form.Get("code"), time.Now())
+	if !ok {
+		h.sessionPut(r.Context(), "error", "Invalid code. Check the time on your device and try again.")
+		http.Redirect(w, r, "/users/two-factor/setup", http.StatusSeeOther)
+		return
+	}
+
+	codes, err := user.EnableTwoFactor(secret, step)
+	if err != nil {
+		h.App.Logger.ErrorContext(r.Context(), "failed to enable two-factor authentication", "error", err)
+		h.App.ErrorIntServerErr(w, r)
+		return
+	}
+	h.sessionRemove(r.Context(), twoFactorSetupKey)
+
+	h.renderRecoveryCodes(w, r, codes)
+}
+
+// PostTwoFactorRecoveryCodes replaces the user's recovery codes, once they confirmed their password
+func (h *Handlers) PostTwoFactorRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.confirmPassword(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := user.NewRecoveryCodes()
+	if err != nil {
+		h.App.Logger.ErrorContext(r.Context(), "failed to create recovery codes", "error", err)
+		h.App.ErrorIntServerErr(w, r)
+		return
+	}
+
+	h.renderRecoveryCodes(w, r, codes)
+}
+
+// PostTwoFactorDisable turns two-factor authentication off, once the user confirmed their password
+func (h *Handlers) PostTwoFactorDisable(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.confirmPassword(w, r)
+	if !ok {
+		return
+	}
+
+	err := user.DisableTwoFactor()
+	if err != nil {
+		h.App.Logger.ErrorContext(r.Context(), "failed to disable two-factor authentication", "error", err)
+		h.App.ErrorIntServerErr(w, r)
+		return
+	}
+
+	h.sessionPut(r.Context(), "flash", "Two-factor authentication is turned off.")
+	http.Redirect(w, r, "/users/two-factor/setup", http.StatusSeeOther)
+}
+
+// confirmPassword returns the logged in user if the form's password is theirs. Otherwise it responds,
+// and returns false.
+func (h *Handlers) confirmPassword(w http.ResponseWriter, r *http.Request) (*data.User, bool) {
+	err := r.ParseForm()
+	if err != nil {
+		h.App.ErrorBadRequest(w, r)
+		return nil, false
+	}
+
+	user, ok := h.currentUser(r.Context())
+	if !ok {
+		h.App.ErrorUnauthorized(w, r)
+		return nil, false
+	}
+
+	match, err := user.IsPasswordMatch(r.Form.Get("password"))
+	if err != nil || !match {
+		h.sessionPut(r.Context(), "error", "Invalid password. Please try again.")
+		http.Redirect(w, r, "/users/two-factor/setup", http.StatusSeeOther)
+		return nil, false
+	}
+
+	return user, true
+}
+
+func (h *Handlers) renderRecoveryCodes(w http.ResponseWriter, r *http.Request, codes []string) {
+	vars := make(jet.VarMap)
+	vars.Set("codes", codes)
+
+	err := h.render(w, r, "two-factor-recovery", vars, nil)
+	if err != nil {
+		h.App.ErrorLog.Println(err)
+	}
+}
@@ -24,6 +24,8 @@ func initApplication() *application {
 	}
 
 	fnx.AppName = "myapp"
+	// the auth guards look users up in the User model
+	fnx.Users = &data.User{}
 
 	middleware := &middleware.Middleware{
 		App: fnx,
@@ -42,7 +44,7 @@ func initApplication() *application {
 	// overwriting the default routes from Fenix with routes from Fenix and our own routes
 	app.App.Routes = app.routes()
 
-	app.Models = data.New(app.App.DB.Pool)
+	app.Models = data.New(app.App.DB.Pool, app.App.DB.DataType)
 	handlers.Models = app.Models
 	app.Middleware.Models = app.Models
 
@@ -1,14 +1,11 @@
 package main
 
 import (
+	"context"
 	"myapp/data"
 	"myapp/handlers"
 	"myapp/middleware"
-	"net/http"
-	"os"
-	"os/signal"
 	"sync"
-	"syscall"
 
 	"github.com/wtran29/fenix/fenix"
 )
@@ -23,29 +20,28 @@ type application struct {
 
 func main() {
 	f := initApplication()
-	go f.listenForShutdown()
+	f.App.OnShutdown(f.shutdown)
+	// blocks until SIGINT/SIGTERM, then shuts down gracefully
 	err := f.App.ListenAndServe()
-	if err != nil && err != http.ErrServerClosed {
+	if err != nil {
 		f.App.ErrorLog.Println(err)
 	}
 }
 
-func (a *application) shutdown() {
+func (a *application) shutdown(ctx context.Context) error {
 	// put any clean up tasks here
 
 	// block until the waitgroup is empty
-	a.wg.Wait()
-
-}
-
-func (a *application) listenForShutdown() {
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-
-	s := <-quit
-
-	a.App.InfoLog.Println("Received signal", s.String())
-	a.shutdown()
-
-	os.Exit(0)
+	done := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -2,10 +2,7 @@ package middleware
 
 import "net/http"
 
+// Auth lets through users who are logged in, by their session or remember-me cookie
 func (m *Middleware) Auth(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !m.App.Session.Exists(r.Context(), "userID") {
-			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
-		}
-	})
+	return m.App.RequireAuth(m.App.SessionGuard(), m.App.RememberGuard())(next)
 }
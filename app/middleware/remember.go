@@ -1,74 +1,27 @@
 package middleware
 
 import (
-	"fmt"
-	"myapp/data"
+	"errors"
 	"net/http"
-	"strconv"
-	"strings"
-	"time"
+
+	"github.com/wtran29/fenix/fenix/auth"
 )
 
+// CheckRemember signs in visitors who are not signed in yet but have a remember-me cookie, through
+// fenix's RememberGuard
 func (m *Middleware) CheckRemember(next http.Handler) http.Handler {
+	guard := m.App.RememberGuard()
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !m.App.Session.Exists(r.Context(), "userID") {
-			// user not logged in
-			cookie, err := r.Cookie(fmt.Sprintf("_%s_remember", m.App.AppName))
-			if err != nil {
-				// no cookie, move to next middleware
-				next.ServeHTTP(w, r)
-			} else {
-				// found cookie, check cookie
-				key := cookie.Value
-				var u data.User
-				if len(key) > 0 {
-					// validate cookie data
-					split := strings.Split(key, "|")
-					uid, hash := split[0], split[1]
-					id, _ := strconv.Atoi(uid)
-					validHash := u.CheckRememberToken(id, hash)
-					if !validHash {
-						m.deleteRememberCookie(w, r)
-						m.App.Session.Put(r.Context(), "error", "You've been logged out from another device.")
-						next.ServeHTTP(w, r)
-					} else {
-						// valid hash, log in user
-						user, _ := u.Get(id)
-						m.App.Session.Put(r.Context(), "userID", user.ID)
-						m.App.Session.Put(r.Context(), "remember_token", hash)
-						next.ServeHTTP(w, r)
-					}
-				} else {
-					// key length is zero, leftover cookie when user has not closed browser
-					m.deleteRememberCookie(w, r)
-					next.ServeHTTP(w, r)
-				}
+			// the guard deletes a cookie that is no longer valid
+			_, err := guard.Authenticate(w, r)
+			if errors.Is(err, auth.ErrInvalidCredentials) {
+				m.App.Session.Put(r.Context(), "error", "You've been logged out from another device.")
+			} else if err != nil {
+				m.App.Logger.ErrorContext(r.Context(), "failed to sign in with the remember-me cookie", "error", err)
 			}
-		} else {
-			// user logged in
-			next.ServeHTTP(w, r)
 		}
+		next.ServeHTTP(w, r)
 	})
 }
-
-func (m *Middleware) deleteRememberCookie(w http.ResponseWriter, r *http.Request) {
-	_ = m.App.Session.RenewToken(r.Context())
-	// delete cookie
-	newCookie := http.Cookie{
-		Name:     fmt.Sprintf("_%s_remember", m.App.AppName),
-		Value:    "",
-		Path:     "/",
-		Expires:  time.Now().Add(-100 * time.Hour),
-		HttpOnly: true,
-		Domain:   m.App.Session.Cookie.Domain,
-		MaxAge:   -1,
-		Secure:   m.App.Session.Cookie.Secure,
-		SameSite: http.SameSiteStrictMode,
-	}
-	http.SetCookie(w, &newCookie)
-
-	// logout user
-	m.App.Session.Remove(r.Context(), "userID")
-	m.App.Session.Destroy(r.Context())
-	_ = m.App.Session.RenewToken(r.Context())
-}
@@ -1,7 +1,13 @@
 package main
 
 import (
+	"myapp/data"
 	"net/http"
+	"time"
+
+	"github.com/wtran29/fenix/fenix"
+	"github.com/wtran29/fenix/fenix/auth"
+	"github.com/wtran29/fenix/fenix/ratelimit"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -9,7 +15,9 @@ import (
 func (a *application) ApiRoutes() http.Handler {
 	r := chi.NewRouter()
 
-	r.Route("/api", func(mux chi.Router) {
+	// public API routes are limited by IP
+	r.Group(func(r chi.Router) {
+		r.Use(a.App.RateLimit(60, time.Minute, fenix.LimitBy(ratelimit.ByIP)))
 
 		r.Get("/test-api", func(w http.ResponseWriter, r *http.Request) {
 			var payload struct {
@@ -21,5 +29,26 @@ func (a *application) ApiRoutes() http.Handler {
 		})
 	})
 
+	// routes that need an API token. Guessing tokens is limited by IP; once the token checks out the
+	// limit is per user, however many tokens or addresses they use.
+	r.Group(func(r chi.Router) {
+		r.Use(a.App.RateLimit(300, time.Minute, fenix.LimitBy(ratelimit.ByIP)))
+		r.Use(a.Middleware.AuthToken)
+		r.Use(a.App.RateLimit(60, time.Minute, fenix.LimitBy(a.App.ByUser)))
+
+		r.Get("/test-api-user", func(w http.ResponseWriter, r *http.Request) {
+			user, _ := auth.UserAs[*data.User](r.Context())
+
+			var payload struct {
+				ID    int    `json:"id"`
+				Email string `json:"email"`
+			}
+
+			payload.ID = user.ID
+			payload.Email = user.Email
+			a.App.WriteJSON(w, http.StatusOK, payload)
+		})
+	})
+
 	return r
 }
@@ -2,13 +2,12 @@ package main
 
 import (
 	"fmt"
-	"log"
 	"myapp/data"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/wtran29/fenix/fenix"
-	"github.com/wtran29/fenix/fenix/cmd/filesystems/miniofilesystem"
 	"github.com/wtran29/fenix/fenix/mailer"
 	"github.com/wtran29/fenix/fenix/testFolder"
 
@@ -27,13 +26,25 @@ func (a *application) routes() *chi.Mux {
 	a.get("/tester", a.Handlers.Clicker)
 
 	a.get("/users/login", a.Handlers.UserLogin)
-	a.post("/users/login", a.Handlers.PostUserLogin)
+	// slow down password guessing; these limits hold even while the cache is down
+	a.App.Routes.With(a.App.RateLimit(5, time.Minute, fenix.LimitFailClosed())).Post("/users/login", a.Handlers.PostUserLogin)
 	a.get("/users/logout", a.Handlers.Logout)
 	a.get("/users/forgot-password", a.Handlers.Forgot)
-	a.post("/users/forgot-password", a.Handlers.PostForgot)
+	a.App.Routes.With(a.App.RateLimit(5, time.Minute, fenix.LimitFailClosed())).Post("/users/forgot-password", a.Handlers.PostForgot)
 	a.get("/users/reset-password", a.Handlers.ResetPasswordForm)
 	a.post("/users/reset-password", a.Handlers.PostResetPassword)
 
+	// two-factor authentication: the code after the password, and turning it on and off
+	a.get("/users/two-factor", a.Handlers.TwoFactorChallenge)
+	a.App.Routes.With(a.App.RateLimit(5, time.Minute, fenix.LimitFailClosed())).Post("/users/two-factor", a.Handlers.PostTwoFactorChallenge)
+	a.App.Routes.Group(func(r chi.Router) {
+		r.Use(a.Middleware.Auth)
+		r.Get("/users/two-factor/setup", a.Handlers.TwoFactorSetup)
+		r.With(a.App.RateLimit(5, time.Minute, fenix.LimitFailClosed())).Post("/users/two-factor/setup", a.Handlers.PostTwoFactorSetup)
+		r.With(a.App.RateLimit(5, time.Minute, fenix.LimitFailClosed())).Post("/users/two-factor/recovery-codes", a.Handlers.PostTwoFactorRecoveryCodes)
+		r.With(a.App.RateLimit(5, time.Minute, fenix.LimitFailClosed())).Post("/users/two-factor/disable", a.Handlers.PostTwoFactorDisable)
+	})
+
 	a.get("/auth/{provider}", a.Handlers.SocialLogin)
 	a.get("/auth/{provider}/callback", a.Handlers.SocialMediaCallback)
 
@@ -64,23 +75,20 @@ func (a *application) routes() *chi.Mux {
 
 	a.get("/test-route", testFolder.TestHandler)
 	a.get("/test-minio", func(w http.ResponseWriter, r *http.Request) {
-		log.Println("route to test-minio")
-		fs, ok := a.App.FileSystems["MINIO"].(miniofilesystem.Minio)
-		if !ok {
-			log.Println("File system 'MINIO' not found or has an unexpected type")
+		fs := a.App.Disk("MINIO")
+		if fs == nil {
+			a.App.Logger.WarnContext(r.Context(), "file system not found", "disk", "MINIO")
 			return
 		}
-		log.Println(fs)
 
 		files, err := fs.List("")
 		if err != nil {
-			log.Println(err)
+			a.App.Logger.ErrorContext(r.Context(), "listing files", "disk", "MINIO", "error", err)
 			return
 		}
-		log.Println(files)
 
 		for _, file := range files {
-			log.Println(file.Key)
+			a.App.Logger.InfoContext(r.Context(), "found file", "disk", "MINIO", "key", file.Key)
 		}
 	})
 
@@ -94,10 +102,10 @@ func (a *application) routes() *chi.Mux {
 			Data:        nil,
 		}
 
-		a.App.Mail.Jobs <- msg
-		res := <-a.App.Mail.Results
-		if res.Error != nil {
-			a.App.ErrorLog.Println(res.Error)
+		id, err := a.App.Mail.Queue(r.Context(), msg)
+		if err != nil {
+			a.App.ErrorLog.Println(err)
+			return
 		}
 
 		// err := a.App.Mail.SendSMTPMessage(msg)
@@ -106,7 +114,17 @@ func (a *application) routes() *chi.Mux {
 		// 	return
 		// }
 
-		fmt.Fprint(w, "Sent mail!")
+		fmt.Fprintf(w, "Queued mail %s", id)
+	})
+
+	a.get("/test-mail/{id}", func(w http.ResponseWriter, r *http.Request) {
+		d, err := a.App.Mail.Status(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			a.App.ErrorLog.Println(err)
+			return
+		}
+
+		fmt.Fprintf(w, "%s after %d attempts %s", d.Status, d.Attempts, d.Error)
 	})
 
 	a.App.Routes.Get("/create-user", func(w http.ResponseWriter, r *http.Request) {
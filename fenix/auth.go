@@ -0,0 +1,97 @@
+package fenix
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/wtran29/fenix/fenix/auth"
+)
+
+// SessionGuard authenticates requests by the user id the login handler put in the session
+func (f *Fenix) SessionGuard() *auth.SessionGuard {
+	return &auth.SessionGuard{Session: f.Session, Users: f.Users, Key: sessionUserKey}
+}
+
+// RememberGuard authenticates requests by the remember-me cookie the login handler set
+func (f *Fenix) RememberGuard() *auth.RememberGuard {
+	return &auth.RememberGuard{
+		Session: f.Session,
+		Users:   f.Users,
+		Cookie:  fmt.Sprintf("_%s_remember", f.AppName),
+		Key:     sessionUserKey,
+	}
+}
+
+// TokenGuard authenticates requests by their bearer API token
+func (f *Fenix) TokenGuard() *auth.TokenGuard {
+	return &auth.TokenGuard{Users: f.Users}
+}
+
+// BasicGuard authenticates requests with HTTP basic auth, asking browsers for the username and
+// password of realm. Users with two-factor authentication are refused, since it cannot ask for their
+// code.
+func (f *Fenix) BasicGuard(realm string) *auth.BasicGuard {
+	return &auth.BasicGuard{Users: f.Users, Realm: realm}
+}
+
+// RequireAuth returns middleware that lets through requests one of the guards authenticates, with
+// the user in the request context for auth.UserFrom, and answers others with 401 Unauthorized. With
+// no guards it uses SessionGuard and RememberGuard. The guards look users up in f.Users, which must
+// be set before they are created.
+func (f *Fenix) RequireAuth(guards ...auth.Guard) func(http.Handler) http.Handler {
+	if len(guards) == 0 {
+		guards = []auth.Guard{f.SessionGuard(), f.RememberGuard()}
+	}
+
+	var challenges []string
+	api := false
+	for _, g := range guards {
+		if c, ok := g.(auth.Challenger); ok {
+			challenges = append(challenges, c.Challenge())
+		}
+		if _, ok := g.(*auth.TokenGuard); ok {
+			api = true
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, err := auth.Authenticate(w, r, guards...)
+			if user != nil {
+				next.ServeHTTP(w, r.WithContext(auth.WithUser(r.Context(), user)))
+				return
+			}
+
+			if err != nil {
+				f.Logger.DebugContext(r.Context(), "authentication failed", "path", r.URL.Path, "error", err)
+			}
+			for _, c := range challenges {
+				w.Header().Add("WWW-Authenticate", c)
+			}
+
+			f.authError(w, r, http.StatusUnauthorized, api)
+		})
+	}
+}
+
+// authError answers a request that was not authenticated or authorized with status, in JSON for API
+// clients
+func (f *Fenix) authError(w http.ResponseWriter, r *http.Request, status int, api bool) {
+	if !api && !strings.Contains(r.Header.Get("Accept"), "application/json") {
+		f.ErrorStatus(w, status)
+		return
+	}
+
+	var payload struct {
+		Error   bool   `json:"error"`
+		Message string `json:"message"`
+	}
+	payload.Error = true
+	payload.Message = "invalid authentication credentials"
+	if status == http.StatusForbidden {
+		payload.Message = "not allowed"
+	}
+
+	_ = f.WriteJSON(w, status, payload)
+}
@@ -0,0 +1,98 @@
+// Package auth finds out who a request is made by. Each Guard reads one kind of credentials, such as
+// the session, a bearer token, the remember-me cookie or HTTP basic auth, and looks the user up
+// through the app's UserProvider.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ErrInvalidCredentials is returned by guards when a request carries credentials that do not belong
+// to a user
+var ErrInvalidCredentials = errors.New("auth: invalid credentials")
+
+// UserProvider looks up the users of the app; the app's User model satisfies it. Each method returns
+// an error when there is no such user, and the user, of the app's own type, otherwise.
+type UserProvider interface {
+	// UserByID returns the user with id, for the session guard
+	UserByID(id string) (interface{}, error)
+	// UserByToken returns the user holding the API token, for the token guard
+	UserByToken(token string) (interface{}, error)
+	// UserByRememberToken returns the user with id holding the remember-me token
+	UserByRememberToken(id, token string) (interface{}, error)
+	// UserByCredentials returns the user with username and password, for HTTP basic auth
+	UserByCredentials(username, password string) (interface{}, error)
+}
+
+// Identifiable is implemented by users that can tell their id, for things that are counted per user
+// such as rate limits. The id must be the one UserByID takes.
+type Identifiable interface {
+	AuthID() string
+}
+
+// RememberForgetter is implemented by user providers that can delete the remember-me tokens of a
+// user. Revoking a user's sessions deletes them, or RememberGuard would sign the revoked browsers
+// straight back in. The tokens belong to the user rather than to one session, so revoking any
+// session of a user signs every browser of theirs out of remember-me.
+type RememberForgetter interface {
+	ForgetRememberTokens(id string) error
+}
+
+// TwoFactorUser is implemented by users who can turn on two-factor authentication. BasicGuard has no
+// way to ask for the code, so it refuses users for whom HasTwoFactor is true.
+type TwoFactorUser interface {
+	HasTwoFactor() bool
+}
+
+// Guard authenticates requests with one kind of credentials
+type Guard interface {
+	// Authenticate returns the user r is made by, or nil if r does not carry the guard's credentials.
+	// An error means the credentials were given but are not valid.
+	Authenticate(w http.ResponseWriter, r *http.Request) (interface{}, error)
+}
+
+// Challenger is a guard that tells clients how to authenticate, in the WWW-Authenticate header of
+// the responses to requests it could not authenticate
+type Challenger interface {
+	Challenge() string
+}
+
+type contextKey struct{}
+
+// WithUser returns a copy of ctx that carries user
+func WithUser(ctx context.Context, user interface{}) context.Context {
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// UserFrom returns the user put in ctx by WithUser, or nil
+func UserFrom(ctx context.Context) interface{} {
+	return ctx.Value(contextKey{})
+}
+
+// UserAs returns the user put in ctx by WithUser as a T, such as *data.User, and false if there is
+// none or it is not a T
+func UserAs[T any](ctx context.Context) (T, bool) {
+	user, ok := UserFrom(ctx).(T)
+	return user, ok
+}
+
+// Authenticate runs the guards in order and returns the user found by the first that finds one. The
+// error is that of the first guard that failed, when none found a user.
+func Authenticate(w http.ResponseWriter, r *http.Request, guards ...Guard) (interface{}, error) {
+	var firstErr error
+	for _, g := range guards {
+		user, err := g.Authenticate(w, r)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if user != nil {
+			return user, nil
+		}
+	}
+	return nil, firstErr
+}
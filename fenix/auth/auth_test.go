@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+type testUser struct {
+	ID        string
+	Name      string
+	TwoFactor bool
+}
+
+func (u *testUser) HasTwoFactor() bool {
+	return u.TwoFactor
+}
+
+// testUsers knows one user, 1, with token "secret", remember token "remember" and password "pass", and
+// bob, with password "pass" and two-factor authentication
+type testUsers struct{}
+
+var errNoUser = errors.New("no such user")
+
+var alice = &testUser{ID: "1", Name: "alice"}
+
+var bob = &testUser{ID: "2", Name: "bob", TwoFactor: true}
+
+func (testUsers) UserByID(id string) (interface{}, error) {
+	if id == "1" {
+		return alice, nil
+	}
+	return nil, errNoUser
+}
+
+func (testUsers) UserByToken(token string) (interface{}, error) {
+	if token == "secret" {
+		return alice, nil
+	}
+	return nil, errNoUser
+}
+
+func (testUsers) UserByRememberToken(id, token string) (interface{}, error) {
+	if id == "1" && token == "remember" {
+		return alice, nil
+	}
+	return nil, errNoUser
+}
+
+func (testUsers) UserByCredentials(username, password string) (interface{}, error) {
+	if username == "alice" && password == "pass" {
+		return alice, nil
+	}
+	if username == "bob" && password == "pass" {
+		return bob, nil
+	}
+	return nil, errNoUser
+}
+
+func TestGuards(t *testing.T) {
+	sm := scs.New()
+	users := testUsers{}
+
+	tests := []struct {
+		name    string
+		guard   Guard
+		prepare func(r *http.Request)
+		want    bool
+		wantErr bool
+	}{
+		{"session", &SessionGuard{Session: sm, Users: users}, func(r *http.Request) { sm.Put(r.Context(), "userID", 1) }, true, false},
+		{"session without user", &SessionGuard{Session: sm, Users: users}, func(r *http.Request) {}, false, false},
+		{"session of unknown user", &SessionGuard{Session: sm, Users: users}, func(r *http.Request) { sm.Put(r.Context(), "userID", 2) }, false, true},
+		{"token", &TokenGuard{Users: users}, func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }, true, false},
+		{"token without header", &TokenGuard{Users: users}, func(r *http.Request) {}, false, false},
+		{"wrong token", &TokenGuard{Users: users}, func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }, false, true},
+		{"basic", &BasicGuard{Users: users}, func(r *http.Request) { r.SetBasicAuth("alice", "pass") }, true, false},
+		{"wrong password", &BasicGuard{Users: users}, func(r *http.Request) { r.SetBasicAuth("alice", "nope") }, false, true},
+		{"basic with two-factor", &BasicGuard{Users: users}, func(r *http.Request) { r.SetBasicAuth("bob", "pass") }, false, true},
+		{"remember", &RememberGuard{Session: sm, Users: users, Cookie: "remember"}, func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: "remember", Value: "1|remember"})
+		}, true, false},
+		{"wrong remember token", &RememberGuard{Session: sm, Users: users, Cookie: "remember"}, func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: "remember", Value: "1|nope"})
+		}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			ctx, err := sm.Load(r.Context(), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			r = r.WithContext(ctx)
+			tt.prepare(r)
+
+			user, err := tt.guard.Authenticate(httptest.NewRecorder(), r)
+			if (user != nil) != tt.want {
+				t.Errorf("expected a user: %v, got %v", tt.want, user)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected an error: %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr && !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("expected ErrInvalidCredentials, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRememberGuard_SignsIn(t *testing.T) {
+	sm := scs.New()
+	g := &RememberGuard{Session: sm, Users: testUsers{}, Cookie: "remember"}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx, _ := sm.Load(r.Context(), "")
+	r = r.WithContext(ctx)
+	r.AddCookie(&http.Cookie{Name: "remember", Value: "1|remember"})
+
+	if _, err := g.Authenticate(httptest.NewRecorder(), r); err != nil {
+		t.Fatal(err)
+	}
+	if sm.GetInt(ctx, "userID") != 1 || sm.GetString(ctx, "remember_token") != "remember" {
+		t.Errorf("expected the user to be signed in to the session, got %v", sm.Keys(ctx))
+	}
+
+	// an invalid cookie is deleted
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "remember", Value: "garbage"})
+	rr := httptest.NewRecorder()
+	if _, err := g.Authenticate(rr, r); err == nil {
+		t.Fatal("expected an error for a malformed cookie")
+	}
+	if c := rr.Result().Cookies(); len(c) != 1 || c[0].MaxAge >= 0 {
+		t.Errorf("expected the cookie to be deleted, got %v", c)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	users := testUsers{}
+	guards := []Guard{&TokenGuard{Users: users}, &BasicGuard{Users: users}}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if user, err := Authenticate(nil, r, guards...); user != nil || err != nil {
+		t.Errorf("expected nothing without credentials, got %v, %v", user, err)
+	}
+
+	r.SetBasicAuth("alice", "pass")
+	user, err := Authenticate(nil, r, guards...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithUser(r.Context(), user)
+	if u, ok := UserAs[*testUser](ctx); !ok || u.Name != "alice" {
+		t.Errorf("expected alice from the context, got %v", u)
+	}
+	if _, ok := UserAs[string](ctx); ok {
+		t.Error("expected UserAs to fail for the wrong type")
+	}
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"log/slog"
+	"reflect"
+	"sync"
+)
+
+// Authorizable is a user with roles, and permissions through them; the app's User model satisfies it
+type Authorizable interface {
+	// Roles returns the names of the user's roles, such as admin
+	Roles() ([]string, error)
+	// Permissions returns the names of the permissions the user has through their roles, such as
+	// posts.edit
+	Permissions() ([]string, error)
+}
+
+// Policy decides whether user may do an action to subject, which is nil for policies defined without
+// a subject
+type Policy func(user, subject interface{}) bool
+
+type policyKey struct {
+	action  string
+	subject reflect.Type
+}
+
+// Gate decides what users may do, by policies for what depends on the subject, like editing one's own
+// posts, and by the permissions of their roles otherwise. The zero Gate only checks permissions.
+type Gate struct {
+	// Before, if set, runs before every check, and answers it when decided is true; for instance it
+	// can let admins do anything
+	Before func(user interface{}, action string) (allowed, decided bool)
+	Logger *slog.Logger
+
+	mu       sync.RWMutex
+	policies map[policyKey]Policy
+}
+
+// Define makes p decide whether users may do action to subjects of the same type as subject, such as
+// (*data.Post)(nil). With a nil subject p decides action whatever the subject, in place of the
+// permission of the same name.
+func (g *Gate) Define(action string, subject interface{}, p Policy) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.policies == nil {
+		g.policies = make(map[policyKey]Policy)
+	}
+	g.policies[policyKey{action, reflect.TypeOf(subject)}] = p
+}
+
+func (g *Gate) policy(action string, subject interface{}) (Policy, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	p, ok := g.policies[policyKey{action, reflect.TypeOf(subject)}]
+	return p, ok
+}
+
+func (g *Gate) logger() *slog.Logger {
+	if g.Logger == nil {
+		return slog.Default()
+	}
+	return g.Logger
+}
+
+// Can reports whether user may do action, to subject if one is given: by Before, then by the policy
+// for action and the type of subject, then by the policy for action alone, and finally by whether
+// user has the permission named action. Nobody can do anything when user is nil.
+func (g *Gate) Can(user interface{}, action string, subject ...interface{}) bool {
+	if user == nil {
+		return false
+	}
+
+	if g.Before != nil {
+		if allowed, decided := g.Before(user, action); decided {
+			return allowed
+		}
+	}
+
+	if len(subject) > 0 && subject[0] != nil {
+		if p, ok := g.policy(action, subject[0]); ok {
+			return p(user, subject[0])
+		}
+	}
+	if p, ok := g.policy(action, nil); ok {
+		return p(user, nil)
+	}
+
+	ok, err := HasPermission(user, action)
+	if err != nil {
+		g.logger().Warn("checking permission failed", "permission", action, "error", err)
+	}
+	return ok
+}
+
+// HasRole reports whether user, an Authorizable, has any of roles
+func HasRole(user interface{}, roles ...string) (bool, error) {
+	a, ok := user.(Authorizable)
+	if !ok {
+		return false, nil
+	}
+
+	have, err := a.Roles()
+	if err != nil {
+		return false, err
+	}
+	return containsAny(have, roles), nil
+}
+
+// HasPermission reports whether user, an Authorizable, has all of permissions
+func HasPermission(user interface{}, permissions ...string) (bool, error) {
+	a, ok := user.(Authorizable)
+	if !ok {
+		return false, nil
+	}
+
+	have, err := a.Permissions()
+	if err != nil {
+		return false, err
+	}
+	for _, p := range permissions {
+		if !containsAny(have, []string{p}) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func containsAny(have, want []string) bool {
+	for _, w := range want {
+		for _, h := range have {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
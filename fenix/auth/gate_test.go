@@ -0,0 +1,74 @@
+package auth
+
+import "testing"
+
+type rbacUser struct {
+	id          int
+	roles       []string
+	permissions []string
+}
+
+func (u *rbacUser) Roles() ([]string, error)       { return u.roles, nil }
+func (u *rbacUser) Permissions() ([]string, error) { return u.permissions, nil }
+
+type post struct {
+	authorID int
+}
+
+func TestGate_Can(t *testing.T) {
+	editor := &rbacUser{id: 1, roles: []string{"editor"}, permissions: []string{"posts.edit", "posts.create"}}
+	author := &rbacUser{id: 2, roles: []string{"author"}, permissions: []string{"posts.create"}}
+	admin := &rbacUser{id: 3, roles: []string{"admin"}}
+
+	g := &Gate{
+		Before: func(user interface{}, action string) (bool, bool) {
+			ok, _ := HasRole(user, "admin")
+			return true, ok
+		},
+	}
+	g.Define("edit", (*post)(nil), func(user, subject interface{}) bool {
+		return user.(*rbacUser).id == subject.(*post).authorID || g.Can(user, "posts.edit")
+	})
+
+	own, other := &post{authorID: 2}, &post{authorID: 9}
+
+	tests := []struct {
+		name    string
+		user    interface{}
+		action  string
+		subject []interface{}
+		want    bool
+	}{
+		{"permission", editor, "posts.edit", nil, true},
+		{"missing permission", author, "posts.edit", nil, false},
+		{"policy for own post", author, "edit", []interface{}{own}, true},
+		{"policy for other post", author, "edit", []interface{}{other}, false},
+		{"policy falls back to permission", editor, "edit", []interface{}{other}, true},
+		{"before lets admins do anything", admin, "posts.delete", nil, true},
+		{"nobody", nil, "posts.create", nil, false},
+		{"user without roles", "alice", "posts.create", nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := g.Can(tt.user, tt.action, tt.subject...); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestHasRoleAndPermission(t *testing.T) {
+	u := &rbacUser{roles: []string{"editor", "author"}, permissions: []string{"posts.edit", "posts.create"}}
+
+	if ok, _ := HasRole(u, "admin", "author"); !ok {
+		t.Error("expected any of the roles to do")
+	}
+	if ok, _ := HasRole(u, "admin"); ok {
+		t.Error("expected a missing role not to do")
+	}
+	if ok, _ := HasPermission(u, "posts.edit", "posts.create"); !ok {
+		t.Error("expected the user to have both permissions")
+	}
+	if ok, _ := HasPermission(u, "posts.edit", "posts.delete"); ok {
+		t.Error("expected every permission to be needed")
+	}
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// SessionGuard authenticates requests whose session holds the id of a user, as put there on login
+type SessionGuard struct {
+	Session *scs.SessionManager
+	Users   UserProvider
+	// Key is the session key holding the user's id; userID by default
+	Key string
+}
+
+func (g *SessionGuard) key() string {
+	if g.Key == "" {
+		return "userID"
+	}
+	return g.Key
+}
+
+func (g *SessionGuard) Authenticate(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	if !g.Session.Exists(r.Context(), g.key()) {
+		return nil, nil
+	}
+
+	user, err := g.Users.UserByID(fmt.Sprint(g.Session.Get(r.Context(), g.key())))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	return user, nil
+}
+
+// TokenGuard authenticates requests with an "Authorization: Bearer <token>" header
+type TokenGuard struct {
+	Users UserProvider
+}
+
+func (g *TokenGuard) Authenticate(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, nil
+	}
+
+	user, err := g.Users.UserByToken(strings.TrimSpace(token))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	return user, nil
+}
+
+func (g *TokenGuard) Challenge() string {
+	return "Bearer"
+}
+
+// RememberGuard authenticates requests with the "<user id>|<token>" remember-me cookie set on login,
+// and signs the user in to the session, so that the cookie is read once per session. An invalid
+// cookie is deleted.
+type RememberGuard struct {
+	Session *scs.SessionManager
+	Users   UserProvider
+	// Cookie is the name of the remember-me cookie
+	Cookie string
+	// Key is the session key the user's id is put under; userID by default. Numeric ids are put as
+	// an int, as login handlers do, so Session.GetInt works whichever way the user signed in.
+	Key string
+	// TokenKey is the session key the token is put under, so logging out can delete it;
+	// remember_token by default
+	TokenKey string
+}
+
+func (g *RememberGuard) Authenticate(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	cookie, err := r.Cookie(g.Cookie)
+	if err != nil || cookie.Value == "" {
+		return nil, nil
+	}
+
+	id, token, ok := strings.Cut(cookie.Value, "|")
+	if !ok {
+		g.forget(w)
+		return nil, ErrInvalidCredentials
+	}
+
+	user, err := g.Users.UserByRememberToken(id, token)
+	if err != nil {
+		g.forget(w)
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	key, tokenKey := g.Key, g.TokenKey
+	if key == "" {
+		key = "userID"
+	}
+	if tokenKey == "" {
+		tokenKey = "remember_token"
+	}
+
+	// a new user is signed in, so the session gets a new token
+	if err := g.Session.RenewToken(r.Context()); err != nil {
+		return nil, err
+	}
+	if n, err := strconv.Atoi(id); err == nil {
+		g.Session.Put(r.Context(), key, n)
+	} else {
+		g.Session.Put(r.Context(), key, id)
+	}
+	g.Session.Put(r.Context(), tokenKey, token)
+
+	return user, nil
+}
+
+// forget deletes the remember-me cookie
+func (g *RememberGuard) forget(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     g.Cookie,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Now().Add(-100 * time.Hour),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Domain:   g.Session.Cookie.Domain,
+		Secure:   g.Session.Cookie.Secure,
+		SameSite: g.Session.Cookie.SameSite,
+	})
+}
+
+// BasicGuard authenticates requests with HTTP basic auth. It checks the password only, so it refuses
+// users with two-factor authentication, as told by TwoFactorUser, rather than let them skip their code.
+type BasicGuard struct {
+	Users UserProvider
+	// Realm is shown by browsers when they ask for the username and password
+	Realm string
+}
+
+func (g *BasicGuard) Authenticate(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, nil
+	}
+
+	user, err := g.Users.UserByCredentials(username, password)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	if u, ok := user.(TwoFactorUser); ok && u.HasTwoFactor() {
+		return nil, fmt.Errorf("%w: two-factor authentication is on for the user", ErrInvalidCredentials)
+	}
+	return user, nil
+}
+
+func (g *BasicGuard) Challenge() string {
+	realm := g.Realm
+	if realm == "" {
+		realm = "Restricted"
+	}
+	return fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", realm)
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// the SHA1 test vectors of RFC 6238, appendix B, cut to 6 digits
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(secret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("at %d: expected %s, got %s", tt.unix, tt.want, got)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	code, _ := TOTPCode(secret, TOTPStep(now.Add(-TOTPPeriod)))
+
+	step, ok := VerifyTOTP(secret, code[:3]+" "+code[3:], now)
+	if !ok || step != TOTPStep(now)-1 {
+		t.Errorf("expected the code of the last step to be accepted, got %d, %v", step, ok)
+	}
+
+	if _, ok := VerifyTOTP(secret, code, now.Add(2*TOTPPeriod)); ok {
+		t.Error("expected an old code to be rejected")
+	}
+	if _, ok := VerifyTOTP(secret, "12345", now); ok {
+		t.Error("expected a short code to be rejected")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("My App", "me@here.com", "ABC")
+	if !strings.HasPrefix(uri, "otpauth://totp/My%20App:me@here.com?") || !strings.Contains(uri, "secret=ABC") {
+		t.Errorf("unexpected URI %s", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	for _, c := range codes {
+		if len(c) != 11 || c[5] != '-' || seen[c] {
+			t.Errorf("unexpected code %q", c)
+		}
+		seen[c] = true
+	}
+
+	if HashRecoveryCode(codes[0]) != HashRecoveryCode(" "+strings.ToUpper(strings.Replace(codes[0], "-", "", 1))) {
+		t.Error("expected the hash to ignore case, spaces and dashes")
+	}
+}
@@ -0,0 +1,64 @@
+package fenix
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wtran29/fenix/fenix/auth"
+)
+
+type authTestUsers struct{}
+
+func (authTestUsers) UserByID(id string) (interface{}, error) { return nil, errors.New("no user") }
+
+func (authTestUsers) UserByToken(token string) (interface{}, error) {
+	if token == "secret" {
+		return "alice", nil
+	}
+	return nil, errors.New("no user")
+}
+
+func (authTestUsers) UserByRememberToken(id, token string) (interface{}, error) {
+	return nil, errors.New("no user")
+}
+
+func (authTestUsers) UserByCredentials(username, password string) (interface{}, error) {
+	if username == "alice" && password == "pass" {
+		return "alice", nil
+	}
+	return nil, errors.New("no user")
+}
+
+func TestFenix_RequireAuth(t *testing.T) {
+	f := &Fenix{Session: testFenix.Session, Logger: testFenix.Logger, Users: authTestUsers{}}
+
+	handler := f.RequireAuth(f.TokenGuard(), f.BasicGuard("admin"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := auth.UserAs[string](r.Context())
+		_, _ = w.Write([]byte(user))
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+	if rr.Code != http.StatusOK || rr.Body.String() != "alice" {
+		t.Errorf("expected alice to be let through, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.SetBasicAuth("alice", "nope")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rr.Code)
+	}
+	if h := rr.Header().Values("WWW-Authenticate"); len(h) != 2 || !strings.HasPrefix(h[1], `Basic realm="admin"`) {
+		t.Errorf("expected a challenge for each guard, got %v", h)
+	}
+	if !strings.Contains(rr.Body.String(), "invalid authentication credentials") {
+		t.Errorf("expected a JSON error with a token guard, got %q", rr.Body.String())
+	}
+}
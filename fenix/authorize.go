@@ -0,0 +1,74 @@
+package fenix
+
+import (
+	"net/http"
+
+	"github.com/wtran29/fenix/fenix/auth"
+)
+
+// Can reports whether user may do action, to subject if one is given, by the policies and
+// permissions of f.Gate
+func (f *Fenix) Can(user interface{}, action string, subject ...interface{}) bool {
+	return f.Gate.Can(user, action, subject...)
+}
+
+// CurrentUser returns the user RequireAuth put in the context of r, or else the user signed in to
+// the session, or nil
+func (f *Fenix) CurrentUser(r *http.Request) interface{} {
+	if user := auth.UserFrom(r.Context()); user != nil {
+		return user
+	}
+	if f.Users == nil || f.Session == nil {
+		return nil
+	}
+
+	user, _ := f.SessionGuard().Authenticate(nil, r)
+	return user
+}
+
+// RequirePermission returns middleware that lets through users who may do every one of permissions,
+// as decided by Can, and answers others with 403 Forbidden. It goes after RequireAuth; requests
+// without a user get 401 Unauthorized.
+func (f *Fenix) RequirePermission(permissions ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := auth.UserFrom(r.Context())
+			if user == nil {
+				f.authError(w, r, http.StatusUnauthorized, false)
+				return
+			}
+
+			for _, p := range permissions {
+				if !f.Can(user, p) {
+					f.authError(w, r, http.StatusForbidden, false)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireRole returns middleware that lets through users with any of roles, and answers others with
+// 403 Forbidden. Like RequirePermission, it goes after RequireAuth.
+func (f *Fenix) RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := auth.UserFrom(r.Context())
+			if user == nil {
+				f.authError(w, r, http.StatusUnauthorized, false)
+				return
+			}
+
+			ok, err := auth.HasRole(user, roles...)
+			if err != nil {
+				f.Logger.WarnContext(r.Context(), "checking roles failed", "path", r.URL.Path, "error", err)
+			}
+			if !ok {
+				f.authError(w, r, http.StatusForbidden, false)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
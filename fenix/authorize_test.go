@@ -0,0 +1,48 @@
+package fenix
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wtran29/fenix/fenix/auth"
+)
+
+type authorizeTestUser struct {
+	roles       []string
+	permissions []string
+}
+
+func (u *authorizeTestUser) Roles() ([]string, error)       { return u.roles, nil }
+func (u *authorizeTestUser) Permissions() ([]string, error) { return u.permissions, nil }
+
+func TestFenix_RequirePermissionAndRole(t *testing.T) {
+	f := &Fenix{Logger: testFenix.Logger, Gate: &auth.Gate{}}
+	editor := &authorizeTestUser{roles: []string{"editor"}, permissions: []string{"posts.edit"}}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		name    string
+		handler http.Handler
+		user    interface{}
+		want    int
+	}{
+		{"permission", f.RequirePermission("posts.edit")(ok), editor, http.StatusOK},
+		{"missing permission", f.RequirePermission("posts.edit", "posts.delete")(ok), editor, http.StatusForbidden},
+		{"role", f.RequireRole("admin", "editor")(ok), editor, http.StatusOK},
+		{"missing role", f.RequireRole("admin")(ok), editor, http.StatusForbidden},
+		{"no user", f.RequirePermission("posts.edit")(ok), nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.user != nil {
+			r = r.WithContext(auth.WithUser(r.Context(), tt.user))
+		}
+		rr := httptest.NewRecorder()
+		tt.handler.ServeHTTP(rr, r)
+		if rr.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/gomodule/redigo/redis"
+)
+
+// Atomic is implemented by caches with counters and locks that are safe to share between every
+// process using the same backend, so callers need not reach into RedisCache.Conn for them
+type Atomic interface {
+	Locker
+	// Increment adds delta to the integer stored under key, treating a missing key as zero, and
+	// returns the result. The key keeps any ttl it already had.
+	Increment(ctx context.Context, key string, delta int64) (int64, error)
+	// Decrement subtracts delta, as Increment adds it
+	Decrement(ctx context.Context, key string, delta int64) (int64, error)
+	// SetNX stores value under key only if the key is not already there, and reports whether it did
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+}
+
+// ErrNotInteger is returned by Increment and Decrement when the key holds something other than an
+// integer
+var ErrNotInteger = errors.New("cache: value is not an integer")
+
+// Counters are stored as decimal strings, as Redis stores them, so GetContext returns "42"
+
+func (f *RedisCache) Increment(ctx context.Context, key string, delta int64) (int64, error) {
+	n, err := redis.Int64(f.do(ctx, "INCRBY", f.key(key), delta))
+	var rerr redis.Error
+	if errors.As(err, &rerr) {
+		return 0, ErrNotInteger
+	}
+	return n, err
+}
+
+func (f *RedisCache) Decrement(ctx context.Context, key string, delta int64) (int64, error) {
+	return f.Increment(ctx, key, -delta)
+}
+
+func (f *RedisCache) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	args := []interface{}{f.key(key), value, "NX"}
+	if ttl > 0 {
+		args = append(args, "PX", ttl.Milliseconds())
+	}
+
+	_, err := redis.String(f.do(ctx, "SET", args...))
+	if errors.Is(err, redis.ErrNil) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// badgerRetries bounds how often a transaction that lost a race with another writer is retried
+const badgerRetries = 100
+
+// badgerBackoff is the longest pause before a retry; each pause is a random part of it, so writers
+// that conflicted do not collide again straight away
+const badgerBackoff = 5 * time.Millisecond
+
+// badgerKeyLocks serialize the atomic operations on a key within this process, so they wait for each
+// other instead of conflicting; a key always hashes to the same lock
+var badgerKeyLocks [64]sync.Mutex
+
+// update runs fn in a read-write transaction on key, retrying it when another writer got there first
+func (bc *BadgerCache) update(ctx context.Context, key string, fn func(txn *badger.Txn) error) error {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	mu := &badgerKeyLocks[h.Sum32()%uint32(len(badgerKeyLocks))]
+	mu.Lock()
+	defer mu.Unlock()
+
+	for i := 0; ; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := bc.Conn.Update(fn)
+		if !errors.Is(err, badger.ErrConflict) || i == badgerRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(rand.Int63n(int64(badgerBackoff)))):
+		}
+	}
+}
+
+func (bc *BadgerCache) Increment(ctx context.Context, key string, delta int64) (int64, error) {
+	var n int64
+
+	err := bc.update(ctx, key, func(txn *badger.Txn) error {
+		n = 0
+		entry := badger.NewEntry([]byte(key), nil)
+
+		item, err := txn.Get([]byte(key))
+		switch {
+		case err == nil:
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if n, err = strconv.ParseInt(string(value), 10, 64); err != nil {
+				return ErrNotInteger
+			}
+			if expires := item.ExpiresAt(); expires > 0 {
+				entry.ExpiresAt = expires
+			}
+		case !errors.Is(err, badger.ErrKeyNotFound):
+			return err
+		}
+
+		n += delta
+		entry.Value = []byte(strconv.FormatInt(n, 10))
+		return txn.SetEntry(entry)
+	})
+	return n, err
+}
+
+func (bc *BadgerCache) Decrement(ctx context.Context, key string, delta int64) (int64, error) {
+	return bc.Increment(ctx, key, -delta)
+}
+
+func (bc *BadgerCache) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	var set bool
+
+	err := bc.update(ctx, key, func(txn *badger.Txn) error {
+		set = false
+
+		_, err := txn.Get([]byte(key))
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		entry := badger.NewEntry([]byte(key), value)
+		if ttl > 0 {
+			entry = entry.WithTTL(ttl)
+		}
+		set = true
+		return txn.SetEntry(entry)
+	})
+	return set, err
+}
+
+// Increment on a MemoryCache is only atomic within this process
+func (mc *MemoryCache) Increment(ctx context.Context, key string, delta int64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	var n int64
+	var ttl time.Duration
+
+	if entry, ok := mc.get(key); ok {
+		var s string
+		switch v := entry.value.(type) {
+		case []byte:
+			s = string(v)
+		case string:
+			s = v
+		default:
+			return 0, ErrNotInteger
+		}
+
+		var err error
+		if n, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return 0, ErrNotInteger
+		}
+		if !entry.expires.IsZero() {
+			// a ttl of zero would make the counter permanent
+			ttl = max(time.Until(entry.expires), time.Nanosecond)
+		}
+	}
+
+	n += delta
+	mc.set(key, []byte(strconv.FormatInt(n, 10)), ttl)
+	return n, nil
+}
+
+func (mc *MemoryCache) Decrement(ctx context.Context, key string, delta int64) (int64, error) {
+	return mc.Increment(ctx, key, -delta)
+}
+
+func (mc *MemoryCache) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if _, ok := mc.get(key); ok {
+		return false, nil
+	}
+	mc.set(key, append([]byte(nil), value...), ttl)
+	return true, nil
+}
+
+// atomic returns L2 if it is Atomic, so counters are shared with every instance, and L1 otherwise
+func (tc *TieredCache) atomic() Atomic {
+	if a, ok := tc.L2.(Atomic); ok {
+		return a
+	}
+	return tc.L1
+}
+
+// Increment updates the counter in L2 and drops it from L1 everywhere, so it is never read stale
+func (tc *TieredCache) Increment(ctx context.Context, key string, delta int64) (int64, error) {
+	n, err := tc.atomic().Increment(ctx, key, delta)
+	if err != nil {
+		return 0, err
+	}
+	return n, tc.invalidate(ctx, key)
+}
+
+func (tc *TieredCache) Decrement(ctx context.Context, key string, delta int64) (int64, error) {
+	return tc.Increment(ctx, key, -delta)
+}
+
+func (tc *TieredCache) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	set, err := tc.atomic().SetNX(ctx, key, value, ttl)
+	if err != nil || !set {
+		return set, err
+	}
+	return true, tc.invalidate(ctx, key)
+}
+
+// invalidate drops key from L1 here and on every other instance
+func (tc *TieredCache) invalidate(ctx context.Context, key string) error {
+	if _, ok := tc.L2.(Atomic); !ok {
+		return nil
+	}
+	_ = tc.L1.Remove(key)
+	return tc.publish(ctx, invalidateKey, key)
+}
@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func atomicCaches() map[string]Atomic {
+	return map[string]Atomic{
+		"redis":  &testRedisCache,
+		"badger": &testBadgerCache,
+		"memory": &testMemoryCache,
+	}
+}
+
+func TestAtomic_IncrementContention(t *testing.T) {
+	ctx := context.Background()
+
+	for name, c := range atomicCaches() {
+		_ = c.(Cache).RemoveContext(ctx, "atomic-counter")
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					if _, err := c.Increment(ctx, "atomic-counter", 2); err != nil {
+						t.Errorf("%s: %s", name, err)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		n, err := c.Decrement(ctx, "atomic-counter", 1)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if n != 399 {
+			t.Errorf("%s: expected 399, got %d", name, n)
+		}
+
+		// counters read back as the decimal string other services expect
+		raw, _ := c.(Cache).GetContext(ctx, "atomic-counter")
+		if string(raw) != "399" {
+			t.Errorf("%s: expected \"399\", got %q", name, raw)
+		}
+	}
+}
+
+func TestAtomic_NotInteger(t *testing.T) {
+	ctx := context.Background()
+
+	for name, c := range atomicCaches() {
+		_ = c.(Cache).SetContext(ctx, "atomic-text", []byte("hello"), 0)
+
+		if _, err := c.Increment(ctx, "atomic-text", 1); !errors.Is(err, ErrNotInteger) {
+			t.Errorf("%s: expected ErrNotInteger, got %v", name, err)
+		}
+	}
+}
+
+func TestAtomic_SetNXContention(t *testing.T) {
+	ctx := context.Background()
+
+	for name, c := range atomicCaches() {
+		_ = c.(Cache).RemoveContext(ctx, "atomic-setnx")
+
+		var wins int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ok, err := c.SetNX(ctx, "atomic-setnx", []byte("first"), time.Minute)
+				if err != nil {
+					t.Errorf("%s: %s", name, err)
+				}
+				if ok {
+					atomic.AddInt32(&wins, 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if wins != 1 {
+			t.Errorf("%s: expected exactly one SetNX to win, %d did", name, wins)
+		}
+	}
+}
+
+func TestAtomic_LockContention(t *testing.T) {
+	ctx := context.Background()
+
+	for name, c := range atomicCaches() {
+		var holders int32
+		tokens := make(chan string, 10)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				token, err := c.Lock(ctx, "atomic-lock", time.Minute)
+				if errors.Is(err, ErrLocked) {
+					return
+				}
+				if err != nil {
+					t.Errorf("%s: %s", name, err)
+					return
+				}
+				atomic.AddInt32(&holders, 1)
+				tokens <- token
+			}()
+		}
+		wg.Wait()
+		close(tokens)
+
+		if holders != 1 {
+			t.Fatalf("%s: expected one holder, got %d", name, holders)
+		}
+
+		// the wrong token does not release the lock
+		_ = c.Unlock(ctx, "atomic-lock", "not-the-token")
+		if _, err := c.Lock(ctx, "atomic-lock", time.Minute); !errors.Is(err, ErrLocked) {
+			t.Errorf("%s: expected the lock to still be held, got %v", name, err)
+		}
+
+		_ = c.Unlock(ctx, "atomic-lock", <-tokens)
+		token, err := c.Lock(ctx, "atomic-lock", time.Minute)
+		if err != nil {
+			t.Errorf("%s: expected the lock to be free after Unlock, got %v", name, err)
+		}
+		_ = c.Unlock(ctx, "atomic-lock", token)
+	}
+}
+
+func TestAtomic_LockExpiry(t *testing.T) {
+	ctx := context.Background()
+
+	for name, c := range atomicCaches() {
+		if _, err := c.Lock(ctx, "atomic-expiry", time.Second); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+	}
+
+	// miniredis only expires keys when told time has passed
+	testRedisServer.FastForward(2 * time.Second)
+	time.Sleep(1100 * time.Millisecond)
+
+	for name, c := range atomicCaches() {
+		token, err := c.Lock(ctx, "atomic-expiry", time.Second)
+		if err != nil {
+			t.Errorf("%s: expected the lock to have expired, got %v", name, err)
+			continue
+		}
+		_ = c.Unlock(ctx, "atomic-expiry", token)
+	}
+}
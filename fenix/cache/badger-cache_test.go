@@ -2,6 +2,8 @@ package cache
 
 import (
 	"testing"
+
+	"github.com/dgraph-io/badger/v3"
 )
 
 func TestBadgerCache_Exists(t *testing.T) {
@@ -96,6 +98,28 @@ func TestBadgerCache_Empty(t *testing.T) {
 	}
 }
 
+func TestBadgerCache_EmptyKeepsSessions(t *testing.T) {
+	err := testBadgerCache.Conn.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("fenix-session:token"), []byte("data"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = testBadgerCache.Remove("fenix-session:token") }()
+
+	if err := testBadgerCache.Empty(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = testBadgerCache.Conn.View(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte("fenix-session:token"))
+		return err
+	})
+	if err != nil {
+		t.Errorf("expected Empty to keep the session, got %v", err)
+	}
+}
+
 func TestBadgerCache_EmptyByMatch(t *testing.T) {
 	err := testBadgerCache.Set("alpha", "beta")
 	if err != nil {
@@ -1,14 +1,31 @@
 package cache
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"time"
 
 	"github.com/dgraph-io/badger/v3"
 )
 
+// sessionPrefix starts the keys of session.BadgerStore and session.BadgerIndex; sessions kept in the
+// same database are not the cache's to empty
+var sessionPrefix = []byte("fenix-session")
+
 type BadgerCache struct {
 	Conn   *badger.DB
 	Prefix string
+	// OnLookup, if set, is called after every Get with whether the key was found
+	OnLookup func(hit bool)
+	// Codec encodes values for Set and the typed helpers; nil means GobCodec
+	Codec Codec
+	// CompressAbove, if greater than zero, gzips encoded values of at least that many bytes
+	CompressAbove int
+}
+
+func (bc *BadgerCache) serializer() serializer {
+	return serializer{codec: bc.Codec, compressAbove: bc.CompressAbove}
 }
 
 func (bc *BadgerCache) Exists(str string) (bool, error) {
@@ -38,24 +55,16 @@ func (bc *BadgerCache) Get(str string) (interface{}, error) {
 		return nil
 	})
 
+	recordLookup(bc.OnLookup, err == nil)
 	if err != nil {
 		return nil, err
 	}
 
-	decoded, err := decode(string(fromCache))
-	if err != nil {
-		return nil, err
-	}
-
-	item := decoded[str]
-
-	return item, nil
+	return bc.serializer().decodeAny(str, fromCache)
 }
 
 func (bc *BadgerCache) Set(str string, val interface{}, expiry ...int) error {
-	entry := Entry{}
-	entry[str] = val
-	encoded, err := encode(entry)
+	encoded, err := bc.serializer().encodeAny(str, val)
 	if err != nil {
 		return err
 	}
@@ -89,6 +98,7 @@ func (bc *BadgerCache) EmptyByMatch(str string) error {
 	return bc.emptyKeysHelper(str)
 }
 
+// Empty removes every key but those of the sessions sharing the database
 func (bc *BadgerCache) Empty() error {
 
 	return bc.emptyKeysHelper("")
@@ -109,6 +119,9 @@ func (bc *BadgerCache) emptyKeysHelper(str string) error {
 
 		for iter.Seek([]byte(str)); iter.ValidForPrefix([]byte(str)); iter.Next() {
 			k := iter.Item().KeyCopy(nil)
+			if bytes.HasPrefix(k, sessionPrefix) {
+				continue
+			}
 			batch.Delete(k)
 			keysCollected++
 
@@ -137,3 +150,132 @@ func (bc *BadgerCache) emptyKeysHelper(str string) error {
 
 	return nil
 }
+
+// badger does not block on the network, so the context is only checked before each operation
+
+func (bc *BadgerCache) ExistsContext(ctx context.Context, str string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	err := bc.Conn.View(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte(str))
+		return err
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (bc *BadgerCache) GetContext(ctx context.Context, str string) ([]byte, error) {
+	found, err := bc.GetMulti(ctx, str)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := found[str]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	return value, nil
+}
+
+func (bc *BadgerCache) SetContext(ctx context.Context, str string, value []byte, ttl time.Duration) error {
+	return bc.SetMulti(ctx, map[string][]byte{str: value}, ttl)
+}
+
+func (bc *BadgerCache) RemoveContext(ctx context.Context, str string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return bc.Remove(str)
+}
+
+// GetMulti reads every key in one transaction
+func (bc *BadgerCache) GetMulti(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	found := make(map[string][]byte, len(keys))
+
+	err := bc.Conn.View(func(txn *badger.Txn) error {
+		for _, k := range keys {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			item, err := txn.Get([]byte(k))
+			recordLookup(bc.OnLookup, err == nil)
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			found[k], err = item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+// SetMulti writes every item in one transaction
+func (bc *BadgerCache) SetMulti(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	return bc.Conn.Update(func(txn *badger.Txn) error {
+		for k, v := range items {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			entry := badger.NewEntry([]byte(k), v)
+			if ttl > 0 {
+				entry = entry.WithTTL(ttl)
+			}
+			if err := txn.SetEntry(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// TTL reads the remaining lifetime of every key in one transaction
+func (bc *BadgerCache) TTL(ctx context.Context, keys ...string) (map[string]time.Duration, error) {
+	ttls := make(map[string]time.Duration, len(keys))
+
+	err := bc.Conn.View(func(txn *badger.Txn) error {
+		for _, k := range keys {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			item, err := txn.Get([]byte(k))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			// ExpiresAt is in unix seconds, and zero for a key that never expires
+			expires := item.ExpiresAt()
+			if expires == 0 {
+				ttls[k] = 0
+			} else if left := time.Until(time.Unix(int64(expires), 0)); left > 0 {
+				ttls[k] = left
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ttls, nil
+}
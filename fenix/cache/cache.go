@@ -2,8 +2,11 @@ package cache
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/gomodule/redigo/redis"
 )
@@ -15,11 +18,19 @@ type Cache interface {
 	Remove(string) error
 	EmptyByMatch(string) error
 	Empty() error
+	ContextCache
+	TaggedCache
 }
 
 type RedisCache struct {
 	Conn   *redis.Pool
 	Prefix string // used to prefix keys with something unique in case they share the same ids
+	// OnLookup, if set, is called after every Get with whether the key was found
+	OnLookup func(hit bool)
+	// Codec encodes values for Set and the typed helpers; nil means GobCodec
+	Codec Codec
+	// CompressAbove, if greater than zero, gzips encoded values of at least that many bytes
+	CompressAbove int
 }
 
 type Entry map[string]interface{}
@@ -37,6 +48,13 @@ func (f *RedisCache) Exists(str string) (bool, error) {
 	return ok, nil
 }
 
+// recordLookup calls the OnLookup hook of a cache, if it has one
+func recordLookup(onLookup func(hit bool), hit bool) {
+	if onLookup != nil {
+		onLookup(hit)
+	}
+}
+
 func encode(item Entry) ([]byte, error) {
 	b := bytes.Buffer{}
 	e := gob.NewEncoder(&b)
@@ -65,18 +83,12 @@ func (f *RedisCache) Get(str string) (interface{}, error) {
 	defer conn.Close()
 
 	cacheEntry, err := redis.Bytes(conn.Do("GET", key))
+	recordLookup(f.OnLookup, err == nil)
 	if err != nil {
 		return nil, err
 	}
 
-	decoded, err := decode(string(cacheEntry))
-	if err != nil {
-		return nil, err
-	}
-
-	item := decoded[key]
-
-	return item, nil
+	return f.serializer().decodeAny(key, cacheEntry)
 }
 
 func (f *RedisCache) Set(str string, val interface{}, expiry ...int) error {
@@ -84,9 +96,7 @@ func (f *RedisCache) Set(str string, val interface{}, expiry ...int) error {
 	conn := f.Conn.Get()
 	defer conn.Close()
 
-	entry := Entry{}
-	entry[key] = val
-	encoded, err := encode(entry)
+	encoded, err := f.serializer().encodeAny(key, val)
 	if err != nil {
 		return err
 	}
@@ -180,3 +190,143 @@ func (f *RedisCache) getKeys(pattern string) ([]string, error) {
 
 	return keys, nil
 }
+
+func (f *RedisCache) serializer() serializer {
+	return serializer{codec: f.Codec, compressAbove: f.CompressAbove}
+}
+
+func (f *RedisCache) key(str string) string {
+	return fmt.Sprintf("%s:%s", f.Prefix, str)
+}
+
+// do runs a single command on a pooled connection, giving up when ctx is done
+func (f *RedisCache) do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	conn, err := f.Conn.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return redis.DoContext(conn, ctx, cmd, args...)
+}
+
+func (f *RedisCache) ExistsContext(ctx context.Context, str string) (bool, error) {
+	return redis.Bool(f.do(ctx, "EXISTS", f.key(str)))
+}
+
+func (f *RedisCache) GetContext(ctx context.Context, str string) ([]byte, error) {
+	value, err := redis.Bytes(f.do(ctx, "GET", f.key(str)))
+	recordLookup(f.OnLookup, err == nil)
+	if errors.Is(err, redis.ErrNil) {
+		return nil, ErrCacheMiss
+	}
+	return value, err
+}
+
+func (f *RedisCache) SetContext(ctx context.Context, str string, value []byte, ttl time.Duration) error {
+	args := []interface{}{f.key(str), value}
+	if ttl > 0 {
+		args = append(args, "PX", ttl.Milliseconds())
+	}
+	_, err := f.do(ctx, "SET", args...)
+	return err
+}
+
+func (f *RedisCache) RemoveContext(ctx context.Context, str string) error {
+	_, err := f.do(ctx, "DEL", f.key(str))
+	return err
+}
+
+// GetMulti fetches every key with a single MGET
+func (f *RedisCache) GetMulti(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	found := make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return found, nil
+	}
+
+	args := make([]interface{}, len(keys))
+	for i, k := range keys {
+		args[i] = f.key(k)
+	}
+
+	values, err := redis.ByteSlices(f.do(ctx, "MGET", args...))
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range values {
+		recordLookup(f.OnLookup, v != nil)
+		if v != nil {
+			found[keys[i]] = v
+		}
+	}
+	return found, nil
+}
+
+// SetMulti writes every item in one MULTI/EXEC transaction
+func (f *RedisCache) SetMulti(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	conn, err := f.Conn.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.Send("MULTI"); err != nil {
+		return err
+	}
+	for k, v := range items {
+		args := []interface{}{f.key(k), v}
+		if ttl > 0 {
+			args = append(args, "PX", ttl.Milliseconds())
+		}
+		if err := conn.Send("SET", args...); err != nil {
+			return err
+		}
+	}
+
+	_, err = redis.DoContext(conn, ctx, "EXEC")
+	return err
+}
+
+// TTL reads the remaining lifetime of every key in one pipelined round trip
+func (f *RedisCache) TTL(ctx context.Context, keys ...string) (map[string]time.Duration, error) {
+	ttls := make(map[string]time.Duration, len(keys))
+	if len(keys) == 0 {
+		return ttls, nil
+	}
+
+	conn, err := f.Conn.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	for _, k := range keys {
+		if err := conn.Send("PTTL", f.key(k)); err != nil {
+			return nil, err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+
+	for _, k := range keys {
+		ms, err := redis.Int64(redis.ReceiveContext(conn, ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		// -2 means the key is not there, -1 that it never expires
+		switch {
+		case ms == -1:
+			ttls[k] = 0
+		case ms > 0:
+			ttls[k] = time.Duration(ms) * time.Millisecond
+		}
+	}
+	return ttls, nil
+}
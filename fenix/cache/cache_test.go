@@ -154,3 +154,28 @@ func TestEncodeDecode(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestRedisCache_OnLookup(t *testing.T) {
+	var hits, misses int
+	testRedisCache.OnLookup = func(hit bool) {
+		if hit {
+			hits++
+		} else {
+			misses++
+		}
+	}
+	defer func() { testRedisCache.OnLookup = nil }()
+
+	_ = testRedisCache.Remove("foo")
+	_, _ = testRedisCache.Get("foo")
+
+	err := testRedisCache.Set("foo", "bar")
+	if err != nil {
+		t.Error(err)
+	}
+	_, _ = testRedisCache.Get("foo")
+
+	if hits != 1 || misses != 1 {
+		t.Errorf("expected 1 hit and 1 miss, got %d and %d", hits, misses)
+	}
+}
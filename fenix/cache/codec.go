@@ -0,0 +1,235 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec turns cached values into bytes and back. Its ID is stored with every entry, so a cache reads
+// entries with the codec that wrote them, whatever codec it is set to write with. IDs up to 15 are
+// reserved for Fenix; register any other codec with RegisterCodec.
+type Codec interface {
+	ID() byte
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// GobCodec is the default codec. Values stored through the untyped Set must be gob.Register'ed.
+type GobCodec struct{}
+
+func (GobCodec) ID() byte { return 1 }
+
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	b := bytes.Buffer{}
+	err := gob.NewEncoder(&b).Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// JSONCodec stores plain JSON, readable and writable by services in any language, unless the value
+// is compressed
+type JSONCodec struct{}
+
+func (JSONCodec) ID() byte { return 2 }
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// MsgpackCodec is a compact binary codec with libraries for most languages
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) ID() byte { return 3 }
+
+func (MsgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[byte]Codec{
+		GobCodec{}.ID():     GobCodec{},
+		JSONCodec{}.ID():    JSONCodec{},
+		MsgpackCodec{}.ID(): MsgpackCodec{},
+	}
+)
+
+// RegisterCodec lets every cache read entries written with c. It panics if the ID is reserved, taken
+// by another codec, or does not fit in the 7 bits stored with an entry.
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	id := c.ID()
+	switch {
+	case id <= 15:
+		panic(fmt.Sprintf("cache: codec id %d is reserved", id))
+	case id&compressedFlag != 0:
+		panic(fmt.Sprintf("cache: codec id %d does not fit in 7 bits", id))
+	case codecs[id] != nil:
+		panic(fmt.Sprintf("cache: codec id %d is already registered", id))
+	}
+	codecs[id] = c
+}
+
+func lookupCodec(id byte) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	c, ok := codecs[id]
+	return c, ok
+}
+
+// Every entry but uncompressed JSON starts with entryMagic and a byte holding the codec id, with
+// compressedFlag set if the rest is gzipped. Uncompressed JSON is stored bare so that other services
+// can share it; it can never start with entryMagic, which is not valid UTF-8. Entries with neither,
+// written before codecs existed, are gob.
+const (
+	entryMagic     byte = 0xfc
+	compressedFlag byte = 0x80
+)
+
+// serializer encodes entries for a cache with the given codec, compressing those of at least
+// compressAbove bytes when compressAbove is greater than zero
+type serializer struct {
+	codec         Codec
+	compressAbove int
+}
+
+// serialized is implemented by caches with a configurable codec; the others use gob
+type serialized interface {
+	serializer() serializer
+}
+
+func serializerFor(c any) serializer {
+	if s, ok := c.(serialized); ok {
+		return s.serializer()
+	}
+	return serializer{}
+}
+
+func (s serializer) writeCodec() Codec {
+	if s.codec == nil {
+		return GobCodec{}
+	}
+	return s.codec
+}
+
+func (s serializer) encode(v any) ([]byte, error) {
+	codec := s.writeCodec()
+
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	compress := s.compressAbove > 0 && len(data) >= s.compressAbove
+	if !compress && codec.ID() == (JSONCodec{}).ID() {
+		return data, nil
+	}
+
+	header := []byte{entryMagic, codec.ID()}
+	if !compress {
+		return append(header, data...), nil
+	}
+
+	header[1] |= compressedFlag
+	b := bytes.NewBuffer(header)
+	zw := gzip.NewWriter(b)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// open returns the codec an entry was written with and its uncompressed body
+func (s serializer) open(entry []byte) (Codec, []byte, error) {
+	if len(entry) < 2 || entry[0] != entryMagic {
+		if json.Valid(entry) {
+			return JSONCodec{}, entry, nil
+		}
+		return GobCodec{}, entry, nil
+	}
+
+	id := entry[1] &^ compressedFlag
+	codec, ok := lookupCodec(id)
+	if !ok {
+		return nil, nil, fmt.Errorf("cache: entry written with unknown codec %d", id)
+	}
+
+	data := entry[2:]
+	if entry[1]&compressedFlag != 0 {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		defer zr.Close()
+
+		data, err = io.ReadAll(zr)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return codec, data, nil
+}
+
+func (s serializer) decode(entry []byte, v any) error {
+	codec, data, err := s.open(entry)
+	if err != nil {
+		return err
+	}
+	return codec.Unmarshal(data, v)
+}
+
+// encodeAny encodes a value stored through the untyped Set. Gob cannot decode into an interface{}
+// unless it was encoded as one, so gob values are wrapped in an Entry, as they always have been.
+func (s serializer) encodeAny(key string, val interface{}) ([]byte, error) {
+	if s.writeCodec().ID() == (GobCodec{}).ID() {
+		return s.encode(Entry{key: val})
+	}
+	return s.encode(val)
+}
+
+func (s serializer) decodeAny(key string, entry []byte) (interface{}, error) {
+	codec, data, err := s.open(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	if codec.ID() == (GobCodec{}).ID() {
+		item := Entry{}
+		if err := codec.Unmarshal(data, &item); err != nil {
+			return nil, err
+		}
+		return item[key], nil
+	}
+
+	var val interface{}
+	err = codec.Unmarshal(data, &val)
+	return val, err
+}
@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testCodecs() []Codec {
+	return []Codec{GobCodec{}, JSONCodec{}, MsgpackCodec{}}
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	for _, codec := range testCodecs() {
+		caches := map[string]Cache{
+			"redis":  &RedisCache{Conn: testRedisCache.Conn, Prefix: testRedisCache.Prefix, Codec: codec},
+			"badger": &BadgerCache{Conn: testBadgerCache.Conn, Codec: codec},
+		}
+
+		for name, c := range caches {
+			err := Set(ctx, c, "codec-user", testUser{ID: 3, Email: "me@here.com"}, time.Minute)
+			if err != nil {
+				t.Fatalf("%s/%T: %s", name, codec, err)
+			}
+
+			u, err := Get[testUser](ctx, c, "codec-user")
+			if err != nil {
+				t.Fatalf("%s/%T: %s", name, codec, err)
+			}
+			if u.ID != 3 || u.Email != "me@here.com" {
+				t.Errorf("%s/%T: unexpected value %+v", name, codec, u)
+			}
+
+			if err := c.Set("codec-legacy", "hello"); err != nil {
+				t.Fatalf("%s/%T: %s", name, codec, err)
+			}
+			v, err := c.Get("codec-legacy")
+			if err != nil {
+				t.Fatalf("%s/%T: %s", name, codec, err)
+			}
+			if v != "hello" {
+				t.Errorf("%s/%T: expected hello, got %v", name, codec, v)
+			}
+		}
+	}
+}
+
+func TestCodec_SwitchCodec(t *testing.T) {
+	ctx := context.Background()
+
+	// written before codecs existed, and with every codec, then read back with a different one
+	_ = testRedisCache.SetContext(ctx, "codec-old", mustGob(t, testUser{ID: 1}), 0)
+	for i, codec := range testCodecs() {
+		writer := &RedisCache{Conn: testRedisCache.Conn, Prefix: testRedisCache.Prefix, Codec: codec}
+		reader := &RedisCache{Conn: testRedisCache.Conn, Prefix: testRedisCache.Prefix, Codec: testCodecs()[(i+1)%3]}
+
+		if err := Set(ctx, writer, "codec-switch", testUser{ID: 2}, 0); err != nil {
+			t.Fatal(err)
+		}
+		u, err := Get[testUser](ctx, reader, "codec-switch")
+		if err != nil || u.ID != 2 {
+			t.Errorf("%T then %T: got %+v, %v", writer.Codec, reader.Codec, u, err)
+		}
+
+		u, err = Get[testUser](ctx, reader, "codec-old")
+		if err != nil || u.ID != 1 {
+			t.Errorf("old entry with %T: got %+v, %v", reader.Codec, u, err)
+		}
+	}
+}
+
+func TestCodec_JSONIsBare(t *testing.T) {
+	ctx := context.Background()
+	c := &RedisCache{Conn: testRedisCache.Conn, Prefix: testRedisCache.Prefix, Codec: JSONCodec{}}
+
+	_ = Set(ctx, c, "codec-json", map[string]int{"id": 5}, 0)
+	raw, err := c.GetContext(ctx, "codec-json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(raw) {
+		t.Errorf("expected bare JSON, got %q", raw)
+	}
+
+	// as written by a service in another language
+	_ = c.SetContext(ctx, "codec-foreign", []byte(`{"ID":9,"Email":"x@y.z"}`), 0)
+	u, err := Get[testUser](ctx, &testRedisCache, "codec-foreign")
+	if err != nil || u.ID != 9 {
+		t.Errorf("got %+v, %v", u, err)
+	}
+}
+
+func TestCodec_Compression(t *testing.T) {
+	ctx := context.Background()
+	c := &BadgerCache{Conn: testBadgerCache.Conn, Codec: JSONCodec{}, CompressAbove: 100}
+
+	long := strings.Repeat("fenix ", 100)
+	if err := Set(ctx, c, "codec-compressed", long, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := c.GetContext(ctx, "codec-compressed")
+	if raw[0] != entryMagic || raw[1]&compressedFlag == 0 || len(raw) >= len(long) {
+		t.Errorf("expected a compressed entry of less than %d bytes, got %d", len(long), len(raw))
+	}
+
+	// readable without compression configured
+	v, err := Get[string](ctx, &testBadgerCache, "codec-compressed")
+	if err != nil || v != long {
+		t.Errorf("unexpected value, err %v", err)
+	}
+}
+
+func TestCodec_Unknown(t *testing.T) {
+	ctx := context.Background()
+
+	_ = testRedisCache.SetContext(ctx, "codec-unknown", []byte{entryMagic, 99, 1, 2, 3}, 0)
+	if _, err := Get[int](ctx, &testRedisCache, "codec-unknown"); err == nil {
+		t.Error("expected an error for an unknown codec")
+	}
+}
+
+func mustGob(t *testing.T, v any) []byte {
+	t.Helper()
+	b, err := GobCodec{}.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
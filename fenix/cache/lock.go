@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrLocked is returned by Lock when the lock is already held
+var ErrLocked = errors.New("cache: lock is held")
+
+// Locker is implemented by caches that can hold a lock shared by every process using the same
+// backend. A lock expires after its ttl, so a crashed holder cannot keep it forever; a ttl of zero
+// means it is held until Unlock.
+type Locker interface {
+	// Lock takes the lock named key without waiting, returning ErrLocked if someone else holds it.
+	// The returned token must be passed to Unlock.
+	Lock(ctx context.Context, key string, ttl time.Duration) (string, error)
+	// Unlock releases the lock if token still holds it; releasing an expired or taken over lock is
+	// not an error
+	Unlock(ctx context.Context, key, token string) error
+}
+
+const lockPrefix = "fenix-lock:"
+
+// unlockScript deletes KEYS[1] only if it still holds the token in ARGV[1]
+var unlockScript = redis.NewScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func (f *RedisCache) Lock(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	token := randomID()
+
+	args := []interface{}{f.key(lockPrefix + key), token, "NX"}
+	if ttl > 0 {
+		args = append(args, "PX", ttl.Milliseconds())
+	}
+
+	_, err := redis.String(f.do(ctx, "SET", args...))
+	if errors.Is(err, redis.ErrNil) {
+		return "", ErrLocked
+	}
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (f *RedisCache) Unlock(ctx context.Context, key, token string) error {
+	conn, err := f.Conn.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = unlockScript.DoContext(ctx, conn, f.key(lockPrefix+key), token)
+	return err
+}
+
+func (bc *BadgerCache) Lock(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	token := randomID()
+	err := bc.Conn.Update(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte(lockPrefix + key))
+		if err == nil {
+			return ErrLocked
+		}
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		entry := badger.NewEntry([]byte(lockPrefix+key), []byte(token))
+		if ttl > 0 {
+			entry = entry.WithTTL(ttl)
+		}
+		return txn.SetEntry(entry)
+	})
+	// two processes racing for a free lock conflict on commit; the loser simply does not get it
+	if errors.Is(err, badger.ErrConflict) {
+		return "", ErrLocked
+	}
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (bc *BadgerCache) Unlock(ctx context.Context, key, token string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := bc.Conn.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(lockPrefix + key))
+		if err != nil {
+			return err
+		}
+
+		held, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(held, []byte(token)) {
+			return nil
+		}
+		return txn.Delete([]byte(lockPrefix + key))
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil
+	}
+	return err
+}
+
+// memoryLocks holds the locks of a MemoryCache apart from its entries, so they are never evicted
+type memoryLocks struct {
+	mu    sync.Mutex
+	locks map[string]memoryLock
+}
+
+type memoryLock struct {
+	token   string
+	expires time.Time
+}
+
+// Lock takes a lock that is only shared within this process
+func (mc *MemoryCache) Lock(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	mc.locks.mu.Lock()
+	defer mc.locks.mu.Unlock()
+
+	if mc.locks.locks == nil {
+		mc.locks.locks = make(map[string]memoryLock)
+	}
+
+	if held, ok := mc.locks.locks[key]; ok && (held.expires.IsZero() || time.Now().Before(held.expires)) {
+		return "", ErrLocked
+	}
+
+	lock := memoryLock{token: randomID()}
+	if ttl > 0 {
+		lock.expires = time.Now().Add(ttl)
+	}
+	mc.locks.locks[key] = lock
+	return lock.token, nil
+}
+
+func (mc *MemoryCache) Unlock(ctx context.Context, key, token string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	mc.locks.mu.Lock()
+	defer mc.locks.mu.Unlock()
+
+	if held, ok := mc.locks.locks[key]; ok && held.token == token {
+		delete(mc.locks.locks, key)
+	}
+	return nil
+}
+
+// locker returns L2 if it can lock, so that locks are shared with every instance, and L1 otherwise
+func (tc *TieredCache) locker() Locker {
+	if l, ok := tc.L2.(Locker); ok {
+		return l
+	}
+	return tc.L1
+}
+
+func (tc *TieredCache) Lock(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return tc.locker().Lock(ctx, key, ttl)
+}
+
+func (tc *TieredCache) Unlock(ctx context.Context, key, token string) error {
+	return tc.locker().Unlock(ctx, key, token)
+}
@@ -0,0 +1,309 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryCache is an in-process cache with least recently used eviction. The zero value is an
+// unbounded cache; set MaxEntries and/or MaxBytes to bound it. Expired entries are removed when they
+// are next read, or when they reach the end of the LRU list.
+type MemoryCache struct {
+	// MaxEntries is the most entries kept, or 0 for no limit
+	MaxEntries int
+	// MaxBytes bounds the size of keys plus []byte and string values, or 0 for no limit
+	MaxBytes int64
+	// OnLookup, if set, is called after every Get with whether the key was found
+	OnLookup func(hit bool)
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	tags  map[string]map[string]struct{}
+	size  int64
+	locks memoryLocks
+}
+
+type memoryEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+	size    int64
+	tags    []string
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+func (mc *MemoryCache) init() {
+	if mc.items == nil {
+		mc.ll = list.New()
+		mc.items = make(map[string]*list.Element)
+		mc.tags = make(map[string]map[string]struct{})
+	}
+}
+
+// get returns the live entry for key and marks it as recently used; mc.mu must be held
+func (mc *MemoryCache) get(key string) (*memoryEntry, bool) {
+	mc.init()
+
+	el, ok := mc.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*memoryEntry)
+	if entry.expired(time.Now()) {
+		mc.removeElement(el)
+		return nil, false
+	}
+
+	mc.ll.MoveToFront(el)
+	return entry, true
+}
+
+// set stores value under key and evicts entries until the cache is within bounds; mc.mu must be held
+func (mc *MemoryCache) set(key string, value interface{}, ttl time.Duration, tags ...string) {
+	mc.init()
+
+	entry := &memoryEntry{
+		key:   key,
+		value: value,
+		size:  entrySize(key, value),
+		tags:  tags,
+	}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+
+	if el, ok := mc.items[key]; ok {
+		mc.untag(el.Value.(*memoryEntry))
+		mc.size -= el.Value.(*memoryEntry).size
+		el.Value = entry
+		mc.ll.MoveToFront(el)
+	} else {
+		mc.items[key] = mc.ll.PushFront(entry)
+	}
+	mc.size += entry.size
+	for _, tag := range tags {
+		if mc.tags[tag] == nil {
+			mc.tags[tag] = make(map[string]struct{})
+		}
+		mc.tags[tag][key] = struct{}{}
+	}
+
+	for mc.ll.Len() > 1 && ((mc.MaxEntries > 0 && mc.ll.Len() > mc.MaxEntries) || (mc.MaxBytes > 0 && mc.size > mc.MaxBytes)) {
+		mc.removeElement(mc.ll.Back())
+	}
+}
+
+func (mc *MemoryCache) removeElement(el *list.Element) {
+	entry := el.Value.(*memoryEntry)
+	mc.ll.Remove(el)
+	delete(mc.items, entry.key)
+	mc.size -= entry.size
+	mc.untag(entry)
+}
+
+// untag drops entry from the tags it was stored under; mc.mu must be held
+func (mc *MemoryCache) untag(entry *memoryEntry) {
+	for _, tag := range entry.tags {
+		delete(mc.tags[tag], entry.key)
+		if len(mc.tags[tag]) == 0 {
+			delete(mc.tags, tag)
+		}
+	}
+}
+
+func entrySize(key string, value interface{}) int64 {
+	size := int64(len(key))
+	switch v := value.(type) {
+	case []byte:
+		size += int64(len(v))
+	case string:
+		size += int64(len(v))
+	}
+	return size
+}
+
+// Len returns the number of entries in the cache, including any that have expired but not yet been removed
+func (mc *MemoryCache) Len() int {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.init()
+	return mc.ll.Len()
+}
+
+func (mc *MemoryCache) Exists(str string) (bool, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	_, ok := mc.get(str)
+	return ok, nil
+}
+
+func (mc *MemoryCache) Get(str string) (interface{}, error) {
+	mc.mu.Lock()
+	entry, ok := mc.get(str)
+	mc.mu.Unlock()
+
+	recordLookup(mc.OnLookup, ok)
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	return entry.value, nil
+}
+
+// Set stores val; the optional expiry is in seconds, as for the other caches
+func (mc *MemoryCache) Set(str string, val interface{}, expiry ...int) error {
+	var ttl time.Duration
+	if len(expiry) > 0 {
+		ttl = time.Duration(expiry[0]) * time.Second
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.set(str, val, ttl)
+	return nil
+}
+
+func (mc *MemoryCache) Remove(str string) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.init()
+	if el, ok := mc.items[str]; ok {
+		mc.removeElement(el)
+	}
+	return nil
+}
+
+// EmptyByMatch removes every key starting with str
+func (mc *MemoryCache) EmptyByMatch(str string) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.init()
+	for key, el := range mc.items {
+		if strings.HasPrefix(key, str) {
+			mc.removeElement(el)
+		}
+	}
+	return nil
+}
+
+func (mc *MemoryCache) Empty() error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.ll = list.New()
+	mc.items = make(map[string]*list.Element)
+	mc.tags = make(map[string]map[string]struct{})
+	mc.size = 0
+	return nil
+}
+
+func (mc *MemoryCache) ExistsContext(ctx context.Context, str string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return mc.Exists(str)
+}
+
+func (mc *MemoryCache) GetContext(ctx context.Context, str string) ([]byte, error) {
+	found, err := mc.GetMulti(ctx, str)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := found[str]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	return value, nil
+}
+
+func (mc *MemoryCache) SetContext(ctx context.Context, str string, value []byte, ttl time.Duration) error {
+	return mc.SetMulti(ctx, map[string][]byte{str: value}, ttl)
+}
+
+func (mc *MemoryCache) RemoveContext(ctx context.Context, str string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return mc.Remove(str)
+}
+
+// errNotBytes is returned by the context aware methods for a value stored by Set that is not a []byte
+var errNotBytes = errors.New("cache: value was not stored as bytes")
+
+func (mc *MemoryCache) GetMulti(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	found := make(map[string][]byte, len(keys))
+	for _, k := range keys {
+		entry, ok := mc.get(k)
+		recordLookup(mc.OnLookup, ok)
+		if !ok {
+			continue
+		}
+
+		b, ok := entry.value.([]byte)
+		if !ok {
+			return nil, errNotBytes
+		}
+		found[k] = b
+	}
+	return found, nil
+}
+
+func (mc *MemoryCache) SetMulti(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	for k, v := range items {
+		// keep our own copy, so the caller can reuse its buffer
+		mc.set(k, append([]byte(nil), v...), ttl)
+	}
+	return nil
+}
+
+func (mc *MemoryCache) TTL(ctx context.Context, keys ...string) (map[string]time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	ttls := make(map[string]time.Duration, len(keys))
+	for _, k := range keys {
+		entry, ok := mc.get(k)
+		if !ok {
+			continue
+		}
+
+		if entry.expires.IsZero() {
+			ttls[k] = 0
+		} else if left := time.Until(entry.expires); left > 0 {
+			ttls[k] = left
+		}
+	}
+	return ttls, nil
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache_LRU(t *testing.T) {
+	mc := MemoryCache{MaxEntries: 2}
+
+	_ = mc.Set("a", "1")
+	_ = mc.Set("b", "2")
+
+	// reading a makes b the least recently used
+	if _, err := mc.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+	_ = mc.Set("c", "3")
+
+	if ok, _ := mc.Exists("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	for _, k := range []string{"a", "c"} {
+		if ok, _ := mc.Exists(k); !ok {
+			t.Errorf("expected %s to be kept", k)
+		}
+	}
+	if mc.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", mc.Len())
+	}
+}
+
+func TestMemoryCache_MaxBytes(t *testing.T) {
+	mc := MemoryCache{MaxBytes: 10}
+
+	_ = mc.Set("a", "1234")
+	_ = mc.Set("b", "1234")
+	_ = mc.Set("c", "1234")
+
+	if ok, _ := mc.Exists("a"); ok {
+		t.Error("expected a to be evicted")
+	}
+	if mc.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", mc.Len())
+	}
+}
+
+func TestMemoryCache_Expiry(t *testing.T) {
+	var mc MemoryCache
+
+	_ = mc.Set("short", "value", 1)
+	_ = mc.Set("forever", "value")
+
+	time.Sleep(1100 * time.Millisecond)
+
+	if _, err := mc.Get("short"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("expected an expired key to miss, got %v", err)
+	}
+	if _, err := mc.Get("forever"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMemoryCache_EmptyByMatch(t *testing.T) {
+	var mc MemoryCache
+
+	_ = mc.Set("alpha", "1")
+	_ = mc.Set("alpha2", "2")
+	_ = mc.Set("beta", "3")
+
+	_ = mc.EmptyByMatch("alpha")
+
+	if mc.Len() != 1 {
+		t.Errorf("expected 1 entry, got %d", mc.Len())
+	}
+	if ok, _ := mc.Exists("beta"); !ok {
+		t.Error("expected beta to be kept")
+	}
+
+	_ = mc.Empty()
+	if mc.Len() != 0 {
+		t.Error("expected an empty cache")
+	}
+}
@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Policy tunes how RememberWithPolicy refreshes a value, so that a hot key expiring does not send
+// every concurrent request to the loader at once
+type Policy struct {
+	// TTL is how long a loaded value is fresh; zero means it never goes stale
+	TTL time.Duration
+	// Stale is how long past TTL the old value is still served while a single caller reloads it in
+	// the background
+	Stale time.Duration
+	// Beta turns on early probabilistic expiration: a fresh value is reloaded in the background before
+	// TTL, the more likely the closer it is to going stale and the slower it was to load. 1 is a good
+	// start; zero turns it off.
+	Beta float64
+	// LockTTL bounds a reload. On a miss, the caller holding the reload lock loads the value while the
+	// others wait up to LockTTL for it before loading it themselves. Zero means 10 seconds.
+	LockTTL time.Duration
+	// OnError, if set, is called with the errors of background reloads, which have no caller to
+	// return them to
+	OnError func(key string, err error)
+}
+
+func (p Policy) lockTTL() time.Duration {
+	if p.LockTTL <= 0 {
+		return 10 * time.Second
+	}
+	return p.LockTTL
+}
+
+// lockWaitInterval is how often a caller waiting on another's reload checks for the new value
+const lockWaitInterval = 50 * time.Millisecond
+
+// policyEntry is what RememberWithPolicy stores: the value, when it goes stale and how long it took
+// to load, in nanoseconds
+type policyEntry[T any] struct {
+	Value      T     `json:"value" msgpack:"value"`
+	FreshUntil int64 `json:"fresh_until" msgpack:"fresh_until"`
+	Delta      int64 `json:"delta" msgpack:"delta"`
+}
+
+// needsRefresh reports whether a value should be reloaded: once it is stale, or, with Beta set, at
+// a random point shortly before (the XFetch algorithm)
+func (p Policy) needsRefresh(freshUntil, delta int64, now time.Time) bool {
+	if p.TTL <= 0 {
+		return false
+	}
+	if now.UnixNano() >= freshUntil {
+		return true
+	}
+	if p.Beta <= 0 {
+		return false
+	}
+
+	early := float64(delta) * p.Beta * -math.Log(1-rand.Float64())
+	return float64(now.UnixNano())+early >= float64(freshUntil)
+}
+
+// RememberWithPolicy works like Remember, but the value is kept for Stale past its TTL. A caller
+// that finds a stale value, or one about to go stale when Beta is set, gets it straight away
+// while the value is reloaded in the background. Only one caller reloads at a time: within a
+// process, and across processes if c is a Locker.
+func RememberWithPolicy[T any](ctx context.Context, c ContextCache, key string, p Policy, loader func(ctx context.Context) (T, error)) (T, error) {
+	var entry policyEntry[T]
+
+	b, err := c.GetContext(ctx, key)
+	switch {
+	case err == nil:
+		if serializerFor(c).decode(b, &entry) == nil {
+			if p.needsRefresh(entry.FreshUntil, entry.Delta, time.Now()) {
+				refreshInBackground(ctx, c, key, p, loader)
+			}
+			return entry.Value, nil
+		}
+		// an entry we cannot read is replaced, as if it were missing
+	case !errors.Is(err, ErrCacheMiss):
+		return entry.Value, err
+	}
+
+	flightKey := fmt.Sprintf("policy:%p:%s", c, key)
+	v, err, _ := rememberGroup.Do(flightKey, func() (interface{}, error) {
+		return loadLocked(ctx, c, key, p, loader, true)
+	})
+	if err != nil {
+		return entry.Value, err
+	}
+
+	value, _ := v.(T)
+	return value, nil
+}
+
+// refreshInBackground reloads key unless a reload is already running in this process
+func refreshInBackground[T any](ctx context.Context, c ContextCache, key string, p Policy, loader func(ctx context.Context) (T, error)) {
+	// the reload outlives the request that noticed the value was stale
+	ctx = context.WithoutCancel(ctx)
+
+	flightKey := fmt.Sprintf("policy-refresh:%p:%s", c, key)
+	rememberGroup.DoChan(flightKey, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(ctx, p.lockTTL())
+		defer cancel()
+
+		_, err := loadLocked(ctx, c, key, p, loader, false)
+		if err != nil && p.OnError != nil {
+			p.OnError(key, err)
+		}
+		return nil, err
+	})
+}
+
+// loadLocked loads and stores the value under the reload lock of key. If another process holds
+// the lock, a caller that needs the value waits for it; a background refresh just gives up.
+func loadLocked[T any](ctx context.Context, c ContextCache, key string, p Policy, loader func(ctx context.Context) (T, error), wait bool) (T, error) {
+	var zero T
+
+	if locker, ok := c.(Locker); ok {
+		token, err := locker.Lock(ctx, key, p.lockTTL())
+		switch {
+		case err == nil:
+			defer func() { _ = locker.Unlock(context.WithoutCancel(ctx), key, token) }()
+		case errors.Is(err, ErrLocked):
+			if !wait {
+				return zero, nil
+			}
+			if value, ok := waitForReload[T](ctx, c, key, p); ok {
+				return value, nil
+			}
+			if err := ctx.Err(); err != nil {
+				return zero, err
+			}
+			// the holder took too long or failed, so load it ourselves
+		default:
+			// a lock we cannot take must not stop the value being loaded
+		}
+	}
+
+	start := time.Now()
+	value, err := loader(ctx)
+	if err != nil {
+		return zero, err
+	}
+
+	entry := policyEntry[T]{
+		Value: value,
+		Delta: int64(time.Since(start)),
+	}
+
+	var ttl time.Duration
+	if p.TTL > 0 {
+		entry.FreshUntil = time.Now().Add(p.TTL).UnixNano()
+		ttl = p.TTL + p.Stale
+	}
+
+	if b, err := serializerFor(c).encode(entry); err == nil {
+		_ = c.SetContext(ctx, key, b, ttl)
+	}
+	return value, nil
+}
+
+// waitForReload polls for the value another process is loading, for at most the lock ttl
+func waitForReload[T any](ctx context.Context, c ContextCache, key string, p Policy) (T, bool) {
+	var entry policyEntry[T]
+
+	timeout := time.NewTimer(p.lockTTL())
+	defer timeout.Stop()
+	ticker := time.NewTicker(lockWaitInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return entry.Value, false
+		case <-timeout.C:
+			return entry.Value, false
+		case <-ticker.C:
+		}
+
+		b, err := c.GetContext(ctx, key)
+		if err == nil && serializerFor(c).decode(b, &entry) == nil {
+			return entry.Value, true
+		}
+	}
+}
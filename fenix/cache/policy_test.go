@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRememberWithPolicy_StaleWhileRevalidate(t *testing.T) {
+	ctx := context.Background()
+	c := &MemoryCache{}
+	p := Policy{TTL: 50 * time.Millisecond, Stale: time.Minute}
+
+	var version int32
+	loader := func(ctx context.Context) (int32, error) {
+		return atomic.AddInt32(&version, 1), nil
+	}
+
+	v, err := RememberWithPolicy(ctx, c, "policy-swr", p, loader)
+	if err != nil || v != 1 {
+		t.Fatalf("expected 1, got %d (%v)", v, err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	// stale, so the old value comes back at once and is reloaded behind it
+	v, err = RememberWithPolicy(ctx, c, "policy-swr", p, loader)
+	if err != nil || v != 1 {
+		t.Fatalf("expected the stale value 1, got %d (%v)", v, err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		v, _ = RememberWithPolicy(ctx, c, "policy-swr", p, loader)
+		if v == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the value to be refreshed, got %d", v)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRememberWithPolicy_Stampede(t *testing.T) {
+	ctx := context.Background()
+	_ = testRedisCache.RemoveContext(ctx, "policy-stampede")
+
+	var calls int32
+	loader := func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(100 * time.Millisecond)
+		return "loaded", nil
+	}
+
+	// separate RedisCache values on one server stand in for separate processes
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := &RedisCache{Conn: testRedisCache.Conn, Prefix: testRedisCache.Prefix}
+			v, err := RememberWithPolicy(ctx, c, "policy-stampede", Policy{TTL: time.Minute}, loader)
+			if err != nil || v != "loaded" {
+				t.Errorf("got %q (%v)", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("expected the loader to run once, ran %d times", calls)
+	}
+}
+
+func TestPolicy_NeedsRefresh(t *testing.T) {
+	now := time.Now()
+	fresh := now.Add(time.Second).UnixNano()
+	stale := now.Add(-time.Second).UnixNano()
+
+	tests := []struct {
+		name       string
+		policy     Policy
+		freshUntil int64
+		delta      int64
+		expected   bool
+	}{
+		{"fresh", Policy{TTL: time.Minute}, fresh, 0, false},
+		{"stale", Policy{TTL: time.Minute}, stale, 0, true},
+		{"no ttl", Policy{}, stale, 0, false},
+		{"early", Policy{TTL: time.Minute, Beta: 1}, fresh, int64(time.Hour), true},
+		{"not early", Policy{TTL: time.Minute, Beta: 1}, now.Add(time.Hour).UnixNano(), int64(time.Nanosecond), false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.policy.needsRefresh(tt.freshUntil, tt.delta, now); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
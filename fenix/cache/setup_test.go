@@ -13,6 +13,8 @@ import (
 
 var testRedisCache RedisCache
 var testBadgerCache BadgerCache
+var testMemoryCache MemoryCache
+var testRedisServer *miniredis.Miniredis
 
 func TestMain(m *testing.M) {
 	s, err := miniredis.Run()
@@ -21,6 +23,7 @@ func TestMain(m *testing.M) {
 	}
 
 	defer s.Close()
+	testRedisServer = s
 
 	pool := redis.Pool{
 		MaxIdle:     50,
@@ -41,7 +44,7 @@ func TestMain(m *testing.M) {
 
 	// create badger database
 	if _, err := os.Stat("./testdata/tmp"); os.IsNotExist(err) {
-		err := os.Mkdir("./testdata/tmp", 0755)
+		err := os.MkdirAll("./testdata/tmp", 0755)
 		if err != nil {
 			log.Fatal(err)
 		}
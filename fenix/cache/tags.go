@@ -0,0 +1,226 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/gomodule/redigo/redis"
+)
+
+// TaggedCache groups entries under tags, so that everything related to, say, user:42 can be dropped
+// at once without a naming convention for keys or a scan of the whole cache.
+type TaggedCache interface {
+	// SetWithTags stores value under key for ttl, as SetContext does, and adds key to every tag
+	SetWithTags(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error
+	// InvalidateTags removes every entry stored under any of the tags
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+// SetTagged encodes value as Set does and stores it under key with tags
+func SetTagged[T any](ctx context.Context, c TaggedCache, key string, value T, ttl time.Duration, tags ...string) error {
+	b, err := serializerFor(c).encode(value)
+	if err != nil {
+		return err
+	}
+	return c.SetWithTags(ctx, key, b, ttl, tags...)
+}
+
+// tagSetScript sets KEYS[1] and adds it to the tag sets in KEYS[2:]. A tag set expires with the
+// longest lived of its members, so tags on short lived entries do not pile up.
+var tagSetScript = redis.NewScript(-1, `
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+
+for i = 2, #KEYS do
+	local existed = redis.call('EXISTS', KEYS[i])
+	redis.call('SADD', KEYS[i], KEYS[1])
+	if ttl == 0 then
+		redis.call('PERSIST', KEYS[i])
+	else
+		local current = redis.call('PTTL', KEYS[i])
+		if existed == 0 or (current >= 0 and current < ttl) then
+			redis.call('PEXPIRE', KEYS[i], ttl)
+		end
+	end
+end
+return 1
+`)
+
+// tagInvalidateScript deletes every member of the tag sets in KEYS, then the sets themselves
+var tagInvalidateScript = redis.NewScript(-1, `
+local removed = 0
+for i = 1, #KEYS do
+	for _, key in ipairs(redis.call('SMEMBERS', KEYS[i])) do
+		removed = removed + redis.call('DEL', key)
+	end
+	redis.call('DEL', KEYS[i])
+end
+return removed
+`)
+
+func (f *RedisCache) tagKey(tag string) string {
+	return f.key("fenix-tag:" + tag)
+}
+
+func (f *RedisCache) SetWithTags(ctx context.Context, str string, value []byte, ttl time.Duration, tags ...string) error {
+	conn, err := f.Conn.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	args := make([]interface{}, 0, len(tags)+4)
+	args = append(args, len(tags)+1, f.key(str))
+	for _, tag := range tags {
+		args = append(args, f.tagKey(tag))
+	}
+	args = append(args, value, ttl.Milliseconds())
+
+	_, err = tagSetScript.DoContext(ctx, conn, args...)
+	return err
+}
+
+func (f *RedisCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	conn, err := f.Conn.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	args := make([]interface{}, 0, len(tags)+1)
+	args = append(args, len(tags))
+	for _, tag := range tags {
+		args = append(args, f.tagKey(tag))
+	}
+
+	_, err = tagInvalidateScript.DoContext(ctx, conn, args...)
+	return err
+}
+
+// badger keeps an empty index entry per tag and key, expiring with the entry it points at, so a tag is
+// invalidated with a prefix scan of its own index entries
+const badgerTagPrefix = "fenix-tag\x00"
+
+func badgerTagIndex(tag, key string) []byte {
+	return []byte(badgerTagPrefix + tag + "\x00" + key)
+}
+
+func (bc *BadgerCache) SetWithTags(ctx context.Context, str string, value []byte, ttl time.Duration, tags ...string) error {
+	return bc.Conn.Update(func(txn *badger.Txn) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		entries := []*badger.Entry{badger.NewEntry([]byte(str), value)}
+		for _, tag := range tags {
+			entries = append(entries, badger.NewEntry(badgerTagIndex(tag, str), nil))
+		}
+
+		for _, entry := range entries {
+			if ttl > 0 {
+				entry = entry.WithTTL(ttl)
+			}
+			if err := txn.SetEntry(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (bc *BadgerCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	var keys [][]byte
+
+	err := bc.Conn.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+
+		for _, tag := range tags {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			prefix := []byte(badgerTagPrefix + tag + "\x00")
+			for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+				index := iter.Item().KeyCopy(nil)
+				keys = append(keys, index, index[len(prefix):])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	batch := bc.Conn.NewWriteBatch()
+	defer batch.Cancel()
+
+	for _, k := range keys {
+		if err := batch.Delete(k); err != nil {
+			return err
+		}
+	}
+	return batch.Flush()
+}
+
+func (mc *MemoryCache) SetWithTags(ctx context.Context, str string, value []byte, ttl time.Duration, tags ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.set(str, append([]byte(nil), value...), ttl, tags...)
+	return nil
+}
+
+func (mc *MemoryCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.init()
+	for _, tag := range tags {
+		for key := range mc.tags[tag] {
+			if el, ok := mc.items[key]; ok {
+				mc.removeElement(el)
+			}
+		}
+	}
+	return nil
+}
+
+// SetWithTags tags the entry in L2 only. Values copied into L1 by reads carry no tags, so InvalidateTags
+// empties L1 on every instance rather than trying to find the tagged keys in it.
+func (tc *TieredCache) SetWithTags(ctx context.Context, str string, value []byte, ttl time.Duration, tags ...string) error {
+	err := tc.L2.SetWithTags(ctx, str, value, ttl, tags...)
+	if err != nil {
+		return err
+	}
+
+	_ = tc.L1.SetContext(ctx, str, value, tc.l1TTL(ttl))
+	return tc.publish(ctx, invalidateKey, str)
+}
+
+func (tc *TieredCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	_ = tc.L1.Empty()
+	if err := tc.L2.InvalidateTags(ctx, tags...); err != nil {
+		return err
+	}
+	return tc.publish(ctx, invalidateAll, "*")
+}
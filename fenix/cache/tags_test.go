@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTaggedCache_InvalidateTags(t *testing.T) {
+	ctx := context.Background()
+
+	for name, c := range contextCaches() {
+		_ = SetTagged(ctx, c, "tagged-profile", testUser{ID: 42}, time.Minute, "user:42")
+		_ = SetTagged(ctx, c, "tagged-orders", []int{1, 2}, 0, "user:42", "orders")
+		_ = SetTagged(ctx, c, "tagged-other", testUser{ID: 7}, time.Minute, "user:7")
+		_ = c.SetContext(ctx, "tagged-untagged", []byte("x"), time.Minute)
+
+		if err := c.InvalidateTags(ctx, "user:42"); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		for _, k := range []string{"tagged-profile", "tagged-orders"} {
+			if ok, _ := c.ExistsContext(ctx, k); ok {
+				t.Errorf("%s: expected %s to be invalidated", name, k)
+			}
+		}
+		for _, k := range []string{"tagged-other", "tagged-untagged"} {
+			if ok, _ := c.ExistsContext(ctx, k); !ok {
+				t.Errorf("%s: expected %s to be kept", name, k)
+			}
+		}
+
+		// invalidating a tag twice, or one that was never used, is not an error
+		if err := c.InvalidateTags(ctx, "user:42", "never-used"); err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+	}
+}
+
+func TestTaggedCache_Tiered(t *testing.T) {
+	ctx := context.Background()
+
+	one := NewTieredCache(&MemoryCache{}, &testRedisCache, time.Minute)
+	defer one.Close()
+	two := NewTieredCache(&MemoryCache{}, &testRedisCache, time.Minute)
+	defer two.Close()
+
+	if err := one.SetWithTags(ctx, "tiered-tagged", []byte("v"), time.Minute, "tiered-tag"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := two.GetContext(ctx, "tiered-tagged"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := one.InvalidateTags(ctx, "tiered-tag"); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if ok, _ := two.ExistsContext(ctx, "tiered-tagged"); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the tagged key to be gone from both tiers")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
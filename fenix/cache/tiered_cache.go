@@ -0,0 +1,399 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// TieredCache keeps recently used values in an in-process L1 MemoryCache in front of a shared L2 cache,
+// such as Redis or Badger. Reads try L1 first; writes and removals go to both. When L2 is a RedisCache,
+// every instance subscribes to an invalidation channel, so a write on one instance evicts the key from
+// the L1 of all the others.
+type TieredCache struct {
+	L1 *MemoryCache
+	L2 Cache
+	// L1TTL caps how long a value stays in L1, bounding staleness if an invalidation is missed
+	L1TTL time.Duration
+
+	id      string
+	pubsub  *redis.Pool
+	channel string
+	conn    redis.PubSubConn
+	stop    chan struct{}
+	done    chan struct{}
+	closeMu sync.Mutex
+	closed  bool
+}
+
+// invalidation messages are "<instance id> <op> <key>", where op is key, prefix or all
+const (
+	invalidateKey    = "key"
+	invalidatePrefix = "prefix"
+	invalidateAll    = "all"
+)
+
+// NewTieredCache puts l1 in front of l2. If l2 is a *RedisCache it starts listening for invalidations
+// from other instances; call Close to stop.
+func NewTieredCache(l1 *MemoryCache, l2 Cache, l1TTL time.Duration) *TieredCache {
+	tc := &TieredCache{
+		L1:    l1,
+		L2:    l2,
+		L1TTL: l1TTL,
+		id:    randomID(),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+
+	if rc, ok := l2.(*RedisCache); ok {
+		tc.pubsub = rc.Conn
+		tc.channel = rc.Prefix + ":fenix:invalidate"
+		tc.subscribe()
+	} else {
+		close(tc.done)
+	}
+
+	return tc
+}
+
+func randomID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// subscribe listens for invalidations until Close is called, reconnecting after errors. Anything
+// published while disconnected is lost, so L1 is emptied on every reconnect.
+func (tc *TieredCache) subscribe() {
+	ready := make(chan struct{})
+	var once sync.Once
+
+	go func() {
+		defer close(tc.done)
+
+		for {
+			tc.closeMu.Lock()
+			if tc.closed {
+				tc.closeMu.Unlock()
+				return
+			}
+			tc.conn = redis.PubSubConn{Conn: tc.pubsub.Get()}
+			err := tc.conn.Subscribe(tc.channel)
+			tc.closeMu.Unlock()
+
+			if err == nil {
+				tc.listen(func() {
+					reconnect := true
+					once.Do(func() {
+						reconnect = false
+						close(ready)
+					})
+					if reconnect {
+						_ = tc.L1.Empty()
+					}
+				})
+			}
+			tc.closeMu.Lock()
+			_ = tc.conn.Close()
+			tc.closeMu.Unlock()
+
+			select {
+			case <-tc.stop:
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+
+	// wait briefly for the subscription, so writes straight after NewTieredCache are seen by others
+	select {
+	case <-ready:
+	case <-time.After(time.Second):
+	}
+}
+
+// listen applies invalidations until the connection fails; subscribed is called once the
+// subscription is confirmed
+func (tc *TieredCache) listen(subscribed func()) {
+	for {
+		switch msg := tc.conn.Receive().(type) {
+		case redis.Subscription:
+			switch {
+			case msg.Kind == "subscribe":
+				subscribed()
+			case msg.Kind == "unsubscribe" && msg.Count == 0:
+				// Close unsubscribed us
+				return
+			}
+		case redis.Message:
+			tc.apply(string(msg.Data))
+		case error:
+			return
+		}
+	}
+}
+
+func (tc *TieredCache) apply(msg string) {
+	parts := strings.SplitN(msg, " ", 3)
+	if len(parts) != 3 || parts[0] == tc.id {
+		return
+	}
+
+	switch parts[1] {
+	case invalidateKey:
+		_ = tc.L1.Remove(parts[2])
+	case invalidatePrefix:
+		_ = tc.L1.EmptyByMatch(parts[2])
+	case invalidateAll:
+		_ = tc.L1.Empty()
+	}
+}
+
+// publish tells the other instances to drop keys from their L1
+func (tc *TieredCache) publish(ctx context.Context, op, key string) error {
+	if tc.pubsub == nil {
+		return nil
+	}
+
+	conn, err := tc.pubsub.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = redis.DoContext(conn, ctx, "PUBLISH", tc.channel, tc.id+" "+op+" "+key)
+	return err
+}
+
+// Close stops listening for invalidations. It does not close L2.
+func (tc *TieredCache) Close() error {
+	tc.closeMu.Lock()
+	if tc.closed {
+		tc.closeMu.Unlock()
+		return nil
+	}
+	tc.closed = true
+	close(tc.stop)
+	if tc.pubsub != nil {
+		// the listener returns once the unsubscribe is confirmed, and closes the connection itself
+		_ = tc.conn.Unsubscribe()
+	}
+	tc.closeMu.Unlock()
+
+	<-tc.done
+	return nil
+}
+
+// serializer encodes values for L1 the same way as for L2, so both hold the same bytes
+func (tc *TieredCache) serializer() serializer {
+	return serializerFor(tc.L2)
+}
+
+// Expirer is implemented by caches that can tell how long their keys have left. A TieredCache needs
+// it from L2 so a value copied into L1 never outlives the L2 entry it came from.
+type Expirer interface {
+	// TTL returns how long each key has left, or zero for a key that never expires. Keys that are
+	// not in the cache are left out.
+	TTL(ctx context.Context, keys ...string) (map[string]time.Duration, error)
+}
+
+// l1TTL returns the lifetime of a value copied into L1, given its lifetime in L2
+func (tc *TieredCache) l1TTL(ttl time.Duration) time.Duration {
+	if tc.L1TTL > 0 && (ttl <= 0 || ttl > tc.L1TTL) {
+		return tc.L1TTL
+	}
+	return ttl
+}
+
+// remainingTTL returns the L1 lifetime of each key that was just read from L2: what is left of its
+// L2 lifetime, capped at L1TTL. Keys left out should not be copied into L1, either because they have
+// expired since, or because L2 cannot tell and there is no L1TTL to bound them.
+func (tc *TieredCache) remainingTTL(ctx context.Context, keys ...string) map[string]time.Duration {
+	ttls := make(map[string]time.Duration, len(keys))
+
+	e, ok := tc.L2.(Expirer)
+	if !ok {
+		if tc.L1TTL > 0 {
+			for _, k := range keys {
+				ttls[k] = tc.L1TTL
+			}
+		}
+		return ttls
+	}
+
+	remaining, err := e.TTL(ctx, keys...)
+	if err != nil {
+		return ttls
+	}
+	for k, ttl := range remaining {
+		ttls[k] = tc.l1TTL(ttl)
+	}
+	return ttls
+}
+
+// seconds converts ttl to the whole seconds Set takes, rounding up so that a lifetime shorter than a
+// second does not become zero, which would never expire
+func seconds(ttl time.Duration) int {
+	if ttl <= 0 {
+		return 0
+	}
+	return int((ttl + time.Second - 1) / time.Second)
+}
+
+func (tc *TieredCache) Exists(str string) (bool, error) {
+	if ok, _ := tc.L1.Exists(str); ok {
+		return true, nil
+	}
+	return tc.L2.Exists(str)
+}
+
+func (tc *TieredCache) Get(str string) (interface{}, error) {
+	if value, err := tc.L1.Get(str); err == nil {
+		return value, nil
+	}
+
+	value, err := tc.L2.Get(str)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl, ok := tc.remainingTTL(context.Background(), str)[str]; ok {
+		_ = tc.L1.Set(str, value, seconds(ttl))
+	}
+	return value, nil
+}
+
+func (tc *TieredCache) Set(str string, val interface{}, expiry ...int) error {
+	err := tc.L2.Set(str, val, expiry...)
+	if err != nil {
+		return err
+	}
+
+	var ttl time.Duration
+	if len(expiry) > 0 {
+		ttl = time.Duration(expiry[0]) * time.Second
+	}
+	_ = tc.L1.Set(str, val, seconds(tc.l1TTL(ttl)))
+
+	return tc.publish(context.Background(), invalidateKey, str)
+}
+
+func (tc *TieredCache) Remove(str string) error {
+	_ = tc.L1.Remove(str)
+	if err := tc.L2.Remove(str); err != nil {
+		return err
+	}
+	return tc.publish(context.Background(), invalidateKey, str)
+}
+
+func (tc *TieredCache) EmptyByMatch(str string) error {
+	_ = tc.L1.EmptyByMatch(str)
+	if err := tc.L2.EmptyByMatch(str); err != nil {
+		return err
+	}
+	return tc.publish(context.Background(), invalidatePrefix, str)
+}
+
+func (tc *TieredCache) Empty() error {
+	_ = tc.L1.Empty()
+	if err := tc.L2.Empty(); err != nil {
+		return err
+	}
+	return tc.publish(context.Background(), invalidateAll, "*")
+}
+
+func (tc *TieredCache) ExistsContext(ctx context.Context, str string) (bool, error) {
+	if ok, _ := tc.L1.ExistsContext(ctx, str); ok {
+		return true, nil
+	}
+	return tc.L2.ExistsContext(ctx, str)
+}
+
+func (tc *TieredCache) GetContext(ctx context.Context, str string) ([]byte, error) {
+	found, err := tc.GetMulti(ctx, str)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := found[str]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	return value, nil
+}
+
+func (tc *TieredCache) SetContext(ctx context.Context, str string, value []byte, ttl time.Duration) error {
+	return tc.SetMulti(ctx, map[string][]byte{str: value}, ttl)
+}
+
+func (tc *TieredCache) RemoveContext(ctx context.Context, str string) error {
+	_ = tc.L1.Remove(str)
+	if err := tc.L2.RemoveContext(ctx, str); err != nil {
+		return err
+	}
+	return tc.publish(ctx, invalidateKey, str)
+}
+
+// GetMulti reads what it can from L1 and fetches the rest from L2 in one call
+func (tc *TieredCache) GetMulti(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	found, err := tc.L1.GetMulti(ctx, keys...)
+	if err != nil && !errors.Is(err, errNotBytes) {
+		return nil, err
+	}
+	if found == nil {
+		found = make(map[string][]byte, len(keys))
+	}
+
+	var missing []string
+	for _, k := range keys {
+		if _, ok := found[k]; !ok {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) == 0 {
+		return found, nil
+	}
+
+	fromL2, err := tc.L2.GetMulti(ctx, missing...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fromL2) > 0 {
+		keys := make([]string, 0, len(fromL2))
+		for k := range fromL2 {
+			keys = append(keys, k)
+		}
+
+		ttls := tc.remainingTTL(ctx, keys...)
+		for k, v := range fromL2 {
+			if ttl, ok := ttls[k]; ok {
+				_ = tc.L1.SetContext(ctx, k, v, ttl)
+			}
+			found[k] = v
+		}
+	}
+	return found, nil
+}
+
+func (tc *TieredCache) SetMulti(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	err := tc.L2.SetMulti(ctx, items, ttl)
+	if err != nil {
+		return err
+	}
+
+	_ = tc.L1.SetMulti(ctx, items, tc.l1TTL(ttl))
+
+	for k := range items {
+		if err := tc.publish(ctx, invalidateKey, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTieredCache_ReadThrough(t *testing.T) {
+	ctx := context.Background()
+	tc := NewTieredCache(&MemoryCache{}, &testBadgerCache, time.Minute)
+	defer tc.Close()
+
+	_ = testBadgerCache.SetContext(ctx, "tiered-read", []byte("from l2"), 0)
+
+	v, err := tc.GetContext(ctx, "tiered-read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v) != "from l2" {
+		t.Errorf("got %q", v)
+	}
+	if ok, _ := tc.L1.Exists("tiered-read"); !ok {
+		t.Error("expected the value to be copied into L1")
+	}
+
+	_ = tc.RemoveContext(ctx, "tiered-read")
+	if ok, _ := testBadgerCache.ExistsContext(ctx, "tiered-read"); ok {
+		t.Error("expected the value to be removed from L2")
+	}
+}
+
+func TestTieredCache_Invalidation(t *testing.T) {
+	ctx := context.Background()
+
+	one := NewTieredCache(&MemoryCache{}, &testRedisCache, time.Minute)
+	defer one.Close()
+	two := NewTieredCache(&MemoryCache{}, &testRedisCache, time.Minute)
+	defer two.Close()
+
+	if err := one.SetContext(ctx, "tiered-key", []byte("v1"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := two.GetContext(ctx, "tiered-key"); string(v) != "v1" {
+		t.Fatalf("expected v1, got %q", v)
+	}
+
+	// two now has v1 in its L1; a write on one must evict it
+	if err := one.SetContext(ctx, "tiered-key", []byte("v2"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		v, err := two.GetContext(ctx, "tiered-key")
+		if err == nil && string(v) == "v2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected v2 after invalidation, got %q (%v)", v, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_ = one.Empty()
+	deadline = time.Now().Add(2 * time.Second)
+	for two.L1.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected Empty to clear the other instance's L1")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTieredCache_L1NeverOutlivesL2(t *testing.T) {
+	ctx := context.Background()
+	l2 := &MemoryCache{}
+	tc := NewTieredCache(&MemoryCache{}, l2, time.Minute)
+	defer tc.Close()
+
+	_ = l2.SetContext(ctx, "short-lived", []byte("v"), 100*time.Millisecond)
+
+	if _, err := tc.GetContext(ctx, "short-lived"); err != nil {
+		t.Fatal(err)
+	}
+	ttls, _ := tc.L1.TTL(ctx, "short-lived")
+	if ttl, ok := ttls["short-lived"]; !ok || ttl <= 0 || ttl > 100*time.Millisecond {
+		t.Errorf("expected L1 to keep what is left of the L2 ttl, got %s", ttl)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if ok, _ := tc.L1.Exists("short-lived"); ok {
+		t.Error("expected the L1 copy to expire with the L2 entry")
+	}
+}
+
+func TestSeconds(t *testing.T) {
+	tests := map[time.Duration]int{
+		0:                       0,
+		500 * time.Millisecond:  1,
+		time.Second:             1,
+		1500 * time.Millisecond: 2,
+	}
+	for ttl, want := range tests {
+		if got := seconds(ttl); got != want {
+			t.Errorf("seconds(%s) = %d, want %d", ttl, got, want)
+		}
+	}
+}
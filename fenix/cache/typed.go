@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrCacheMiss is returned by the context aware methods when a key is not in the cache
+var ErrCacheMiss = errors.New("cache: miss")
+
+// ContextCache is the context aware side of a cache. Values are raw bytes; use Get, Set and
+// Remember for typed values. Every call gives up when its context is done, so a per call
+// timeout is just a context.WithTimeout. A ttl of zero means the value never expires.
+type ContextCache interface {
+	ExistsContext(ctx context.Context, key string) (bool, error)
+	GetContext(ctx context.Context, key string) ([]byte, error)
+	SetContext(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	RemoveContext(ctx context.Context, key string) error
+	// GetMulti returns the values of the keys that are in the cache; missing keys are left out
+	GetMulti(ctx context.Context, keys ...string) (map[string][]byte, error)
+	SetMulti(ctx context.Context, items map[string][]byte, ttl time.Duration) error
+}
+
+// Get returns the value stored under key by Set or Remember, decoded with the codec it was written with
+func Get[T any](ctx context.Context, c ContextCache, key string) (T, error) {
+	var value T
+
+	b, err := c.GetContext(ctx, key)
+	if err != nil {
+		return value, err
+	}
+
+	err = serializerFor(c).decode(b, &value)
+	return value, err
+}
+
+// Set stores value under key for ttl, encoded with the codec of c, or gob if it has none
+func Set[T any](ctx context.Context, c ContextCache, key string, value T, ttl time.Duration) error {
+	b, err := serializerFor(c).encode(value)
+	if err != nil {
+		return err
+	}
+	return c.SetContext(ctx, key, b, ttl)
+}
+
+var rememberGroup singleflight.Group
+
+// Remember returns the value under key, calling loader and caching its result on a miss. Concurrent
+// misses for the same key in this process share one call to loader. A failure to write the loaded
+// value back to the cache is not returned, since the caller still has the value. For hot keys, see
+// RememberWithPolicy.
+func Remember[T any](ctx context.Context, c ContextCache, key string, ttl time.Duration, loader func(ctx context.Context) (T, error)) (T, error) {
+	value, err := Get[T](ctx, c, key)
+	if err == nil {
+		return value, nil
+	}
+	if !errors.Is(err, ErrCacheMiss) {
+		return value, err
+	}
+
+	// the group is shared by every cache, so keep keys from different caches apart
+	flightKey := fmt.Sprintf("%p:%s", c, key)
+	v, err, _ := rememberGroup.Do(flightKey, func() (interface{}, error) {
+		loaded, err := loader(ctx)
+		if err != nil {
+			return loaded, err
+		}
+		_ = Set(ctx, c, key, loaded, ttl)
+		return loaded, nil
+	})
+	if err != nil {
+		return value, err
+	}
+
+	value, _ = v.(T)
+	return value, nil
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testUser struct {
+	ID    int
+	Email string
+}
+
+func contextCaches() map[string]Cache {
+	return map[string]Cache{
+		"redis":  &testRedisCache,
+		"badger": &testBadgerCache,
+		"memory": &testMemoryCache,
+	}
+}
+
+func TestContextCache_GetSet(t *testing.T) {
+	ctx := context.Background()
+
+	for name, c := range contextCaches() {
+		_ = c.RemoveContext(ctx, "typed-user")
+
+		_, err := Get[testUser](ctx, c, "typed-user")
+		if !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("%s: expected a cache miss, got %v", name, err)
+		}
+
+		err = Set(ctx, c, "typed-user", testUser{ID: 1, Email: "me@here.com"}, time.Minute)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		u, err := Get[testUser](ctx, c, "typed-user")
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if u.ID != 1 || u.Email != "me@here.com" {
+			t.Errorf("%s: unexpected value %+v", name, u)
+		}
+
+		exists, err := c.ExistsContext(ctx, "typed-user")
+		if err != nil || !exists {
+			t.Errorf("%s: expected typed-user to exist, got %v %v", name, exists, err)
+		}
+	}
+}
+
+func TestContextCache_Multi(t *testing.T) {
+	ctx := context.Background()
+
+	for name, c := range contextCaches() {
+		_ = c.RemoveContext(ctx, "multi-c")
+
+		err := c.SetMulti(ctx, map[string][]byte{
+			"multi-a": []byte("alpha"),
+			"multi-b": []byte("beta"),
+		}, time.Minute)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		found, err := c.GetMulti(ctx, "multi-a", "multi-b", "multi-c")
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if len(found) != 2 || string(found["multi-a"]) != "alpha" || string(found["multi-b"]) != "beta" {
+			t.Errorf("%s: unexpected values %v", name, found)
+		}
+	}
+}
+
+func TestContextCache_Remember(t *testing.T) {
+	ctx := context.Background()
+
+	for name, c := range contextCaches() {
+		_ = c.RemoveContext(ctx, "remembered")
+
+		var calls int32
+		loader := func(ctx context.Context) (string, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(50 * time.Millisecond)
+			return "loaded", nil
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, err := Remember(ctx, c, "remembered", time.Minute, loader)
+				if err != nil || v != "loaded" {
+					t.Errorf("%s: unexpected result %q %v", name, v, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if calls != 1 {
+			t.Errorf("%s: expected the loader to run once, ran %d times", name, calls)
+		}
+
+		// now it is cached, so the loader is not needed at all
+		v, err := Remember(ctx, c, "remembered", time.Minute, func(ctx context.Context) (string, error) {
+			return "", errors.New("loader should not be called")
+		})
+		if err != nil || v != "loaded" {
+			t.Errorf("%s: unexpected cached result %q %v", name, v, err)
+		}
+	}
+}
+
+func TestContextCache_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for name, c := range contextCaches() {
+		_, err := c.GetContext(ctx, "anything")
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected context.Canceled, got %v", name, err)
+		}
+	}
+}
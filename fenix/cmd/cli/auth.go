@@ -27,8 +27,18 @@ func doAuth() error {
 		exitGracefully(err)
 	}
 
+	// the roles and two-factor tables go in the same migration; apps that ran make auth before they
+	// existed get them from make roles and make two-factor
+	for _, name := range []string{"roles", "two_factor"} {
+		b, err := templateFS.ReadFile("templates/migrations/" + name + "." + dbType + ".sql")
+		if err != nil {
+			exitGracefully(err)
+		}
+		upBytes = append(append(upBytes, '\n', '\n'), b...)
+	}
+
 	// err = copyDataToFile([]byte("drop table if exists users cascade; drop table if exists tokens cascade; drop table if exists remember_tokens"), downFile)
-	downBytes := []byte("drop table if exists users cascade; drop table if exists tokens cascade; drop table if exists remember_tokens")
+	downBytes := []byte("drop table if exists recovery_codes; drop table if exists role_user; drop table if exists permissions; drop table if exists roles; drop table if exists users cascade; drop table if exists tokens cascade; drop table if exists remember_tokens")
 	if err != nil {
 		exitGracefully(err)
 	}
@@ -60,6 +70,16 @@ func doAuth() error {
 		exitGracefully(err)
 	}
 
+	err = copyFileFromTemplate("templates/data/role.go.txt", fnx.RootPath+"/data/role.go")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = copyFileFromTemplate("templates/data/two_factor.go.txt", fnx.RootPath+"/data/two_factor.go")
+	if err != nil {
+		exitGracefully(err)
+	}
+
 	// copy middleware
 	err = copyFileFromTemplate("templates/middleware/auth.go.txt", fnx.RootPath+"/middleware/auth.go")
 	if err != nil {
@@ -81,6 +101,11 @@ func doAuth() error {
 		exitGracefully(err)
 	}
 
+	err = copyFileFromTemplate("templates/handlers/two-factor-handlers.go.txt", fnx.RootPath+"/handlers/two-factor-handlers.go")
+	if err != nil {
+		exitGracefully(err)
+	}
+
 	err = copyFileFromTemplate("templates/mailer/password-reset.html.tmpl", fnx.RootPath+"/mail/password-reset.html.tmpl")
 	if err != nil {
 		exitGracefully(err)
@@ -106,11 +131,22 @@ func doAuth() error {
 		exitGracefully(err)
 	}
 
-	color.Yellow("	- Users, tokens, and remember_tokens migrations created and executed")
-	color.Yellow("	- User and token models created")
+	for _, view := range []string{"two-factor", "two-factor-setup", "two-factor-recovery"} {
+		err = copyFileFromTemplate("templates/views/"+view+".jet", fnx.RootPath+"/views/"+view+".jet")
+		if err != nil {
+			exitGracefully(err)
+		}
+	}
+
+	color.Yellow("	- Users, tokens, remember_tokens, roles, permissions, role_user and recovery_codes migrations created and executed")
+	color.Yellow("	- User, token, role and two-factor models created")
 	color.Yellow("	- Auth middleware created")
+	color.Yellow("	- Login, password reset and two-factor handlers and views created")
 	color.Yellow("")
 	color.Cyan("Don't forget to add user and token models in data/models.go, and add appropriate middleware to your routes!")
+	color.Cyan("Set fnx.Users = &data.User{} in init-fenix.go, before the routes, so the auth guards can find users.")
+	color.Cyan("For two-factor authentication, route /users/two-factor to the TwoFactorChallenge handlers, and")
+	color.Cyan("/users/two-factor/setup, /recovery-codes and /disable to the TwoFactor handlers behind the Auth middleware.")
 
 	return nil
 }
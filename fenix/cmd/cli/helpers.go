@@ -85,9 +85,13 @@ func showHelp() {
 	make migration <name> <format>		- Create new up and down migrations files in the migrations folder; 
 						format=sql/fizz (default fizz)
 	make auth				- Create and runs migrations for auth tables, and create models and middleware
+	make roles				- Create the roles, permissions and role_user tables, for apps that ran make auth before they existed
+	make two-factor				- Add the TOTP columns and recovery_codes table, for apps that ran make auth before they existed
 	make handler <name>			- Create a stub handler in the handlers directory
 	make model <name>			- Create a new model in the data directory
 	make session				- Create a table in the database as session store
+	make session-index			- Create the table listing each user's sessions, for apps that ran make session before it existed
+	make queue				- Create a table in the database as job queue store
 	make mail <name>			- Create two starter email templates in the mail directory
 
 	`)
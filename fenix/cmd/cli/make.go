@@ -139,6 +139,26 @@ func doMake(arg2, arg3, arg4 string) error {
 		if err != nil {
 			exitGracefully(err)
 		}
+	case "session-index":
+		err := doSessionIndexTable()
+		if err != nil {
+			exitGracefully(err)
+		}
+	case "roles":
+		err := doRoles()
+		if err != nil {
+			exitGracefully(err)
+		}
+	case "two-factor":
+		err := doTwoFactor()
+		if err != nil {
+			exitGracefully(err)
+		}
+	case "queue":
+		err := doQueueTable()
+		if err != nil {
+			exitGracefully(err)
+		}
 	case "mail":
 		if arg3 == "" {
 			exitGracefully(errors.New("must include mail template name"))
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func doQueueTable() error {
+	dbType := fnx.DB.DataType
+
+	if dbType == "mariadb" {
+		dbType = "mysql"
+	}
+
+	if dbType == "postgresql" {
+		dbType = "postgres"
+	}
+
+	fileName := fmt.Sprintf("%d_create_jobs_table", time.Now().UnixMicro())
+
+	upFile := fnx.RootPath + "/migrations/" + fileName + "." + dbType + ".up.sql"
+	downFile := fnx.RootPath + "/migrations/" + fileName + "." + dbType + ".down.sql"
+
+	err := copyFileFromTemplate("templates/migrations/"+dbType+"_queue.sql", upFile)
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = copyDataToFile([]byte("drop table jobs"), downFile)
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = doMigrate("up", "")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	return nil
+}
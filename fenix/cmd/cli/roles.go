@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+
+	"github.com/fatih/color"
+)
+
+// doRoles adds the roles, permissions and role_user tables to an app whose auth tables were created
+// before there were roles. The migration only creates what is missing, and leaves users alone.
+func doRoles() error {
+	checkForDB()
+	dbType := fnx.DB.DataType
+
+	tx, err := fnx.PopConnect()
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	defer tx.Close()
+
+	upBytes, err := templateFS.ReadFile("templates/migrations/roles." + dbType + ".sql")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	downBytes := []byte("drop table if exists role_user; drop table if exists permissions; drop table if exists roles")
+
+	err = fnx.CreatePopMigration(upBytes, downBytes, "roles", "sql")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = fnx.RunPopMigrations(tx)
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	// keep a role model the app already has
+	if _, err := os.Stat(fnx.RootPath + "/data/role.go"); os.IsNotExist(err) {
+		err = copyFileFromTemplate("templates/data/role.go.txt", fnx.RootPath+"/data/role.go")
+		if err != nil {
+			exitGracefully(err)
+		}
+		color.Yellow("	- Role model created")
+	}
+
+	color.Yellow("	- Roles, permissions and role_user migration created and executed")
+
+	return nil
+}
@@ -6,27 +6,31 @@ import (
 )
 
 func doSessionTable() error {
-	dbType := fnx.DB.DataType
+	dbType := sessionDBType()
 
-	if dbType == "mariadb" {
-		dbType = "mysql"
+	err := writeSessionMigration(dbType, "create_sessions_table", "session", "drop table sessions")
+	if err != nil {
+		exitGracefully(err)
 	}
 
-	if dbType == "postgresql" {
-		dbType = "postgres"
+	// new apps get the table that lists each user's sessions along with the sessions table
+	err = writeSessionMigration(dbType, "create_session_index_table", "session_index", "drop table session_index")
+	if err != nil {
+		exitGracefully(err)
 	}
 
-	fileName := fmt.Sprintf("%d_create_sessions_table", time.Now().UnixMicro())
-
-	upFile := fnx.RootPath + "/migrations/" + fileName + "." + dbType + ".up.sql"
-	downFile := fnx.RootPath + "/migrations/" + fileName + "." + dbType + ".down.sql"
-
-	err := copyFileFromTemplate("templates/migrations/"+dbType+"_session.sql", upFile)
+	err = doMigrate("up", "")
 	if err != nil {
 		exitGracefully(err)
 	}
 
-	err = copyDataToFile([]byte("drop table sessions"), downFile)
+	return nil
+}
+
+// doSessionIndexTable adds the table that lists each user's sessions to an app whose sessions table
+// was created before there was one
+func doSessionIndexTable() error {
+	err := writeSessionMigration(sessionDBType(), "create_session_index_table", "session_index", "drop table session_index")
 	if err != nil {
 		exitGracefully(err)
 	}
@@ -38,3 +42,33 @@ func doSessionTable() error {
 
 	return nil
 }
+
+func sessionDBType() string {
+	dbType := fnx.DB.DataType
+
+	if dbType == "mariadb" {
+		dbType = "mysql"
+	}
+
+	if dbType == "postgresql" {
+		dbType = "postgres"
+	}
+
+	return dbType
+}
+
+// writeSessionMigration writes the up migration from templates/migrations/<dbType>_<template>.sql,
+// and a down migration that runs down
+func writeSessionMigration(dbType, name, template, down string) error {
+	fileName := fmt.Sprintf("%d_%s", time.Now().UnixMicro(), name)
+
+	upFile := fnx.RootPath + "/migrations/" + fileName + "." + dbType + ".up.sql"
+	downFile := fnx.RootPath + "/migrations/" + fileName + "." + dbType + ".down.sql"
+
+	err := copyFileFromTemplate("templates/migrations/"+dbType+"_"+template+".sql", upFile)
+	if err != nil {
+		return err
+	}
+
+	return copyDataToFile([]byte(down), downFile)
+}
@@ -0,0 +1,15 @@
+CREATE TABLE jobs (
+                      id VARCHAR(64) PRIMARY KEY,
+                      queue VARCHAR(255) NOT NULL,
+                      payload LONGTEXT NOT NULL,
+                      status VARCHAR(16) NOT NULL,
+                      attempts INT NOT NULL DEFAULT 0,
+                      max_attempts INT NOT NULL,
+                      last_error TEXT NOT NULL,
+                      run_at TIMESTAMP(6) NOT NULL,
+                      created_at TIMESTAMP(6) NOT NULL,
+                      updated_at TIMESTAMP(6) NOT NULL
+);
+
+CREATE INDEX jobs_queue_status_run_at_idx ON jobs (queue, status, run_at);
+CREATE INDEX jobs_status_updated_at_idx ON jobs (status, updated_at);
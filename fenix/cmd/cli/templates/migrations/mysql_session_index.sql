@@ -0,0 +1,12 @@
+CREATE TABLE session_index (
+                          id CHAR(32) PRIMARY KEY,
+                          user_id VARCHAR(255) NOT NULL,
+                          token CHAR(43) NOT NULL,
+                          ip VARCHAR(45) NOT NULL,
+                          user_agent TEXT NOT NULL,
+                          created_at TIMESTAMP(6) NOT NULL,
+                          last_seen TIMESTAMP(6) NOT NULL,
+                          expiry TIMESTAMP(6) NOT NULL
+);
+
+CREATE INDEX session_index_user_id_idx ON session_index (user_id);
@@ -0,0 +1,15 @@
+CREATE TABLE jobs (
+                      id VARCHAR(64) PRIMARY KEY,
+                      queue VARCHAR(255) NOT NULL,
+                      payload TEXT NOT NULL,
+                      status VARCHAR(16) NOT NULL,
+                      attempts INTEGER NOT NULL DEFAULT 0,
+                      max_attempts INTEGER NOT NULL,
+                      last_error TEXT NOT NULL DEFAULT '',
+                      run_at TIMESTAMPTZ NOT NULL,
+                      created_at TIMESTAMPTZ NOT NULL,
+                      updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX jobs_queue_status_run_at_idx ON jobs (queue, status, run_at);
+CREATE INDEX jobs_status_updated_at_idx ON jobs (status, updated_at);
@@ -0,0 +1,12 @@
+CREATE TABLE session_index (
+                          id VARCHAR(32) PRIMARY KEY,
+                          user_id VARCHAR(255) NOT NULL,
+                          token TEXT NOT NULL,
+                          ip VARCHAR(45) NOT NULL,
+                          user_agent TEXT NOT NULL,
+                          created_at TIMESTAMPTZ NOT NULL,
+                          last_seen TIMESTAMPTZ NOT NULL,
+                          expiry TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX session_index_user_id_idx ON session_index (user_id);
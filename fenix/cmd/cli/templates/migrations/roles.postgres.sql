@@ -0,0 +1,41 @@
+-- roles, their permissions, and the users they are given to. Only creates what is missing, so it
+-- can run on a database made by an earlier "fenix make auth".
+
+CREATE TABLE IF NOT EXISTS roles (
+    id SERIAL PRIMARY KEY,
+    name character varying(100) NOT NULL UNIQUE,
+    created_at timestamp without time zone NOT NULL DEFAULT now(),
+    updated_at timestamp without time zone NOT NULL DEFAULT now()
+);
+
+DROP TRIGGER IF EXISTS set_timestamp ON roles;
+
+CREATE TRIGGER set_timestamp
+    BEFORE UPDATE ON roles
+    FOR EACH ROW
+    EXECUTE PROCEDURE trigger_set_timestamp();
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id SERIAL PRIMARY KEY,
+    role_id integer NOT NULL REFERENCES roles(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    name character varying(100) NOT NULL,
+    created_at timestamp without time zone NOT NULL DEFAULT now(),
+    updated_at timestamp without time zone NOT NULL DEFAULT now(),
+    UNIQUE (role_id, name)
+);
+
+DROP TRIGGER IF EXISTS set_timestamp ON permissions;
+
+CREATE TRIGGER set_timestamp
+    BEFORE UPDATE ON permissions
+    FOR EACH ROW
+    EXECUTE PROCEDURE trigger_set_timestamp();
+
+CREATE TABLE IF NOT EXISTS role_user (
+    role_id integer NOT NULL REFERENCES roles(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    user_id integer NOT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    created_at timestamp without time zone NOT NULL DEFAULT now(),
+    PRIMARY KEY (role_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS role_user_user_id_idx ON role_user (user_id);
@@ -0,0 +1,17 @@
+-- two-factor authentication: the TOTP columns of users, and the hashed recovery codes. Adds to the
+-- tables made by "fenix make auth" without dropping them.
+
+ALTER TABLE `users`
+    ADD COLUMN `totp_secret` varchar(64) NOT NULL DEFAULT '' AFTER `password`,
+    ADD COLUMN `totp_enabled` int(11) NOT NULL DEFAULT 0 AFTER `totp_secret`,
+    ADD COLUMN `totp_last_step` bigint(20) NOT NULL DEFAULT 0 AFTER `totp_enabled`;
+
+CREATE TABLE IF NOT EXISTS `recovery_codes` (
+    `id` int(10) unsigned NOT NULL AUTO_INCREMENT,
+    `user_id` int(10) unsigned NOT NULL,
+    `code_hash` char(64) NOT NULL,
+    `created_at` timestamp NOT NULL DEFAULT current_timestamp(),
+    PRIMARY KEY (`id`),
+    KEY `recovery_codes_user_id_foreign` (`user_id`),
+    CONSTRAINT `recovery_codes_user_id_foreign` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
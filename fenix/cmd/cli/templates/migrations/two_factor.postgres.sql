@@ -0,0 +1,16 @@
+-- two-factor authentication: the TOTP columns of users, and the hashed recovery codes. Adds to the
+-- tables made by "fenix make auth" without dropping them.
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS totp_secret character varying(64) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS totp_enabled integer NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS totp_last_step bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    code_hash character(64) NOT NULL,
+    created_at timestamp without time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS recovery_codes_user_id_idx ON recovery_codes (user_id);
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+
+	"github.com/fatih/color"
+)
+
+// doTwoFactor adds the TOTP columns of users and the recovery_codes table to an app whose auth tables
+// were created before there was two-factor authentication, without touching the users it has
+func doTwoFactor() error {
+	checkForDB()
+	dbType := fnx.DB.DataType
+
+	tx, err := fnx.PopConnect()
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	defer tx.Close()
+
+	upBytes, err := templateFS.ReadFile("templates/migrations/two_factor." + dbType + ".sql")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	downBytes := []byte("drop table if exists recovery_codes; alter table users drop column totp_secret, drop column totp_enabled, drop column totp_last_step")
+
+	err = fnx.CreatePopMigration(upBytes, downBytes, "two_factor", "sql")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = fnx.RunPopMigrations(tx)
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	// keep what the app already has
+	files := map[string]string{
+		"templates/data/two_factor.go.txt":              "/data/two_factor.go",
+		"templates/handlers/two-factor-handlers.go.txt": "/handlers/two-factor-handlers.go",
+		"templates/views/two-factor.jet":                "/views/two-factor.jet",
+		"templates/views/two-factor-setup.jet":          "/views/two-factor-setup.jet",
+		"templates/views/two-factor-recovery.jet":       "/views/two-factor-recovery.jet",
+	}
+	for template, file := range files {
+		if _, err := os.Stat(fnx.RootPath + file); os.IsNotExist(err) {
+			err = copyFileFromTemplate(template, fnx.RootPath+file)
+			if err != nil {
+				exitGracefully(err)
+			}
+		}
+	}
+
+	color.Yellow("	- TOTP columns and recovery_codes migration created and executed")
+	color.Yellow("	- Two-factor model, handlers and views created where missing")
+	color.Yellow("")
+	color.Cyan("The user model needs the TOTP fields of templates/data/user.go.txt, and the login handlers need")
+	color.Cyan("to send users with two-factor authentication to the challenge, as the auth-handlers template does.")
+
+	return nil
+}
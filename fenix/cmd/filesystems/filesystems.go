@@ -1,6 +1,9 @@
 package filesystems
 
-import "time"
+import (
+	"io"
+	"time"
+)
 
 // FS is the interface for file systems. In order to satisfy the interface,
 // all functions must exist
@@ -9,6 +12,22 @@ type FS interface {
 	Get(destination string, items ...string) error
 	List(prefix string) ([]Listing, error)
 	Delete(itemsToDel []string) bool
+
+	// PutStream writes everything read from r to key. size may be -1 when unknown
+	PutStream(key string, r io.Reader, size int64, contentType string) error
+	// Open returns a reader for key; the caller must close it
+	Open(key string) (io.ReadCloser, error)
+	// Stat describes key; the error wraps fs.ErrNotExist if there is no such item
+	Stat(key string) (Listing, error)
+	Exists(key string) (bool, error)
+	Copy(src, dst string) error
+	Move(src, dst string) error
+}
+
+// TemporaryURLer is implemented by file systems that can hand out a time limited download link
+// themselves, such as presigned S3 and MinIO URLs. Fenix signs its own links for the others.
+type TemporaryURLer interface {
+	TemporaryURL(key string, ttl time.Duration) (string, error)
 }
 
 // Listing describes one file on a remote file system
@@ -0,0 +1,259 @@
+package localfilesystem
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/wtran29/fenix/fenix/cmd/filesystems"
+)
+
+// ErrPathTraversal is returned when a key resolves to a location outside of Root
+var ErrPathTraversal = errors.New("localfilesystem: path escapes root directory")
+
+// Local is a file system on local disk; every key is relative to Root
+type Local struct {
+	Root string
+}
+
+func init() {
+	filesystems.Register("local", New)
+}
+
+// New creates a local file system from a disk configuration; the "root" option
+// is the directory the disk lives in
+func New(cfg filesystems.Config) (filesystems.FS, error) {
+	root := cfg.Get("root")
+	if root == "" {
+		return nil, errors.New("localfilesystem: root must be set for disk " + cfg.Name)
+	}
+	return &Local{Root: root}, nil
+}
+
+// fullPath resolves key against the root directory, refusing anything that would escape it
+func (l *Local) fullPath(key string) (string, error) {
+	root, err := filepath.Abs(l.Root)
+	if err != nil {
+		return "", err
+	}
+
+	full := filepath.Join(root, filepath.FromSlash(key))
+	if full != root && !strings.HasPrefix(full, root+string(filepath.Separator)) {
+		return "", ErrPathTraversal
+	}
+	return full, nil
+}
+
+func (l *Local) Put(filename, folder string) error {
+	dst, err := l.fullPath(path.Join(folder, path.Base(filename)))
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return err
+	}
+
+	return copyFile(filename, dst)
+}
+
+func (l *Local) Get(destination string, items ...string) error {
+	for _, item := range items {
+		src, err := l.fullPath(item)
+		if err != nil {
+			return err
+		}
+
+		err = copyFile(src, filepath.Join(destination, path.Base(item)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Local) List(prefix string) ([]filesystems.Listing, error) {
+	var listing []filesystems.Listing
+
+	dir, err := l.fullPath(prefix)
+	if err != nil {
+		return listing, err
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return listing, err
+	}
+
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			return listing, err
+		}
+
+		b := float64(info.Size())
+		kb := b / 1024
+		mb := kb / 1024
+		listing = append(listing, filesystems.Listing{
+			Key:          path.Join(strings.TrimPrefix(prefix, "/"), file.Name()),
+			Size:         mb,
+			LastModified: info.ModTime(),
+			IsDir:        file.IsDir(),
+		})
+	}
+	return listing, nil
+}
+
+func (l *Local) Delete(itemsToDel []string) bool {
+	for _, item := range itemsToDel {
+		full, err := l.fullPath(item)
+		if err != nil {
+			return false
+		}
+
+		// never remove the root itself
+		root, _ := filepath.Abs(l.Root)
+		if full == root {
+			return false
+		}
+
+		err = os.RemoveAll(full)
+		if err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		return err
+	}
+
+	return out.Sync()
+}
+
+func (l *Local) PutStream(key string, r io.Reader, size int64, contentType string) error {
+	dst, err := l.fullPath(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, r)
+	if err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+func (l *Local) Open(key string) (io.ReadCloser, error) {
+	full, err := l.fullPath(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(full)
+}
+
+func (l *Local) Stat(key string) (filesystems.Listing, error) {
+	full, err := l.fullPath(key)
+	if err != nil {
+		return filesystems.Listing{}, err
+	}
+
+	info, err := os.Stat(full)
+	if err != nil {
+		return filesystems.Listing{}, err
+	}
+
+	b := float64(info.Size())
+	kb := b / 1024
+	mb := kb / 1024
+	return filesystems.Listing{
+		Key:          key,
+		Size:         mb,
+		LastModified: info.ModTime(),
+		IsDir:        info.IsDir(),
+	}, nil
+}
+
+func (l *Local) Exists(key string) (bool, error) {
+	_, err := l.Stat(key)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *Local) Copy(src, dst string) error {
+	from, err := l.fullPath(src)
+	if err != nil {
+		return err
+	}
+
+	to, err := l.fullPath(dst)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(to), 0755)
+	if err != nil {
+		return err
+	}
+
+	return copyFile(from, to)
+}
+
+func (l *Local) Move(src, dst string) error {
+	from, err := l.fullPath(src)
+	if err != nil {
+		return err
+	}
+
+	to, err := l.fullPath(dst)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(to), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(from, to)
+}
@@ -0,0 +1,158 @@
+package localfilesystem
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSource(t *testing.T, name, content string) string {
+	t.Helper()
+	fn := filepath.Join(testSrcDir, name)
+	err := os.WriteFile(fn, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fn
+}
+
+func TestLocal_PutGet(t *testing.T) {
+	fn := writeSource(t, "hello.txt", "hello world")
+
+	err := testLocal.Put(fn, "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := t.TempDir()
+	err = testLocal.Get(dst, "docs/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dst, "hello.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "hello world" {
+		t.Errorf("wrong content; got %q", string(b))
+	}
+}
+
+func TestLocal_List(t *testing.T) {
+	fn := writeSource(t, "list.txt", "abc")
+
+	err := testLocal.Put(fn, "listing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := testLocal.List("listing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 1 || list[0].Key != "listing/list.txt" {
+		t.Errorf("unexpected listing: %+v", list)
+	}
+}
+
+func TestLocal_Delete(t *testing.T) {
+	fn := writeSource(t, "delete.txt", "abc")
+
+	err := testLocal.Put(fn, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !testLocal.Delete([]string{"delete.txt"}) {
+		t.Error("delete failed")
+	}
+
+	if _, err := os.Stat(filepath.Join(testLocal.Root, "delete.txt")); !os.IsNotExist(err) {
+		t.Error("file should have been removed")
+	}
+
+	if testLocal.Delete([]string{"/"}) {
+		t.Error("root directory should never be deleted")
+	}
+}
+
+func TestLocal_PathTraversal(t *testing.T) {
+	fn := writeSource(t, "evil.txt", "abc")
+
+	err := testLocal.Put(fn, "../../")
+	if !errors.Is(err, ErrPathTraversal) {
+		t.Errorf("expected ErrPathTraversal, got %v", err)
+	}
+
+	err = testLocal.Get(t.TempDir(), "../etc/passwd")
+	if !errors.Is(err, ErrPathTraversal) {
+		t.Errorf("expected ErrPathTraversal, got %v", err)
+	}
+
+	_, err = testLocal.List("../")
+	if !errors.Is(err, ErrPathTraversal) {
+		t.Errorf("expected ErrPathTraversal, got %v", err)
+	}
+
+	if testLocal.Delete([]string{"../something"}) {
+		t.Error("delete outside of root should fail")
+	}
+}
+
+func TestLocal_Streams(t *testing.T) {
+	err := testLocal.PutStream("streams/a.txt", strings.NewReader("streamed"), 8, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := testLocal.Open("streams/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(rc)
+	rc.Close()
+
+	if string(b) != "streamed" {
+		t.Errorf("wrong content; got %q", string(b))
+	}
+
+	err = testLocal.Copy("streams/a.txt", "streams/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testLocal.Move("streams/b.txt", "moved/c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exists, err := testLocal.Exists("streams/b.txt")
+	if err != nil || exists {
+		t.Errorf("moved file should not exist; exists %v err %v", exists, err)
+	}
+
+	item, err := testLocal.Stat("moved/c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Key != "moved/c.txt" || item.IsDir {
+		t.Errorf("unexpected stat: %+v", item)
+	}
+
+	_, err = testLocal.Stat("streams/missing.txt")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+
+	err = testLocal.PutStream("../escape.txt", strings.NewReader("x"), 1, "text/plain")
+	if !errors.Is(err, ErrPathTraversal) {
+		t.Errorf("expected ErrPathTraversal, got %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package localfilesystem
+
+import (
+	"log"
+	"os"
+	"testing"
+)
+
+var testLocal Local
+var testSrcDir string
+
+func TestMain(m *testing.M) {
+	root, err := os.MkdirTemp("", "fenix-local-root")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	testSrcDir, err = os.MkdirTemp("", "fenix-local-src")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	testLocal.Root = root
+
+	code := m.Run()
+
+	_ = os.RemoveAll(root)
+	_ = os.RemoveAll(testSrcDir)
+	os.Exit(code)
+}
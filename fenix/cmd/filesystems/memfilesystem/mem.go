@@ -0,0 +1,202 @@
+// Package memfilesystem is an in-memory file system, mostly useful in tests
+package memfilesystem
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wtran29/fenix/fenix/cmd/filesystems"
+)
+
+type file struct {
+	data    []byte
+	modTime time.Time
+}
+
+// Memory keeps every file in a map, keyed by its path. The zero value is ready to use
+type Memory struct {
+	mu    sync.RWMutex
+	files map[string]file
+}
+
+func init() {
+	filesystems.Register("memory", New)
+}
+
+// New creates an empty in-memory file system; a disk configuration has no options
+func New(cfg filesystems.Config) (filesystems.FS, error) {
+	return &Memory{}, nil
+}
+
+// normalize turns a key into the form it is stored under: slash separated, with no leading slash
+func normalize(key string) string {
+	return strings.TrimPrefix(path.Clean("/"+key), "/")
+}
+
+func (m *Memory) Put(filename, folder string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.files == nil {
+		m.files = make(map[string]file)
+	}
+	m.files[normalize(path.Join(folder, path.Base(filename)))] = file{data: data, modTime: time.Now()}
+	return nil
+}
+
+func (m *Memory) Get(destination string, items ...string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, item := range items {
+		f, ok := m.files[normalize(item)]
+		if !ok {
+			return fmt.Errorf("memfilesystem: %s: %w", item, os.ErrNotExist)
+		}
+
+		err := os.WriteFile(filepath.Join(destination, path.Base(item)), f.data, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns every file whose key starts with prefix, sorted by key
+func (m *Memory) List(prefix string) ([]filesystems.Listing, error) {
+	var listing []filesystems.Listing
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	prefix = strings.TrimPrefix(prefix, "/")
+	for key, f := range m.files {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		b := float64(len(f.data))
+		kb := b / 1024
+		mb := kb / 1024
+		listing = append(listing, filesystems.Listing{
+			Key:          key,
+			Size:         mb,
+			LastModified: f.modTime,
+		})
+	}
+
+	sort.Slice(listing, func(i, j int) bool {
+		return listing[i].Key < listing[j].Key
+	})
+	return listing, nil
+}
+
+func (m *Memory) Delete(itemsToDel []string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, item := range itemsToDel {
+		key := normalize(item)
+		if _, ok := m.files[key]; !ok {
+			return false
+		}
+		delete(m.files, key)
+	}
+	return true
+}
+
+func (m *Memory) PutStream(key string, r io.Reader, size int64, contentType string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.files == nil {
+		m.files = make(map[string]file)
+	}
+	m.files[normalize(key)] = file{data: data, modTime: time.Now()}
+	return nil
+}
+
+func (m *Memory) Open(key string) (io.ReadCloser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	f, ok := m.files[normalize(key)]
+	if !ok {
+		return nil, fmt.Errorf("memfilesystem: %s: %w", key, os.ErrNotExist)
+	}
+	return io.NopCloser(bytes.NewReader(f.data)), nil
+}
+
+func (m *Memory) Stat(key string) (filesystems.Listing, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	f, ok := m.files[normalize(key)]
+	if !ok {
+		return filesystems.Listing{}, fmt.Errorf("memfilesystem: %s: %w", key, os.ErrNotExist)
+	}
+
+	b := float64(len(f.data))
+	kb := b / 1024
+	mb := kb / 1024
+	return filesystems.Listing{
+		Key:          normalize(key),
+		Size:         mb,
+		LastModified: f.modTime,
+	}, nil
+}
+
+func (m *Memory) Exists(key string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.files[normalize(key)]
+	return ok, nil
+}
+
+func (m *Memory) Copy(src, dst string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, ok := m.files[normalize(src)]
+	if !ok {
+		return fmt.Errorf("memfilesystem: %s: %w", src, os.ErrNotExist)
+	}
+
+	data := make([]byte, len(f.data))
+	copy(data, f.data)
+	m.files[normalize(dst)] = file{data: data, modTime: time.Now()}
+	return nil
+}
+
+func (m *Memory) Move(src, dst string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, ok := m.files[normalize(src)]
+	if !ok {
+		return fmt.Errorf("memfilesystem: %s: %w", src, os.ErrNotExist)
+	}
+
+	delete(m.files, normalize(src))
+	m.files[normalize(dst)] = f
+	return nil
+}
@@ -0,0 +1,119 @@
+package memfilesystem
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMemory_PutGet(t *testing.T) {
+	fn := filepath.Join(testSrcDir, "hello.txt")
+	err := os.WriteFile(fn, []byte("hello world"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testMemory.Put(fn, "/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := t.TempDir()
+	err = testMemory.Get(dst, "docs/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dst, "hello.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "hello world" {
+		t.Errorf("wrong content; got %q", string(b))
+	}
+
+	err = testMemory.Get(dst, "docs/missing.txt")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Error("expected error getting missing file")
+	}
+}
+
+func TestMemory_ListDelete(t *testing.T) {
+	fn := filepath.Join(testSrcDir, "a.txt")
+	err := os.WriteFile(fn, []byte("a"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = testMemory.Put(fn, "list")
+	_ = testMemory.Put(fn, "other")
+
+	list, err := testMemory.List("list")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 1 || list[0].Key != "list/a.txt" {
+		t.Errorf("unexpected listing: %+v", list)
+	}
+
+	if !testMemory.Delete([]string{"list/a.txt"}) {
+		t.Error("delete failed")
+	}
+
+	if testMemory.Delete([]string{"list/a.txt"}) {
+		t.Error("deleting a missing file should fail")
+	}
+
+	list, _ = testMemory.List("list")
+	if len(list) != 0 {
+		t.Error("file still listed after delete")
+	}
+}
+
+func TestMemory_Streams(t *testing.T) {
+	err := testMemory.PutStream("streams/a.txt", strings.NewReader("streamed"), -1, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := testMemory.Open("/streams/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(rc)
+	rc.Close()
+
+	if string(b) != "streamed" {
+		t.Errorf("wrong content; got %q", string(b))
+	}
+
+	err = testMemory.Copy("streams/a.txt", "streams/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testMemory.Move("streams/b.txt", "moved/c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exists, _ := testMemory.Exists("streams/b.txt")
+	if exists {
+		t.Error("moved file should not exist")
+	}
+
+	exists, _ = testMemory.Exists("moved/c.txt")
+	if !exists {
+		t.Error("moved file should exist at its new key")
+	}
+
+	_, err = testMemory.Stat("missing.txt")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+package memfilesystem
+
+import (
+	"log"
+	"os"
+	"testing"
+)
+
+var testMemory Memory
+var testSrcDir string
+
+func TestMain(m *testing.M) {
+	var err error
+	testSrcDir, err = os.MkdirTemp("", "fenix-mem-src")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	code := m.Run()
+
+	_ = os.RemoveAll(testSrcDir)
+	os.Exit(code)
+}
@@ -2,10 +2,15 @@ package miniofilesystem
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"net/url"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -21,6 +26,22 @@ type Minio struct {
 	Bucket   string
 }
 
+func init() {
+	filesystems.Register("minio", New)
+}
+
+// New creates a MinIO file system from a disk configuration
+func New(cfg filesystems.Config) (filesystems.FS, error) {
+	return &Minio{
+		Endpoint: cfg.Get("endpoint"),
+		Key:      cfg.Get("key"),
+		Secret:   cfg.Get("secret"),
+		UseSSL:   cfg.Bool("usessl"),
+		Region:   cfg.Get("region"),
+		Bucket:   cfg.Get("bucket"),
+	}, nil
+}
+
 func (m *Minio) getCredentials() *minio.Client {
 
 	client, err := minio.New(m.Endpoint, &minio.Options{
@@ -123,3 +144,114 @@ func (m *Minio) Delete(itemsToDel []string) bool {
 
 	return true
 }
+
+func (m *Minio) PutStream(key string, r io.Reader, size int64, contentType string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := m.getCredentials()
+	_, err := client.PutObject(ctx, m.Bucket, key, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Minio) Open(key string) (io.ReadCloser, error) {
+	client := m.getCredentials()
+
+	obj, err := client.GetObject(context.Background(), m.Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, notFound(key, err)
+	}
+
+	// GetObject is lazy; stat the object so a missing key fails here rather than on first read
+	_, err = obj.Stat()
+	if err != nil {
+		_ = obj.Close()
+		return nil, notFound(key, err)
+	}
+	return obj, nil
+}
+
+func (m *Minio) Stat(key string) (filesystems.Listing, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := m.getCredentials()
+	info, err := client.StatObject(ctx, m.Bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return filesystems.Listing{}, notFound(key, err)
+	}
+
+	b := float64(info.Size)
+	kb := b / 1024
+	mb := kb / 1024
+	return filesystems.Listing{
+		Etag:         info.ETag,
+		LastModified: info.LastModified,
+		Key:          info.Key,
+		Size:         mb,
+	}, nil
+}
+
+func (m *Minio) Exists(key string) (bool, error) {
+	_, err := m.Stat(key)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Copy copies src to dst inside the bucket, without downloading it
+func (m *Minio) Copy(src, dst string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := m.getCredentials()
+	_, err := client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: m.Bucket, Object: dst},
+		minio.CopySrcOptions{Bucket: m.Bucket, Object: src},
+	)
+	if err != nil {
+		return notFound(src, err)
+	}
+	return nil
+}
+
+func (m *Minio) Move(src, dst string) error {
+	err := m.Copy(src, dst)
+	if err != nil {
+		return err
+	}
+
+	client := m.getCredentials()
+	return client.RemoveObject(context.Background(), m.Bucket, src, minio.RemoveObjectOptions{})
+}
+
+// notFound wraps fs.ErrNotExist around errors for missing keys
+func notFound(key string, err error) error {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NotFound":
+		return fmt.Errorf("minio: %s: %w", key, fs.ErrNotExist)
+	}
+	return err
+}
+
+// TemporaryURL returns a presigned download link for key, valid for ttl
+func (m *Minio) TemporaryURL(key string, ttl time.Duration) (string, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := m.getCredentials()
+	u, err := client.PresignedGetObject(ctx, m.Bucket, key, ttl, url.Values{})
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
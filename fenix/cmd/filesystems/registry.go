@@ -0,0 +1,76 @@
+package filesystems
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Driver creates a file system from the configuration of a single disk
+type Driver func(cfg Config) (FS, error)
+
+// Config describes one configured disk, e.g. DISK_UPLOADS_DRIVER=s3 and DISK_UPLOADS_BUCKET=uploads
+// give a disk named "uploads" using the "s3" driver with a "bucket" option
+type Config struct {
+	Name    string
+	Driver  string
+	Options map[string]string
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]Driver)
+)
+
+// Register makes a driver available under the given name. It panics if the name
+// is registered twice or the driver is nil, in the same way as database/sql
+func Register(name string, driver Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	name = strings.ToLower(name)
+	if driver == nil {
+		panic("filesystems: Register driver is nil")
+	}
+	if _, dup := drivers[name]; dup {
+		panic("filesystems: Register called twice for driver " + name)
+	}
+	drivers[name] = driver
+}
+
+// Drivers returns a sorted list of the names of the registered drivers
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	var list []string
+	for name := range drivers {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Open creates a file system using the driver named in cfg
+func Open(cfg Config) (FS, error) {
+	driversMu.RLock()
+	driver, ok := drivers[strings.ToLower(cfg.Driver)]
+	driversMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("filesystems: unknown driver %q for disk %q (forgotten import?)", cfg.Driver, cfg.Name)
+	}
+
+	return driver(cfg)
+}
+
+// Get returns the value of an option, or an empty string if it is not set
+func (c Config) Get(key string) string {
+	return c.Options[strings.ToLower(key)]
+}
+
+// Bool returns true if the option is set to "true"
+func (c Config) Bool(key string) bool {
+	return strings.ToLower(c.Get(key)) == "true"
+}
@@ -0,0 +1,59 @@
+package filesystems
+
+import "testing"
+
+func TestRegistry_Open(t *testing.T) {
+	fs, err := Open(Config{Name: "uploads", Driver: "TEST", Options: map[string]string{"bucket": "one"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tfs, ok := fs.(*testFS)
+	if !ok {
+		t.Fatalf("wrong type returned from Open: %T", fs)
+	}
+
+	if tfs.cfg.Get("BUCKET") != "one" {
+		t.Error("option not passed to driver")
+	}
+
+	// two disks sharing one driver
+	other, err := Open(Config{Name: "backups", Driver: "test", Options: map[string]string{"bucket": "two"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if other.(*testFS).cfg.Get("bucket") != "two" {
+		t.Error("second disk should have its own options")
+	}
+}
+
+func TestRegistry_OpenUnknown(t *testing.T) {
+	_, err := Open(Config{Name: "uploads", Driver: "nope"})
+	if err == nil {
+		t.Error("expected error opening unknown driver")
+	}
+}
+
+func TestRegistry_RegisterTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a driver twice should panic")
+		}
+	}()
+
+	Register("test", func(cfg Config) (FS, error) { return nil, nil })
+}
+
+func TestRegistry_Drivers(t *testing.T) {
+	found := false
+	for _, d := range Drivers() {
+		if d == "test" {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Error("test driver not listed")
+	}
+}
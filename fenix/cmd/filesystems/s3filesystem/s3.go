@@ -1,12 +1,16 @@
 package s3filesystem
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"io/fs"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -24,6 +28,24 @@ type S3 struct {
 	Region   string
 	Endpoint string
 	Bucket   string
+	// ACL is the canned ACL given to uploads, e.g. public-read; empty leaves the bucket's default
+	ACL string
+}
+
+func init() {
+	filesystems.Register("s3", New)
+}
+
+// New creates an S3 file system from a disk configuration
+func New(cfg filesystems.Config) (filesystems.FS, error) {
+	return &S3{
+		Key:      cfg.Get("key"),
+		Secret:   cfg.Get("secret"),
+		Region:   cfg.Get("region"),
+		Endpoint: cfg.Get("endpoint"),
+		Bucket:   cfg.Get("bucket"),
+		ACL:      cfg.Get("acl"),
+	}, nil
 }
 
 func (s *S3) getCredentials() *credentials.Credentials {
@@ -31,16 +53,17 @@ func (s *S3) getCredentials() *credentials.Credentials {
 	return client
 }
 
-func (s *S3) Put(filename, folder string) error {
+func (s *S3) newSession() *session.Session {
 	client := s.getCredentials()
 	sess := session.Must(session.NewSession(&aws.Config{
 		Endpoint:    &s.Endpoint,
 		Region:      &s.Region,
 		Credentials: client,
 	}))
+	return sess
+}
 
-	uploader := s3manager.NewUploader(sess)
-
+func (s *S3) Put(filename, folder string) error {
 	f, err := os.Open(filename)
 	if err != nil {
 		return err
@@ -52,36 +75,138 @@ func (s *S3) Put(filename, folder string) error {
 		return err
 	}
 
-	var size = fileInfo.Size()
+	// sniff the content type from the start of the file, then rewind
+	head := make([]byte, 512)
+	n, err := f.Read(head)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	fileType := http.DetectContentType(head[:n])
 
-	buffer := make([]byte, size)
-	_, err = f.Read(buffer)
+	_, err = f.Seek(0, io.SeekStart)
 	if err != nil {
 		return err
 	}
 
-	fileBytes := bytes.NewReader(buffer)
-	fileType := http.DetectContentType(buffer)
+	return s.PutStream(fmt.Sprintf("%s/%s", folder, path.Base(filename)), f, fileInfo.Size(), fileType)
+}
 
-	log.Println("Content-Type", fileType)
-	log.Println("Key", fmt.Sprintf("%s/%s", folder, path.Base(filename)))
+// PutStream uploads r to key; the uploader sends it in parts, so it is never held in memory whole
+func (s *S3) PutStream(key string, r io.Reader, size int64, contentType string) error {
+	uploader := s3manager.NewUploader(s.newSession())
 
-	_, err = uploader.Upload(&s3manager.UploadInput{
+	input := &s3manager.UploadInput{
 		Bucket:      aws.String(s.Bucket),
-		Key:         aws.String(fmt.Sprintf("%s/%s", folder, path.Base(filename))),
-		Body:        fileBytes,
-		ACL:         aws.String("public-read"),
-		ContentType: aws.String(fileType),
-		Metadata: map[string]*string{
-			"Key": aws.String("MetadataValue"),
-		},
-	})
+		Key:         aws.String(key),
+		Body:        r,
+		ContentType: aws.String(contentType),
+	}
+	if s.ACL != "" {
+		input.ACL = aws.String(s.ACL)
+	}
+
+	_, err := uploader.Upload(input)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+func (s *S3) Open(key string) (io.ReadCloser, error) {
+	svc := s3.New(s.newSession())
+
+	out, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, notFound(key, err)
+	}
+	return out.Body, nil
+}
+
+func (s *S3) Stat(key string) (filesystems.Listing, error) {
+	svc := s3.New(s.newSession())
+
+	out, err := svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return filesystems.Listing{}, notFound(key, err)
+	}
+
+	b := float64(aws.Int64Value(out.ContentLength))
+	kb := b / 1024
+	mb := kb / 1024
+	return filesystems.Listing{
+		Etag:         aws.StringValue(out.ETag),
+		LastModified: aws.TimeValue(out.LastModified),
+		Key:          key,
+		Size:         mb,
+	}, nil
+}
+
+func (s *S3) Exists(key string) (bool, error) {
+	_, err := s.Stat(key)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// escapeKey URL encodes each segment of key, leaving the slashes between them as they are
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// Copy copies src to dst inside the bucket, without downloading it
+func (s *S3) Copy(src, dst string) error {
+	svc := s3.New(s.newSession())
+
+	_, err := svc.CopyObject(&s3.CopyObjectInput{
+		Bucket:     aws.String(s.Bucket),
+		CopySource: aws.String(s.Bucket + "/" + escapeKey(src)),
+		Key:        aws.String(dst),
+	})
+	if err != nil {
+		return notFound(src, err)
+	}
+	return nil
+}
+
+func (s *S3) Move(src, dst string) error {
+	err := s.Copy(src, dst)
+	if err != nil {
+		return err
+	}
+
+	svc := s3.New(s.newSession())
+	_, err = svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(src),
+	})
+	return err
+}
+
+// notFound wraps fs.ErrNotExist around errors for missing keys
+func notFound(key string, err error) error {
+	if awsErr, ok := err.(awserr.Error); ok {
+		switch awsErr.Code() {
+		case s3.ErrCodeNoSuchKey, "NotFound":
+			return fmt.Errorf("s3: %s: %w", key, fs.ErrNotExist)
+		}
+	}
+	return err
+}
+
 func (s *S3) List(prefix string) ([]filesystems.Listing, error) {
 	var listing []filesystems.Listing
 
@@ -201,3 +326,14 @@ func (s *S3) Get(destination string, items ...string) error {
 	}
 	return nil
 }
+
+// TemporaryURL returns a presigned download link for key, valid for ttl
+func (s *S3) TemporaryURL(key string, ttl time.Duration) (string, error) {
+	svc := s3.New(s.newSession())
+
+	req, _ := svc.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	return req.Presign(ttl)
+}
@@ -0,0 +1,17 @@
+package s3filesystem
+
+import "testing"
+
+func TestEscapeKey(t *testing.T) {
+	tests := map[string]string{
+		"file.txt":              "file.txt",
+		"folder/my file.txt":    "folder/my%20file.txt",
+		"a/b/100%/c?.txt":       "a/b/100%25/c%3F.txt",
+		"reports/2024/q1#2.pdf": "reports/2024/q1%232.pdf",
+	}
+	for key, expected := range tests {
+		if got := escapeKey(key); got != expected {
+			t.Errorf("escapeKey(%q): expected %q, got %q", key, expected, got)
+		}
+	}
+}
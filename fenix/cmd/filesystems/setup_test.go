@@ -0,0 +1,33 @@
+package filesystems
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+// testFS is a do-nothing file system used to exercise the driver registry
+type testFS struct {
+	cfg Config
+}
+
+func (t *testFS) Put(filename, folder string) error             { return nil }
+func (t *testFS) Get(destination string, items ...string) error { return nil }
+func (t *testFS) List(prefix string) ([]Listing, error)         { return nil, nil }
+func (t *testFS) Delete(itemsToDel []string) bool               { return true }
+func (t *testFS) PutStream(key string, r io.Reader, size int64, contentType string) error {
+	return nil
+}
+func (t *testFS) Open(key string) (io.ReadCloser, error) { return nil, nil }
+func (t *testFS) Stat(key string) (Listing, error)       { return Listing{}, nil }
+func (t *testFS) Exists(key string) (bool, error)        { return false, nil }
+func (t *testFS) Copy(src, dst string) error             { return nil }
+func (t *testFS) Move(src, dst string) error             { return nil }
+
+func TestMain(m *testing.M) {
+	Register("test", func(cfg Config) (FS, error) {
+		return &testFS{cfg: cfg}, nil
+	})
+
+	os.Exit(m.Run())
+}
@@ -1,8 +1,10 @@
 package sftpfilesystem
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path"
@@ -20,6 +22,20 @@ type SFTP struct {
 	Port string
 }
 
+func init() {
+	filesystems.Register("sftp", New)
+}
+
+// New creates an SFTP file system from a disk configuration
+func New(cfg filesystems.Config) (filesystems.FS, error) {
+	return &SFTP{
+		Host: cfg.Get("host"),
+		User: cfg.Get("user"),
+		Pass: cfg.Get("pass"),
+		Port: cfg.Get("port"),
+	}, nil
+}
+
 func (s *SFTP) getCredentials() (*sftp.Client, error) {
 	addr := fmt.Sprintf("%s:%s", s.Host, s.Port)
 	config := &ssh.ClientConfig{
@@ -162,3 +178,125 @@ func (s *SFTP) Get(destination string, items ...string) error {
 	}
 	return nil
 }
+
+func (s *SFTP) PutStream(key string, r io.Reader, size int64, contentType string) error {
+	client, err := s.getCredentials()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if dir := path.Dir(key); dir != "." && dir != "/" {
+		err = client.MkdirAll(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	dst, err := client.Create(key)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, r); err != nil {
+		return err
+	}
+	return nil
+}
+
+// remoteFile closes the ssh connection along with the file it was opened for
+type remoteFile struct {
+	*sftp.File
+	client *sftp.Client
+}
+
+func (f *remoteFile) Close() error {
+	err := f.File.Close()
+	_ = f.client.Close()
+	return err
+}
+
+func (s *SFTP) Open(key string) (io.ReadCloser, error) {
+	client, err := s.getCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := client.Open(key)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return &remoteFile{File: src, client: client}, nil
+}
+
+func (s *SFTP) Stat(key string) (filesystems.Listing, error) {
+	client, err := s.getCredentials()
+	if err != nil {
+		return filesystems.Listing{}, err
+	}
+	defer client.Close()
+
+	file, err := client.Stat(key)
+	if err != nil {
+		return filesystems.Listing{}, err
+	}
+
+	b := float64(file.Size())
+	kb := b / 1024
+	mb := kb / 1024
+	return filesystems.Listing{
+		Key:          key,
+		Size:         mb,
+		LastModified: file.ModTime(),
+		IsDir:        file.IsDir(),
+	}, nil
+}
+
+func (s *SFTP) Exists(key string) (bool, error) {
+	_, err := s.Stat(key)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Copy streams src to dst over a single connection; sftp has no server side copy
+func (s *SFTP) Copy(src, dst string) error {
+	client, err := s.getCredentials()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	in, err := client.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := client.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SFTP) Move(src, dst string) error {
+	client, err := s.getCredentials()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return client.Rename(src, dst)
+}
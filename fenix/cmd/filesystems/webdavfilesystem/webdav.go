@@ -1,8 +1,10 @@
 package webdavfilesystem
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path"
 	"strings"
@@ -17,6 +19,19 @@ type WebDAV struct {
 	Pass string
 }
 
+func init() {
+	filesystems.Register("webdav", New)
+}
+
+// New creates a WebDAV file system from a disk configuration
+func New(cfg filesystems.Config) (filesystems.FS, error) {
+	return &WebDAV{
+		Host: cfg.Get("host"),
+		User: cfg.Get("user"),
+		Pass: cfg.Get("pass"),
+	}, nil
+}
+
 func (w *WebDAV) getCredentials() *gowebdav.Client {
 	client := gowebdav.NewClient(w.Host, w.User, w.Pass)
 	return client
@@ -107,3 +122,73 @@ func (w *WebDAV) Get(destination string, items ...string) error {
 	}
 	return nil
 }
+
+func (w *WebDAV) PutStream(key string, r io.Reader, size int64, contentType string) error {
+	client := w.getCredentials()
+
+	err := client.WriteStream(key, r, 0664)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *WebDAV) Open(key string) (io.ReadCloser, error) {
+	client := w.getCredentials()
+
+	reader, err := client.ReadStream(key)
+	if err != nil {
+		return nil, notFound(key, err)
+	}
+	return reader, nil
+}
+
+func (w *WebDAV) Stat(key string) (filesystems.Listing, error) {
+	client := w.getCredentials()
+
+	file, err := client.Stat(key)
+	if err != nil {
+		return filesystems.Listing{}, notFound(key, err)
+	}
+
+	b := float64(file.Size())
+	kb := b / 1024
+	mb := kb / 1024
+	return filesystems.Listing{
+		LastModified: file.ModTime(),
+		Key:          key,
+		Size:         mb,
+		IsDir:        file.IsDir(),
+	}, nil
+}
+
+func (w *WebDAV) Exists(key string) (bool, error) {
+	_, err := w.Stat(key)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Copy copies src to dst on the server, overwriting dst
+func (w *WebDAV) Copy(src, dst string) error {
+	client := w.getCredentials()
+	return notFound(src, client.Copy(src, dst, true))
+}
+
+// Move renames src to dst on the server, overwriting dst
+func (w *WebDAV) Move(src, dst string) error {
+	client := w.getCredentials()
+	return notFound(src, client.Rename(src, dst, true))
+}
+
+// notFound wraps fs.ErrNotExist around errors for missing items
+func notFound(key string, err error) error {
+	if err != nil && gowebdav.IsErrNotFound(err) {
+		return fmt.Errorf("webdav: %s: %w", key, fs.ErrNotExist)
+	}
+	return err
+}
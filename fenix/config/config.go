@@ -0,0 +1,365 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Config holds every setting Fenix reads at start up. Each field is filled from the environment
+// variable named in its env tag; see Process for the supported tags.
+type Config struct {
+	AppName         string        `env:"APP_NAME"`
+	AppURL          string        `env:"APP_URL"`
+	Debug           bool          `env:"DEBUG"`
+	Port            string        `env:"PORT" default:"4000"`
+	RPCPort         string        `env:"RPC_PORT"`
+	ServerName      string        `env:"SERVER_NAME" default:"localhost"`
+	Secure          bool          `env:"SECURE" default:"true"`
+	Key             string        `env:"KEY" required:"true"`
+	Renderer        string        `env:"RENDERER" default:"jet" oneof:"go,jet"`
+	Cache           string        `env:"CACHE" oneof:"redis,badger,memory"`
+	SessionType     string        `env:"SESSION_TYPE" default:"cookie" oneof:"cookie,redis,mysql,mariadb,postgres,postgresql,badger,sqlite"`
+	SessionDB       string        `env:"SESSION_SQLITE_PATH" default:"tmp/sessions.db"`
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" default:"30s"`
+	// ShutdownDelay is how long /readyz reports not ready before the server stops accepting requests
+	ShutdownDelay time.Duration `env:"SHUTDOWN_DELAY"`
+	// TrustedProxies are the addresses and CIDR ranges of the proxies whose X-Forwarded-For and
+	// X-Real-IP headers are believed; without any, the client address is the peer's
+	TrustedProxies []string `env:"TRUSTED_PROXIES"`
+
+	Cookie   Cookie
+	Database Database
+	Redis    Redis
+	Caching  Caching
+	Limits   RateLimit
+	Queue    Queue
+	Uploads  Uploads
+	Disks    Disks
+	Mail     Mail
+	Log      Log
+	Metrics  Metrics
+
+	// values holds every setting that was read, by name, for Lookup
+	values map[string]string
+}
+
+type Cookie struct {
+	Name string `env:"COOKIE_NAME" default:"fenix"`
+	// Lifetime is in minutes
+	Lifetime int    `env:"COOKIE_LIFETIME" default:"1440"`
+	Persist  bool   `env:"COOKIE_PERSIST" default:"true"`
+	Secure   bool   `env:"COOKIE_SECURE"`
+	Domain   string `env:"COOKIE_DOMAIN" default:"localhost"`
+}
+
+type Database struct {
+	Type    string `env:"DATABASE_TYPE" oneof:"postgres,postgresql,mysql,mariadb"`
+	Host    string `env:"DATABASE_HOST"`
+	Port    int    `env:"DATABASE_PORT"`
+	User    string `env:"DATABASE_USER"`
+	Pass    string `env:"DATABASE_PASS"`
+	Name    string `env:"DATABASE_NAME"`
+	SSLMode string `env:"DATABASE_SSL_MODE" default:"disable" oneof:"disable,allow,prefer,require,verify-ca,verify-full"`
+}
+
+type Redis struct {
+	Host     string `env:"REDIS_HOST"`
+	Password string `env:"REDIS_PASSWORD"`
+	Prefix   string `env:"REDIS_PREFIX"`
+}
+
+// Caching configures the cache chosen by CACHE. The in-process cache is used on its own when CACHE is
+// memory, or in front of redis or badger when CACHE_TIERED is true.
+type Caching struct {
+	MaxEntries int `env:"CACHE_MAX_ENTRIES" default:"10000"`
+	// MaxBytes is in bytes; 0 means no limit
+	MaxBytes int64         `env:"CACHE_MAX_BYTES"`
+	Tiered   bool          `env:"CACHE_TIERED"`
+	L1TTL    time.Duration `env:"CACHE_L1_TTL" default:"60s"`
+	// Codec encodes values stored in redis and badger
+	Codec string `env:"CACHE_CODEC" default:"gob" oneof:"gob,json,msgpack"`
+	// CompressAbove is in bytes; 0 turns compression off
+	CompressAbove int `env:"CACHE_COMPRESS_ABOVE"`
+}
+
+// RateLimit sets the defaults of Fenix.RateLimit, which keeps its counters in the cache chosen by CACHE
+type RateLimit struct {
+	Algorithm string `env:"RATELIMIT_ALGORITHM" default:"fixed" oneof:"fixed,sliding,token"`
+}
+
+// Queue configures the background job queue. Jobs in memory are lost on restart; database keeps them
+// in the jobs table created by "fenix make queue".
+type Queue struct {
+	// Store defaults to redis when a redis connection is configured, then to the database once
+	// "fenix make queue" has created the jobs table, and only then to memory
+	Store       string `env:"QUEUE_STORE" oneof:"memory,redis,database"`
+	Workers     int    `env:"QUEUE_WORKERS" default:"5"`
+	MaxAttempts int    `env:"QUEUE_MAX_ATTEMPTS" default:"5"`
+	// PollInterval is how often idle workers look for jobs pushed by other instances
+	PollInterval time.Duration `env:"QUEUE_POLL_INTERVAL" default:"1s"`
+}
+
+type Uploads struct {
+	AllowedFileTypes []string `env:"ALLOWED_FILETYPES"`
+	// MaxUploadSize is in bytes
+	MaxUploadSize int64 `env:"MAX_UPLOAD_SIZE" default:"10485760"`
+}
+
+// Disks lists the file systems opened at start up. Each disk named in DISKS is set up by its
+// DISK_<NAME>_DRIVER and DISK_<NAME>_<OPTION> settings, so several disks may share a driver. The older
+// S3_*, MINIO_*, SFTP_* and WEBDAV_* settings still create disks named S3, MINIO, SFTP and WEBDAV.
+type Disks struct {
+	Names   []string `env:"DISKS"`
+	Default string   `env:"DISK_DEFAULT"`
+	// List holds every disk with its settings; Load and FromEnv fill it in
+	List []Disk
+}
+
+type Disk struct {
+	Name   string
+	Driver string
+	// Options are the other settings of the disk, keyed by the lower case rest of their name, e.g.
+	// "bucket" for DISK_UPLOADS_BUCKET
+	Options map[string]string
+}
+
+// legacyDisks are the disks created by the settings used before DISKS, with the setting that turns
+// each one on
+var legacyDisks = []struct {
+	name, driver, check string
+}{
+	{"S3", "s3", "S3_KEY"},
+	{"MINIO", "minio", "MINIO_SECRET"},
+	{"SFTP", "sftp", "SFTP_HOST"},
+	{"WEBDAV", "webdav", "WEBDAV_HOST"},
+}
+
+type Mail struct {
+	Domain      string `env:"MAIL_DOMAIN"`
+	Host        string `env:"SMTP_HOST"`
+	Port        int    `env:"SMTP_PORT" default:"1025"`
+	Username    string `env:"SMTP_USERNAME"`
+	Password    string `env:"SMTP_PASSWORD"`
+	Encryption  string `env:"SMTP_ENCRYPTION" default:"none" oneof:"tls,ssl,none"`
+	FromName    string `env:"FROM_NAME"`
+	FromAddress string `env:"FROM_ADDRESS"`
+	API         string `env:"MAILER_API" oneof:"smtp,mailgun,sparkpost,sendgrid"`
+	APIKey      string `env:"MAILER_KEY"`
+	APIUrl      string `env:"MAILER_URL"`
+}
+
+type Log struct {
+	Format string `env:"LOG_FORMAT" default:"text" oneof:"text,json"`
+	// Level defaults to debug when DEBUG is true, and info otherwise
+	Level  string `env:"LOG_LEVEL" oneof:"debug,info,warn,error"`
+	Output string `env:"LOG_OUTPUT" default:"stdout" oneof:"stdout,file,both"`
+	// MaxSize is in megabytes, MaxAge in days
+	MaxSize    int `env:"LOG_MAX_SIZE" default:"100"`
+	MaxBackups int `env:"LOG_MAX_BACKUPS" default:"7"`
+	MaxAge     int `env:"LOG_MAX_AGE" default:"28"`
+}
+
+type Metrics struct {
+	Enabled bool   `env:"METRICS_ENABLED"`
+	Path    string `env:"METRICS_PATH" default:"/metrics"`
+}
+
+// Load reads the configuration for the application in rootPath. Values come from, in order of precedence:
+//
+//  1. the process environment
+//  2. rootPath/.env
+//  3. the file named by CONFIG_FILE, or else config.yaml, config.yml or config.toml in rootPath
+//
+// Neither file has to exist, and neither is exported to the process environment; use Lookup or Get
+// for settings the Config has no field for. Every invalid setting is reported in a single *Error.
+func Load(rootPath string) (*Config, error) {
+	env := environ()
+
+	dotEnv, err := godotenv.Read(filepath.Join(rootPath, ".env"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("config: reading .env: %w", err)
+	}
+
+	configFile, ok := env["CONFIG_FILE"]
+	if !ok {
+		configFile = dotEnv["CONFIG_FILE"]
+	}
+	values, err := readConfigFile(rootPath, configFile)
+	if err != nil {
+		return nil, err
+	}
+	if values == nil {
+		values = make(map[string]string)
+	}
+
+	for k, v := range dotEnv {
+		values[k] = v
+	}
+	for k, v := range env {
+		values[k] = v
+	}
+
+	return fromValues(values)
+}
+
+// FromEnv reads the configuration from the process environment only, without looking for
+// a .env or config file
+func FromEnv() (*Config, error) {
+	return fromValues(environ())
+}
+
+// environ returns the process environment by name
+func environ() map[string]string {
+	values := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			values[k] = v
+		}
+	}
+	return values
+}
+
+// fromValues builds the configuration from values, which holds every setting by name
+func fromValues(values map[string]string) (*Config, error) {
+	cfg := Config{values: values}
+	err := Process(&cfg, cfg.Lookup)
+
+	var fieldErrs *Error
+	if err != nil && !errors.As(err, &fieldErrs) {
+		return nil, err
+	}
+	if fieldErrs == nil {
+		fieldErrs = &Error{}
+	}
+
+	// only report a cross field problem if the setting itself was valid
+	for _, fe := range append(cfg.readDisks(values), cfg.validate()...) {
+		if !fieldErrs.has(fe.Key) {
+			fieldErrs.Fields = append(fieldErrs.Fields, fe)
+		}
+	}
+	sortFieldErrors(fieldErrs.Fields)
+
+	if len(fieldErrs.Fields) > 0 {
+		return nil, fieldErrs
+	}
+
+	return &cfg, nil
+}
+
+// Lookup returns the raw value of the setting named key, wherever it was read from
+func (c *Config) Lookup(key string) (string, bool) {
+	v, ok := c.values[key]
+	return v, ok
+}
+
+// Get returns the raw value of the setting named key, or "" if it is not set. Applications use it
+// for their own settings, such as the keys of social login providers.
+func (c *Config) Get(key string) string {
+	return c.values[key]
+}
+
+// readDisks fills in Disks.List from values, which holds every setting by name
+func (c *Config) readDisks(values map[string]string) []FieldError {
+	var errs []FieldError
+
+	c.Disks.List = nil
+	for _, l := range legacyDisks {
+		if values[l.check] != "" {
+			c.Disks.List = append(c.Disks.List, Disk{
+				Name:    l.name,
+				Driver:  l.driver,
+				Options: withPrefix(values, l.name+"_"),
+			})
+		}
+	}
+
+	for _, name := range c.Disks.Names {
+		prefix := "DISK_" + strings.ToUpper(name) + "_"
+		options := withPrefix(values, prefix)
+		driver := options["driver"]
+		delete(options, "driver")
+		if driver == "" {
+			errs = append(errs, FieldError{Key: prefix + "DRIVER", Err: fmt.Errorf("is required for disk %s in DISKS", name)})
+			continue
+		}
+		c.Disks.List = append(c.Disks.List, Disk{
+			Name:    name,
+			Driver:  driver,
+			Options: options,
+		})
+	}
+
+	return errs
+}
+
+// withPrefix returns every value whose name starts with prefix, keyed by the lower case rest of its
+// name, e.g. S3_BUCKET becomes "bucket" for the prefix S3_
+func withPrefix(values map[string]string, prefix string) map[string]string {
+	options := make(map[string]string)
+	for k, v := range values {
+		if strings.HasPrefix(k, prefix) {
+			options[strings.ToLower(strings.TrimPrefix(k, prefix))] = v
+		}
+	}
+	return options
+}
+
+// validate checks the rules that depend on more than one setting
+func (c *Config) validate() []FieldError {
+	var errs []FieldError
+
+	if c.Key != "" && len(c.Key) != 32 {
+		errs = append(errs, FieldError{Key: "KEY", Err: fmt.Errorf("must be exactly 32 characters long, got %d", len(c.Key))})
+	}
+
+	if c.Database.Type != "" {
+		for key, value := range map[string]string{
+			"DATABASE_HOST": c.Database.Host,
+			"DATABASE_USER": c.Database.User,
+			"DATABASE_NAME": c.Database.Name,
+		} {
+			if value == "" {
+				errs = append(errs, FieldError{Key: key, Err: fmt.Errorf("is required when DATABASE_TYPE is %s", c.Database.Type)})
+			}
+		}
+	}
+
+	if c.Redis.Host == "" {
+		if c.Cache == "redis" {
+			errs = append(errs, FieldError{Key: "REDIS_HOST", Err: errors.New("is required when CACHE is redis")})
+		} else if c.SessionType == "redis" {
+			errs = append(errs, FieldError{Key: "REDIS_HOST", Err: errors.New("is required when SESSION_TYPE is redis")})
+		} else if c.Queue.Store == "redis" {
+			errs = append(errs, FieldError{Key: "REDIS_HOST", Err: errors.New("is required when QUEUE_STORE is redis")})
+		}
+	}
+
+	if c.Caching.Tiered && c.Cache != "redis" && c.Cache != "badger" {
+		errs = append(errs, FieldError{Key: "CACHE_TIERED", Err: errors.New("needs CACHE to be redis or badger")})
+	}
+
+	switch c.SessionType {
+	case "mysql", "mariadb", "postgres", "postgresql":
+		if c.Database.Type == "" {
+			errs = append(errs, FieldError{Key: "SESSION_TYPE", Err: fmt.Errorf("%s sessions need DATABASE_TYPE to be set", c.SessionType)})
+		}
+	}
+
+	if c.Queue.Store == "database" && c.Database.Type == "" {
+		errs = append(errs, FieldError{Key: "QUEUE_STORE", Err: errors.New("a database queue needs DATABASE_TYPE to be set")})
+	}
+
+	if c.Uploads.MaxUploadSize <= 0 {
+		errs = append(errs, FieldError{Key: "MAX_UPLOAD_SIZE", Err: errors.New("must be greater than zero")})
+	}
+
+	return errs
+}
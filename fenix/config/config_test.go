@@ -0,0 +1,251 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testSettings struct {
+	Name    string        `env:"NAME" required:"true"`
+	Port    int           `env:"PORT" default:"4000"`
+	Debug   bool          `env:"DEBUG"`
+	Driver  string        `env:"DRIVER" default:"jet" oneof:"go,jet"`
+	Timeout time.Duration `env:"TIMEOUT" default:"30"`
+	Types   []string      `env:"TYPES"`
+	Nested  struct {
+		Size int64 `env:"NESTED_SIZE" default:"10"`
+	}
+}
+
+func mapLookup(m map[string]string) Lookup {
+	return func(key string) (string, bool) {
+		v, ok := m[key]
+		return v, ok
+	}
+}
+
+func TestProcess(t *testing.T) {
+	var s testSettings
+	err := Process(&s, mapLookup(map[string]string{
+		"NAME":        "fenix",
+		"DEBUG":       "true",
+		"DRIVER":      "GO",
+		"TIMEOUT":     "1m",
+		"TYPES":       "image/png, image/gif,",
+		"NESTED_SIZE": "",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Name != "fenix" || s.Port != 4000 || !s.Debug || s.Driver != "go" {
+		t.Errorf("unexpected settings: %+v", s)
+	}
+	if s.Timeout != time.Minute {
+		t.Errorf("expected timeout of 1m, got %s", s.Timeout)
+	}
+	if !reflect.DeepEqual(s.Types, []string{"image/png", "image/gif"}) {
+		t.Errorf("unexpected types %v", s.Types)
+	}
+	if s.Nested.Size != 10 {
+		t.Errorf("expected default nested size of 10, got %d", s.Nested.Size)
+	}
+}
+
+func TestProcess_Errors(t *testing.T) {
+	var s testSettings
+	err := Process(&s, mapLookup(map[string]string{
+		"PORT":    "forty",
+		"DRIVER":  "blade",
+		"TIMEOUT": "soon",
+	}))
+
+	var cfgErr *Error
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+
+	var keys []string
+	for _, f := range cfgErr.Fields {
+		keys = append(keys, f.Key)
+	}
+	expected := []string{"DRIVER", "NAME", "PORT", "TIMEOUT"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected errors for %v, got %v", expected, keys)
+	}
+
+	for _, key := range expected {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error message does not mention %s: %s", key, err)
+		}
+	}
+}
+
+func TestProcess_NotAStruct(t *testing.T) {
+	var s string
+	if err := Process(&s, mapLookup(nil)); err == nil {
+		t.Error("expected error processing a non struct")
+	}
+}
+
+// notExported checks that Load leaves the settings it read from files out of the environment
+func notExported(t *testing.T, keys ...string) {
+	for _, key := range keys {
+		if _, ok := os.LookupEnv(key); ok {
+			t.Fatalf("%s is already set in the environment", key)
+		}
+	}
+	t.Cleanup(func() {
+		for _, key := range keys {
+			if _, ok := os.LookupEnv(key); ok {
+				t.Errorf("%s was exported to the environment", key)
+				_ = os.Unsetenv(key)
+			}
+		}
+	})
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	notExported(t, "KEY", "DATABASE_TYPE", "DATABASE_HOST", "DATABASE_USER", "DATABASE_NAME", "ALLOWED_FILETYPES", "GITHUB_KEY")
+
+	env := "KEY=abcdefghijklmnopqrstuvwxyz123456\nDATABASE_TYPE=postgres\nGITHUB_KEY=github\n"
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(env), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	yml := `database:
+  type: mysql
+  host: localhost
+  user: fenix
+  name: fenix
+allowed_filetypes:
+  - image/png
+  - image/gif
+cache: badger
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.yml"), []byte(yml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the process environment wins over both files
+	t.Setenv("CACHE", "redis")
+	t.Setenv("REDIS_HOST", "localhost:6379")
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Database.Type != "postgres" {
+		t.Errorf("expected .env to win over config file, got %s", cfg.Database.Type)
+	}
+	if cfg.Database.Host != "localhost" || cfg.Database.SSLMode != "disable" {
+		t.Errorf("unexpected database settings: %+v", cfg.Database)
+	}
+	if cfg.Cache != "redis" {
+		t.Errorf("expected environment to win over config file, got %s", cfg.Cache)
+	}
+	if !reflect.DeepEqual(cfg.Uploads.AllowedFileTypes, []string{"image/png", "image/gif"}) {
+		t.Errorf("unexpected file types %v", cfg.Uploads.AllowedFileTypes)
+	}
+	if cfg.ShutdownTimeout != 30*time.Second {
+		t.Errorf("expected default shutdown timeout, got %s", cfg.ShutdownTimeout)
+	}
+	if cfg.Get("GITHUB_KEY") != "github" || cfg.Get("DATABASE_TYPE") != "postgres" {
+		t.Errorf("expected Get to return settings from .env, got %q and %q", cfg.Get("GITHUB_KEY"), cfg.Get("DATABASE_TYPE"))
+	}
+}
+
+func TestLoad_TOML(t *testing.T) {
+	dir := t.TempDir()
+	notExported(t, "KEY", "PORT", "COOKIE_NAME", "COOKIE_LIFETIME")
+
+	toml := `key = "abcdefghijklmnopqrstuvwxyz123456"
+port = 8080
+
+[cookie]
+name = "fenix_session"
+lifetime = 60
+`
+	if err := os.WriteFile(filepath.Join(dir, "settings.toml"), []byte(toml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", "settings.toml")
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Port != "8080" || cfg.Cookie.Name != "fenix_session" || cfg.Cookie.Lifetime != 60 {
+		t.Errorf("unexpected settings: port %s, cookie %+v", cfg.Port, cfg.Cookie)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Setenv("KEY", "too-short")
+	t.Setenv("MAX_UPLOAD_SIZE", "ten megabytes")
+	t.Setenv("CACHE", "memcached")
+	t.Setenv("SESSION_TYPE", "redis")
+	t.Setenv("REDIS_HOST", "")
+
+	_, err := Load(dir)
+
+	var cfgErr *Error
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+
+	var keys []string
+	for _, f := range cfgErr.Fields {
+		keys = append(keys, f.Key)
+	}
+	expected := []string{"CACHE", "KEY", "MAX_UPLOAD_SIZE", "REDIS_HOST"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected errors for %v, got %v", expected, keys)
+	}
+}
+
+func TestFromEnv_Disks(t *testing.T) {
+	t.Setenv("KEY", "abcdefghijklmnopqrstuvwxyz123456")
+	t.Setenv("DISKS", "uploads, backups")
+	t.Setenv("DISK_UPLOADS_DRIVER", "local")
+	t.Setenv("DISK_UPLOADS_ROOT", "storage/uploads")
+	t.Setenv("DISK_BACKUPS_DRIVER", "memory")
+	t.Setenv("DISK_DEFAULT", "uploads")
+	t.Setenv("S3_KEY", "key")
+	t.Setenv("S3_BUCKET", "bucket")
+
+	cfg, err := FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Disk{
+		{Name: "S3", Driver: "s3", Options: map[string]string{"key": "key", "bucket": "bucket"}},
+		{Name: "uploads", Driver: "local", Options: map[string]string{"root": "storage/uploads"}},
+		{Name: "backups", Driver: "memory", Options: map[string]string{}},
+	}
+	if !reflect.DeepEqual(cfg.Disks.List, expected) {
+		t.Errorf("expected disks %+v, got %+v", expected, cfg.Disks.List)
+	}
+	if cfg.Disks.Default != "uploads" {
+		t.Errorf("expected default disk uploads, got %s", cfg.Disks.Default)
+	}
+
+	t.Setenv("DISK_BACKUPS_DRIVER", "")
+	_, err = FromEnv()
+
+	var cfgErr *Error
+	if !errors.As(err, &cfgErr) || len(cfgErr.Fields) != 1 || cfgErr.Fields[0].Key != "DISK_BACKUPS_DRIVER" {
+		t.Errorf("expected an error for the missing driver, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// configFiles are looked for in the root path, in order, when CONFIG_FILE is not set
+var configFiles = []string{"config.yaml", "config.yml", "config.toml"}
+
+// readConfigFile reads the YAML or TOML config file, if there is one, and flattens it into
+// environment variable names, so that
+//
+//	database:
+//	  type: postgres
+//
+// becomes DATABASE_TYPE=postgres. Lists become comma separated values. file is the value of
+// CONFIG_FILE, if it was set.
+func readConfigFile(rootPath, file string) (map[string]string, error) {
+	if file != "" && !filepath.IsAbs(file) {
+		file = filepath.Join(rootPath, file)
+	}
+
+	if file == "" {
+		for _, name := range configFiles {
+			candidate := filepath.Join(rootPath, name)
+			if _, err := os.Stat(candidate); err == nil {
+				file = candidate
+				break
+			}
+		}
+	}
+
+	if file == "" {
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("config: reading %s: %w", file, err)
+	}
+
+	tree := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &tree)
+	case ".toml":
+		err = toml.Unmarshal(content, &tree)
+	default:
+		return nil, fmt.Errorf("config: unsupported config file %s; use .yaml, .yml or .toml", file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: parsing %s: %w", file, err)
+	}
+
+	values := make(map[string]string)
+	flatten("", tree, values)
+	return values, nil
+}
+
+func flatten(prefix string, value interface{}, values map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			flatten(joinKey(prefix, k), child, values)
+		}
+	case map[interface{}]interface{}:
+		// yaml.v2 decodes nested maps with interface keys
+		for k, child := range v {
+			flatten(joinKey(prefix, fmt.Sprint(k)), child, values)
+		}
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		values[prefix] = strings.Join(items, ",")
+	case nil:
+		values[prefix] = ""
+	default:
+		values[prefix] = fmt.Sprint(v)
+	}
+}
+
+func joinKey(prefix, key string) string {
+	key = strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+	if prefix == "" {
+		return key
+	}
+	return prefix + "_" + key
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Lookup returns the value of a setting and whether it was set, in the same way as os.LookupEnv
+type Lookup func(key string) (string, bool)
+
+// FieldError describes one misconfigured setting
+type FieldError struct {
+	Key string
+	Err error
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s %s", e.Key, e.Err)
+}
+
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Error lists every misconfigured setting found while loading the configuration
+type Error struct {
+	Fields []FieldError
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "config: %d invalid setting(s)", len(e.Fields))
+	for _, f := range e.Fields {
+		b.WriteString("\n\t")
+		b.WriteString(f.Error())
+	}
+	return b.String()
+}
+
+func (e *Error) has(key string) bool {
+	for _, f := range e.Fields {
+		if f.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Process fills the struct pointed to by dst using lookup. Fields are matched by the following tags:
+//
+//	env:"NAME"          the setting to read; fields without it are skipped, nested structs are walked
+//	default:"value"     used when the setting is missing or empty
+//	required:"true"     the setting must have a value
+//	oneof:"a,b,c"       the value must be one of the list, compared case insensitively
+//
+// Supported field types are string, bool, the int and uint types, float64, time.Duration and []string,
+// which is read as a comma separated list. A duration without a unit is taken to be in seconds.
+// All problems are collected and returned together as an *Error.
+func Process(dst any, lookup Lookup) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return errors.New("config: Process needs a pointer to a struct")
+	}
+
+	var errs []FieldError
+	process(v.Elem(), lookup, &errs)
+
+	if len(errs) > 0 {
+		sortFieldErrors(errs)
+		return &Error{Fields: errs}
+	}
+	return nil
+}
+
+func process(v reflect.Value, lookup Lookup, errs *[]FieldError) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		key := field.Tag.Get("env")
+		if key == "" {
+			if field.Type.Kind() == reflect.Struct {
+				process(v.Field(i), lookup, errs)
+			}
+			continue
+		}
+
+		value, _ := lookup(key)
+		value = strings.TrimSpace(value)
+		if value == "" {
+			value = field.Tag.Get("default")
+		}
+
+		if value == "" {
+			if field.Tag.Get("required") == "true" {
+				*errs = append(*errs, FieldError{Key: key, Err: errors.New("is required")})
+			}
+			continue
+		}
+
+		if oneOf := field.Tag.Get("oneof"); oneOf != "" {
+			allowed := strings.Split(oneOf, ",")
+			value = strings.ToLower(value)
+			if !inList(allowed, value) {
+				*errs = append(*errs, FieldError{Key: key, Err: fmt.Errorf("must be one of %s, got %q", strings.Join(allowed, ", "), value)})
+				continue
+			}
+		}
+
+		if err := setField(v.Field(i), value); err != nil {
+			*errs = append(*errs, FieldError{Key: key, Err: err})
+		}
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setField(f reflect.Value, value string) error {
+	if f.Type() == durationType {
+		if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+			f.SetInt(secs * int64(time.Second))
+			return nil
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("is not a valid duration: %q", value)
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("is not a valid boolean: %q", value)
+		}
+		f.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, f.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("is not a valid integer: %q", value)
+		}
+		f.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, f.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("is not a valid unsigned integer: %q", value)
+		}
+		f.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, f.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("is not a valid number: %q", value)
+		}
+		f.SetFloat(n)
+
+	case reflect.Slice:
+		if f.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported field type %s", f.Type())
+		}
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		f.Set(reflect.ValueOf(list))
+
+	default:
+		return fmt.Errorf("unsupported field type %s", f.Type())
+	}
+
+	return nil
+}
+
+func inList(list []string, s string) bool {
+	for _, item := range list {
+		if strings.TrimSpace(item) == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortFieldErrors(errs []FieldError) {
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Key < errs[j].Key
+	})
+}
@@ -1,13 +1,22 @@
 package fenix
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"net"
+	"net/http"
+	"net/netip"
 	"net/rpc"
-	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/CloudyKit/jet/v6"
@@ -15,51 +24,106 @@ import (
 	"github.com/dgraph-io/badger/v3"
 	"github.com/go-chi/chi/v5"
 	"github.com/gomodule/redigo/redis"
-	"github.com/joho/godotenv"
 	"github.com/robfig/cron/v3"
+	"github.com/wtran29/fenix/fenix/auth"
 	"github.com/wtran29/fenix/fenix/cache"
+	"github.com/wtran29/fenix/fenix/cmd/filesystems"
+	_ "github.com/wtran29/fenix/fenix/cmd/filesystems/localfilesystem"
+	_ "github.com/wtran29/fenix/fenix/cmd/filesystems/memfilesystem"
 	"github.com/wtran29/fenix/fenix/cmd/filesystems/miniofilesystem"
 	"github.com/wtran29/fenix/fenix/cmd/filesystems/s3filesystem"
 	"github.com/wtran29/fenix/fenix/cmd/filesystems/sftpfilesystem"
 	"github.com/wtran29/fenix/fenix/cmd/filesystems/webdavfilesystem"
+	"github.com/wtran29/fenix/fenix/config"
 	"github.com/wtran29/fenix/fenix/mailer"
+	"github.com/wtran29/fenix/fenix/queue"
 	"github.com/wtran29/fenix/fenix/render"
 	"github.com/wtran29/fenix/fenix/session"
 )
 
 const version = "1.0.0"
 
-var redisCache *cache.RedisCache
-var badgerCache *cache.BadgerCache
-
-var redisPool *redis.Pool
-var badgerConn *badger.DB
-
 var maintenanceMode bool
 
 type Fenix struct {
 	AppName       string
 	Debug         bool
 	Version       string
-	ErrorLog      *log.Logger
-	InfoLog       *log.Logger
+	Logger        *slog.Logger
+	Metrics       *Metrics
+	ErrorLog      *log.Logger // Deprecated: use Logger
+	InfoLog       *log.Logger // Deprecated: use Logger
 	RootPath      string
 	Routes        *chi.Mux
 	Render        *render.Render
 	Session       *scs.SessionManager
+	Sessions      *session.Registry
+	Users         auth.UserProvider
+	Gate          *auth.Gate
 	DB            Database
 	JetViews      *jet.Set
-	config        config
+	config        config.Config
 	EncryptionKey string
 	Cache         cache.Cache
 	Scheduler     *cron.Cron
+	Queue         *queue.Queue
 	Mail          mailer.Mail
 	Server        Server
-	FileSystems   map[string]interface{}
+	FileSystems   map[string]filesystems.FS
 	S3            s3filesystem.S3
 	SFTP          sftpfilesystem.SFTP
 	WebDAV        webdavfilesystem.WebDAV
 	Minio         miniofilesystem.Minio
+	serverMu      sync.Mutex
+	server        *http.Server
+	rpcListener   net.Listener
+	mailStop      chan struct{}
+	mailDone      chan struct{}
+	shutdownHooks []ShutdownHook
+	shuttingDown  atomic.Bool
+	defaultDisk   string
+	logCloser     io.Closer
+	healthMu      sync.Mutex
+	healthChecks  map[string]HealthCheck
+	redisPool     *redis.Pool
+	badgerConn    *badger.DB
+	sqliteConn    *sql.DB
+	closers       []closer
+	// trustedProxies are the proxies RealIP takes the client address from
+	trustedProxies []netip.Prefix
+}
+
+// closer is a connection Fenix opened itself, and so closes on shutdown
+type closer struct {
+	name string
+	io.Closer
+}
+
+// closeFunc lets a function be registered with own
+type closeFunc func() error
+
+func (c closeFunc) Close() error {
+	return c()
+}
+
+// own registers c to be closed on shutdown. Connections are closed in the reverse order they were
+// opened, so a connection is closed after everything that was built on it. Anything passed in
+// through an Option belongs to the caller and is never registered.
+func (f *Fenix) own(name string, c io.Closer) {
+	f.closers = append(f.closers, closer{name: name, Closer: c})
+}
+
+// closeOwned closes the connections registered with own, newest first
+func (f *Fenix) closeOwned() error {
+	var errs []error
+	for i := len(f.closers) - 1; i >= 0; i-- {
+		c := f.closers[i]
+		if err := c.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+		}
+	}
+	f.closers = nil
+	return errors.Join(errs...)
 }
 
 type Server struct {
@@ -69,17 +133,31 @@ type Server struct {
 	URL        string
 }
 
-type config struct {
-	port        string
-	renderer    string // represents template engine used
-	cookie      cookieConfig
-	sessionType string
-	database    databaseConfig
-	redis       redisConfig
-	uploads     uploadConfig
+// New initializes Fenix for the application in rootPath, reading its settings from .env, the
+// environment and an optional config file. Use NewWithOptions to supply any of the subsystems.
+func (f *Fenix) New(rootPath string) error {
+	return f.setup(&options{rootPath: rootPath})
 }
 
-func (f *Fenix) New(rootPath string) error {
+// setup builds every subsystem that was not supplied in o. When a step fails, the connections the
+// steps before it opened are closed again; otherwise pools would leak and Badger would keep its
+// directory locked.
+func (f *Fenix) setup(o *options) error {
+	err := f.build(o)
+	if err != nil {
+		_ = f.closeOwned()
+		if f.logCloser != nil {
+			_ = f.logCloser.Close()
+			f.logCloser = nil
+		}
+		return err
+	}
+	return nil
+}
+
+// build does the work of setup
+func (f *Fenix) build(o *options) error {
+	rootPath := o.rootPath
 	pathConfig := initPaths{
 		rootPath:    rootPath,
 		folderNames: []string{"handlers", "migrations", "views", "mail", "data", "public", "tmp", "logs", "middleware", "screenshots"},
@@ -90,134 +168,238 @@ func (f *Fenix) New(rootPath string) error {
 		return err
 	}
 
-	err = f.checkDotEnv(rootPath)
-	if err != nil {
-		return err
+	// read .env, the environment and the optional config file
+	cfg := o.config
+	if cfg == nil {
+		if o.skipDotEnv {
+			cfg, err = config.FromEnv()
+		} else {
+			cfg, err = config.Load(rootPath)
+		}
+		if err != nil {
+			return err
+		}
 	}
 
-	// read .env
-	err = godotenv.Load(rootPath + "/.env")
-	if err != nil {
-		fmt.Println("Error loading .env file")
-		return err
-	}
+	f.config = *cfg
+	f.RootPath = rootPath
+	f.Debug = cfg.Debug
 
 	// create loggers
-	infoLog, errorLog := f.startLoggers()
+	if o.logger != nil {
+		f.Logger = o.logger
+	} else {
+		f.Logger, f.logCloser = f.createLogger()
+	}
+	f.InfoLog, f.ErrorLog = legacyLoggers(f.Logger)
+
+	if cfg.Metrics.Enabled {
+		f.Metrics = NewMetrics()
+	}
 
 	// connect to the database
-	if os.Getenv("DATABASE_TYPE") != "" {
-		db, err := f.OpenDB(os.Getenv("DATABASE_TYPE"), f.BuildDSN())
+	switch {
+	case o.db != nil:
+		f.DB = Database{
+			DataType: cfg.Database.Type,
+			Pool:     o.db,
+		}
+	case cfg.Database.Type != "":
+		db, err := f.OpenDB(cfg.Database.Type, f.BuildDSN())
 		if err != nil {
-			errorLog.Println(err)
-			os.Exit(1)
+			return fmt.Errorf("connecting to database: %w", err)
 		}
+		f.own("database", db)
 		f.DB = Database{
-			DataType: os.Getenv("DATABASE_TYPE"),
+			DataType: cfg.Database.Type,
 			Pool:     db,
 		}
 	}
 
-	scheduler := cron.New()
+	if f.Metrics != nil && f.DB.Pool != nil {
+		err = f.Metrics.WatchDB(f.DB.Pool, cfg.Database.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	var cronOpts []cron.Option
+	if f.Metrics != nil {
+		cronOpts = append(cronOpts, cron.WithChain(f.Metrics.JobWrapper()))
+	}
+	scheduler := cron.New(cronOpts...)
 	f.Scheduler = scheduler
 
-	if os.Getenv("CACHE") == "redis" || os.Getenv("SESSION_TYPE") == "redis" {
-		redisCache = f.createClientRedisCache()
-		f.Cache = redisCache
-		redisPool = redisCache.Conn
+	// an injected redis or badger cache is shared with the session store, but left open on shutdown
+	f.Cache = o.cache
+	switch c := o.cache.(type) {
+	case *cache.RedisCache:
+		f.redisPool = c.Conn
+	case *cache.BadgerCache:
+		f.badgerConn = c.Conn
+	}
+
+	_, haveRedis := o.cache.(*cache.RedisCache)
+	if !haveRedis && (cfg.Cache == "redis" || cfg.SessionType == "redis") {
+		redisCache := f.createClientRedisCache()
+		f.redisPool = redisCache.Conn
+		f.own("redis", f.redisPool)
+		if f.Cache == nil {
+			f.Cache = redisCache
+		}
 	}
 
-	if os.Getenv("CACHE") == "badger" {
-		badgerCache = f.createClientBadgerCache()
+	if f.Cache == nil && cfg.Cache == "badger" {
+		badgerCache, err := f.createClientBadgerCache()
+		if err != nil {
+			return err
+		}
 		f.Cache = badgerCache
-		badgerConn = badgerCache.Conn
+		f.badgerConn = badgerCache.Conn
+		f.own("badger", f.badgerConn)
+	}
+
+	if f.Cache == nil {
+		f.Cache = f.createMemoryCache()
+	}
+
+	var tieredCache *cache.TieredCache
+	if o.cache == nil && cfg.Caching.Tiered {
+		tieredCache = cache.NewTieredCache(f.createMemoryCache(), f.Cache, cfg.Caching.L1TTL)
+		f.own("tiered cache", tieredCache)
+	}
+
+	if f.Metrics != nil {
+		switch c := f.Cache.(type) {
+		case *cache.RedisCache:
+			if c.OnLookup == nil {
+				c.OnLookup = f.Metrics.CacheLookup("redis")
+			}
+		case *cache.BadgerCache:
+			if c.OnLookup == nil {
+				c.OnLookup = f.Metrics.CacheLookup("badger")
+			}
+		case *cache.MemoryCache:
+			if c.OnLookup == nil {
+				c.OnLookup = f.Metrics.CacheLookup("memory")
+			}
+		}
+	}
+
+	// the L2 was instrumented above; wrap it last so the app only ever sees the tiered cache
+	if tieredCache != nil {
+		if f.Metrics != nil {
+			tieredCache.L1.OnLookup = f.Metrics.CacheLookup("memory")
+		}
+		f.Cache = tieredCache
+	}
+
+	// badger sessions share the cache's connection, or open their own
+	if cfg.SessionType == "badger" && o.session == nil && f.badgerConn == nil {
+		f.badgerConn, err = f.createBadgerConn()
+		if err != nil {
+			return err
+		}
+		f.own("badger", f.badgerConn)
+	}
 
+	if f.badgerConn != nil {
+		badgerConn := f.badgerConn
 		_, err = f.Scheduler.AddFunc("@daily", func() {
-			_ = badgerCache.Conn.RunValueLogGC(0.7)
+			_ = badgerConn.RunValueLogGC(0.7)
 		})
 		if err != nil {
 			return err
 		}
 	}
 
-	f.InfoLog = infoLog
-	f.ErrorLog = errorLog
-	f.Debug, _ = strconv.ParseBool(os.Getenv("DEBUG"))
 	f.Version = version
-	f.RootPath = rootPath
-	f.Mail = f.createMailer()
-	f.Routes = f.routes().(*chi.Mux)
-
-	// file uploads
-	uploadTypes := strings.Split(os.Getenv("ALLOWED_FILETYPES"), ",")
-	var mimeTypes []string
-	mimeTypes = append(mimeTypes, uploadTypes...)
 
-	// max upload size
-	var maxUploadSize int64
-	if max, err := strconv.Atoi(os.Getenv("MAX_UPLOAD_SIZE")); err != nil {
-		maxUploadSize = 10 << 20
+	if o.queue != nil {
+		f.Queue = o.queue
 	} else {
-		maxUploadSize = int64(max)
-	}
-
-	f.config = config{
-		port:     os.Getenv("PORT"),
-		renderer: os.Getenv("RENDERER"),
-		cookie: cookieConfig{
-			name:     os.Getenv("COOKIE_NAME"),
-			lifetime: os.Getenv("COOKIE_LIFETIME"),
-			persist:  os.Getenv("COOKIE_PERSISTS"),
-			secure:   os.Getenv("COOKIE_SECURE"),
-			domain:   os.Getenv("COOKIE_DOMAIN"),
-		},
-		sessionType: os.Getenv("SESSION_TYPE"),
-		database: databaseConfig{
-			database: os.Getenv("DATABASE_TYPE"),
-			dsn:      f.BuildDSN(),
-		},
-		redis: redisConfig{
-			host:     os.Getenv("REDIS_HOST"),
-			password: os.Getenv("REDIS_PASSWORD"),
-			prefix:   os.Getenv("REDIS_PREFIX"),
-		},
-		uploads: uploadConfig{
-			maxUploadSize:    maxUploadSize,
-			allowedMimeTypes: mimeTypes,
-		},
+		store := f.queueStore()
+		if store == "redis" && f.redisPool == nil {
+			f.redisPool = f.createRedisPool()
+			f.own("redis", f.redisPool)
+		}
+		f.Queue = f.createQueue(store)
+	}
+	if f.Metrics != nil && f.Queue.OnResult == nil {
+		f.Queue.OnResult = f.Metrics.QueueResult
 	}
 
-	secure := true
-	if strings.ToLower(os.Getenv("SECURE")) == "false" {
-		secure = false
+	if o.mailer != nil {
+		f.Mail = *o.mailer
+	} else {
+		f.Mail = f.createMailer()
+	}
+	if f.Metrics != nil && f.Mail.OnResult == nil {
+		f.Mail.OnResult = f.Metrics.MailResult
+	}
+	if f.Mail.JobQueue == nil {
+		f.Mail.UseQueue(f.Queue)
 	}
 
 	f.Server = Server{
-		ServerName: os.Getenv("SERVER_NAME"),
-		Port:       os.Getenv("PORT"),
-		Secure:     secure,
-		URL:        os.Getenv("APP_URL"),
+		ServerName: cfg.ServerName,
+		Port:       cfg.Port,
+		Secure:     cfg.Secure,
+		URL:        cfg.AppURL,
 	}
 
 	// create session
+	if o.session != nil {
+		f.Session = o.session
+	} else {
+		sess := session.Session{
+			CookieLifetime: strconv.Itoa(cfg.Cookie.Lifetime),
+			CookiePersist:  strconv.FormatBool(cfg.Cookie.Persist),
+			CookieName:     cfg.Cookie.Name,
+			SessionType:    cfg.SessionType,
+			CookieDomain:   cfg.Cookie.Domain,
+			CookieSecure:   strconv.FormatBool(cfg.Cookie.Secure),
+			Logger:         f.Logger,
+		}
 
-	sess := session.Session{
-		CookieLifetime: f.config.cookie.lifetime,
-		CookiePersist:  f.config.cookie.persist,
-		CookieName:     f.config.cookie.name,
-		SessionType:    f.config.sessionType,
-		CookieDomain:   f.config.cookie.domain,
+		switch cfg.SessionType {
+		case "redis":
+			sess.RedisPool = f.redisPool
+		case "mysql", "postgres", "postgresql", "mariadb":
+			sess.DBPool = f.DB.Pool
+		case "badger":
+			sess.BadgerConn = f.badgerConn
+		case "sqlite":
+			f.sqliteConn, err = session.OpenSQLite(f.sessionDBPath())
+			if err != nil {
+				return fmt.Errorf("failed to open the session database: %w", err)
+			}
+			f.own("sqlite", f.sqliteConn)
+			sess.DBPool = f.sqliteConn
+		}
+
+		f.Session = sess.InitSession()
+
+		// stop removing expired sessions before the database is closed
+		if store, ok := f.Session.Store.(*session.SQLiteStore); ok {
+			f.own("sqlite session cleanup", closeFunc(func() error {
+				store.StopCleanup()
+				return nil
+			}))
+		}
 	}
+	f.Sessions = f.createSessionRegistry()
+	f.Gate = &auth.Gate{Logger: f.Logger}
 
-	switch f.config.sessionType {
-	case "redis":
-		sess.RedisPool = redisCache.Conn
-	case "mysql", "postgres", "postgresql", "mariadb":
-		sess.DBPool = f.DB.Pool
+	f.EncryptionKey = cfg.Key
+
+	f.trustedProxies, err = parseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return err
 	}
 
-	f.Session = sess.InitSession()
-	f.EncryptionKey = os.Getenv("KEY")
+	// the routes need the session, so they are built after it
+	f.Routes = f.routes().(*chi.Mux)
 
 	if f.Debug {
 		var views = jet.NewSet(
@@ -235,9 +417,27 @@ func (f *Fenix) New(rootPath string) error {
 		f.JetViews = views
 	}
 
-	f.createRenderer()
-	f.FileSystems = f.createFileSystems()
-	go f.Mail.ListenForMail()
+	if o.renderer != nil {
+		f.Render = o.renderer
+	} else {
+		f.createRenderer()
+	}
+
+	f.FileSystems, err = f.createFileSystems()
+	if err != nil {
+		return err
+	}
+
+	f.Queue.Start()
+
+	if f.Mail.Jobs != nil {
+		f.mailStop = make(chan struct{})
+		f.mailDone = make(chan struct{})
+		go func() {
+			f.Mail.ListenForMail(f.mailStop)
+			close(f.mailDone)
+		}()
+	}
 
 	return nil
 }
@@ -255,72 +455,153 @@ func (f *Fenix) Init(p initPaths) error {
 	return nil
 }
 
-func (f *Fenix) checkDotEnv(path string) error {
-	err := f.CreateDirIfNotExist(fmt.Sprintf("%s/.env", path))
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (f *Fenix) startLoggers() (*log.Logger, *log.Logger) {
-	var infoLog *log.Logger
-	var errorLog *log.Logger
-
-	infoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	errorLog = log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
-
-	return infoLog, errorLog
-}
-
 func (f *Fenix) createRenderer() {
 	renderer := render.Render{
-		Renderer: f.config.renderer,
+		Renderer: f.config.Renderer,
 		RootPath: f.RootPath,
-		Port:     f.config.port,
+		Port:     f.config.Port,
 		JetViews: f.JetViews,
 		Session:  f.Session,
+		Gate:     f.Gate,
+		User:     f.CurrentUser,
 	}
 
 	f.Render = &renderer
 }
 
 func (f *Fenix) createMailer() mailer.Mail {
-	port, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
 	m := mailer.Mail{
-		Domain:      os.Getenv("MAIL_DOMAIN"),
-		Templates:   f.RootPath + "/mail",
-		Host:        os.Getenv("SMTP_HOST"),
-		Port:        port,
-		Username:    os.Getenv("SMTP_USERNAME"),
-		Password:    os.Getenv("SMTP_PASSWORD"),
-		Encryption:  os.Getenv("SMTP_ENCRYPTION"),
-		FromName:    os.Getenv("FROM_NAME"),
-		FromAddress: os.Getenv("FROM_ADDRESS"),
 		Jobs:        make(chan mailer.Message, 20),
 		Results:     make(chan mailer.Result, 20),
-		API:         os.Getenv("MAILER_API"),
-		APIKey:      os.Getenv("MAILER_KEY"),
-		APIUrl:      os.Getenv("MAILER_URL"),
+		Logger:      f.Logger,
+		Domain:      f.config.Mail.Domain,
+		Templates:   f.RootPath + "/mail",
+		Host:        f.config.Mail.Host,
+		Port:        f.config.Mail.Port,
+		Username:    f.config.Mail.Username,
+		Password:    f.config.Mail.Password,
+		Encryption:  f.config.Mail.Encryption,
+		FromName:    f.config.Mail.FromName,
+		FromAddress: f.config.Mail.FromAddress,
+		API:         f.config.Mail.API,
+		APIKey:      f.config.Mail.APIKey,
+		APIUrl:      f.config.Mail.APIUrl,
 	}
 	return m
 }
 
+// createSessionRegistry indexes the sessions of each user next to the session store
+func (f *Fenix) createSessionRegistry() *session.Registry {
+	r := &session.Registry{
+		Manager: f.Session,
+		UserKey: sessionUserKey,
+		Logger:  f.Logger,
+	}
+	// f.Users is set by the app after New, so it is looked up when sessions are revoked
+	r.OnRevoke = func(ctx context.Context, userID string) error {
+		users, ok := f.Users.(auth.RememberForgetter)
+		if !ok {
+			f.Logger.WarnContext(ctx, "remember-me tokens kept after revoking sessions: users cannot forget them", "user", userID)
+			return nil
+		}
+		return users.ForgetRememberTokens(userID)
+	}
+
+	switch f.config.SessionType {
+	case "redis":
+		r.Index = &session.RedisIndex{
+			Conn:   f.redisPool,
+			Prefix: f.config.Redis.Prefix,
+		}
+	case "mysql", "mariadb", "postgres", "postgresql":
+		r.Index = &session.SQLIndex{
+			DB:      f.DB.Pool,
+			Dialect: f.DB.DataType,
+		}
+	case "sqlite":
+		r.Index = &session.SQLIndex{
+			DB:      f.sqliteConn,
+			Dialect: "sqlite",
+		}
+	case "badger":
+		r.Index = &session.BadgerIndex{DB: f.badgerConn}
+	default:
+		r.Index = &session.MemoryIndex{}
+	}
+
+	return r
+}
+
+// queueStore returns QUEUE_STORE or, when it is not set, the most durable store that is available.
+// Jobs in memory, which include queued mail, are lost on restart, so falling back to it is logged.
+func (f *Fenix) queueStore() string {
+	if f.config.Queue.Store != "" {
+		return f.config.Queue.Store
+	}
+
+	if f.redisPool != nil {
+		return "redis"
+	}
+
+	if f.DB.Pool != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		store := queue.SQLStore{DB: f.DB.Pool, Dialect: f.DB.DataType}
+		if store.Ready(ctx) == nil {
+			return "database"
+		}
+	}
+
+	f.Logger.Warn("jobs and queued mail are kept in memory and lost on restart; set QUEUE_STORE to redis or database")
+	return "memory"
+}
+
+func (f *Fenix) createQueue(store string) *queue.Queue {
+	q := &queue.Queue{
+		Workers:      f.config.Queue.Workers,
+		MaxAttempts:  f.config.Queue.MaxAttempts,
+		PollInterval: f.config.Queue.PollInterval,
+		Logger:       f.Logger,
+	}
+
+	switch store {
+	case "redis":
+		q.Store = &queue.RedisStore{
+			Conn:   f.redisPool,
+			Prefix: f.config.Redis.Prefix,
+		}
+	case "database":
+		q.Store = &queue.SQLStore{
+			DB:      f.DB.Pool,
+			Dialect: f.DB.DataType,
+		}
+	default:
+		q.Store = &queue.MemoryStore{}
+	}
+
+	return q
+}
+
 // BuildDSN builds the datasource name of the database, then returns as a string
 func (f *Fenix) BuildDSN() string {
 	var dsn string
 
-	switch os.Getenv("DATABASE_TYPE") {
+	db := f.config.Database
+	switch db.Type {
 	case "postgres", "postgresql":
-		dsn = fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=%s timezone=UTC connect_timeout=5",
-			os.Getenv("DATABASE_HOST"),
-			os.Getenv("DATABASE_PORT"),
-			os.Getenv("DATABASE_USER"),
-			os.Getenv("DATABASE_NAME"),
-			os.Getenv("DATABASE_SSL_MODE"))
+		dsn = fmt.Sprintf("host=%s user=%s dbname=%s sslmode=%s timezone=UTC connect_timeout=5",
+			db.Host,
+			db.User,
+			db.Name,
+			db.SSLMode)
+
+		if db.Port != 0 {
+			dsn = fmt.Sprintf("%s port=%d", dsn, db.Port)
+		}
 
-		if os.Getenv("DATABASE_PASS") != "" {
-			dsn = fmt.Sprintf("%s password=%s", dsn, os.Getenv("DATABASE_PASS"))
+		if db.Pass != "" {
+			dsn = fmt.Sprintf("%s password=%s", dsn, db.Pass)
 		}
 
 	default:
@@ -335,7 +616,7 @@ func (f *Fenix) createRedisPool() *redis.Pool {
 		MaxActive:   10000,
 		IdleTimeout: 240 * time.Second,
 		Dial: func() (redis.Conn, error) {
-			return redis.Dial("tcp", f.config.redis.host, redis.DialPassword(f.config.redis.password))
+			return redis.Dial("tcp", f.config.Redis.Host, redis.DialPassword(f.config.Redis.Password))
 		},
 
 		TestOnBorrow: func(c redis.Conn, t time.Time) error {
@@ -347,87 +628,122 @@ func (f *Fenix) createRedisPool() *redis.Pool {
 
 func (f *Fenix) createClientRedisCache() *cache.RedisCache {
 	client := cache.RedisCache{
-		Conn:   f.createRedisPool(),
-		Prefix: f.config.redis.prefix,
+		Conn:          f.createRedisPool(),
+		Prefix:        f.config.Redis.Prefix,
+		Codec:         f.cacheCodec(),
+		CompressAbove: f.config.Caching.CompressAbove,
 	}
 	return &client
 }
 
-func (f *Fenix) createClientBadgerCache() *cache.BadgerCache {
-	client := cache.BadgerCache{
-		Conn: f.createBadgerConn(),
+func (f *Fenix) cacheCodec() cache.Codec {
+	switch f.config.Caching.Codec {
+	case "json":
+		return cache.JSONCodec{}
+	case "msgpack":
+		return cache.MsgpackCodec{}
+	default:
+		return cache.GobCodec{}
 	}
+}
 
-	return &client
+func (f *Fenix) createMemoryCache() *cache.MemoryCache {
+	return &cache.MemoryCache{
+		MaxEntries: f.config.Caching.MaxEntries,
+		MaxBytes:   f.config.Caching.MaxBytes,
+	}
 }
 
-func (f *Fenix) createBadgerConn() *badger.DB {
-	db, err := badger.Open(badger.DefaultOptions(f.RootPath + "/tmp/badger"))
+func (f *Fenix) createClientBadgerCache() (*cache.BadgerCache, error) {
+	conn, err := f.createBadgerConn()
 	if err != nil {
-		log.Printf("failed to create BadgerDB connection: %v", err)
-		return nil
+		return nil, err
 	}
-	return db
 
-}
+	client := cache.BadgerCache{
+		Conn:          conn,
+		Codec:         f.cacheCodec(),
+		CompressAbove: f.config.Caching.CompressAbove,
+	}
 
-func (f *Fenix) createFileSystems() map[string]interface{} {
-	fileSystems := make(map[string]interface{})
+	return &client, nil
+}
 
-	if os.Getenv("S3_KEY") != "" {
-		s3 := s3filesystem.S3{
-			Key:      os.Getenv("S3_KEY"),
-			Secret:   os.Getenv("S3_SECRET"),
-			Region:   os.Getenv("S3_REGION"),
-			Endpoint: os.Getenv("S3_ENDPOINT"),
-			Bucket:   os.Getenv("S3_BUCKET"),
-		}
-		fileSystems["S3"] = s3
-		f.S3 = s3
+// sessionDBPath is SESSION_SQLITE_PATH, relative to the root path unless it is absolute
+func (f *Fenix) sessionDBPath() string {
+	if filepath.IsAbs(f.config.SessionDB) {
+		return f.config.SessionDB
+	}
+	return filepath.Join(f.RootPath, f.config.SessionDB)
+}
 
+func (f *Fenix) createBadgerConn() (*badger.DB, error) {
+	db, err := badger.Open(badger.DefaultOptions(f.RootPath + "/tmp/badger"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create BadgerDB connection: %w", err)
 	}
+	return db, nil
+}
 
-	if os.Getenv("MINIO_SECRET") != "" {
-		useSSL := false
-		if strings.ToLower(os.Getenv("MINIO_USESSL")) == "true" {
-			useSSL = true
-		}
+// createFileSystems opens every disk in the config's Disks.List
+func (f *Fenix) createFileSystems() (map[string]filesystems.FS, error) {
+	fileSystems := make(map[string]filesystems.FS)
 
-		minio := miniofilesystem.Minio{
-			Endpoint: os.Getenv("MINIO_ENDPOINT"),
-			Key:      os.Getenv("MINIO_KEY"),
-			Secret:   os.Getenv("MINIO_SECRET"),
-			UseSSL:   useSSL,
-			Region:   os.Getenv("MINIO_REGION"),
-			Bucket:   os.Getenv("MINIO_BUCKET"),
+	disks := f.config.Disks.List
+	for _, disk := range disks {
+		fs, err := filesystems.Open(filesystems.Config{
+			Name:    disk.Name,
+			Driver:  disk.Driver,
+			Options: disk.Options,
+		})
+		if err != nil {
+			return nil, err
+		}
+		fileSystems[disk.Name] = fs
+
+		// keep the typed fields populated for existing callers
+		switch v := fs.(type) {
+		case *s3filesystem.S3:
+			f.S3 = *v
+		case *miniofilesystem.Minio:
+			f.Minio = *v
+		case *sftpfilesystem.SFTP:
+			f.SFTP = *v
+		case *webdavfilesystem.WebDAV:
+			f.WebDAV = *v
 		}
-		fileSystems["MINIO"] = minio
-		f.Minio = minio
 	}
 
-	if os.Getenv("SFTP_HOST") != "" {
-		sftp := sftpfilesystem.SFTP{
-			Host: os.Getenv("SFTP_HOST"),
-			User: os.Getenv("SFTP_USER"),
-			Pass: os.Getenv("SFTP_PASS"),
-			Port: os.Getenv("SFTP_PORT"),
+	f.defaultDisk = f.config.Disks.Default
+	if f.defaultDisk == "" && len(disks) == 1 {
+		f.defaultDisk = disks[0].Name
+	}
+	if f.defaultDisk != "" {
+		if _, ok := fileSystems[f.defaultDisk]; !ok {
+			return nil, fmt.Errorf("default disk %s is not configured", f.defaultDisk)
 		}
-		fileSystems["SFTP"] = sftp
-		f.SFTP = sftp
 	}
 
-	if os.Getenv("WEBDAV_HOST") != "" {
-		webdav := webdavfilesystem.WebDAV{
-			Host: os.Getenv("WEBDAV_HOST"),
-			User: os.Getenv("WEBDAV_USER"),
-			Pass: os.Getenv("WEBDAV_PASS"),
-		}
-		fileSystems["WEBDAV"] = webdav
-		f.WebDAV = webdav
+	return fileSystems, nil
+}
+
+// Disk returns the file system configured under name, or the default disk when name is empty.
+// Disk names are matched case insensitively. It returns nil if there is no such disk.
+func (f *Fenix) Disk(name string) filesystems.FS {
+	if name == "" {
+		name = f.defaultDisk
 	}
 
-	return fileSystems
+	if fs, ok := f.FileSystems[name]; ok {
+		return fs
+	}
 
+	for k, fs := range f.FileSystems {
+		if strings.EqualFold(k, name) {
+			return fs
+		}
+	}
+	return nil
 }
 
 type RPCServer struct{}
@@ -443,26 +759,39 @@ func (r *RPCServer) MaintenanceMode(inMaintenanceMode bool, resp *string) error
 	return nil
 }
 
-func (f *Fenix) listenRPC() {
-	// if nothing specified for rpc port, do not start
-	if os.Getenv("RPC_PORT") != "" {
-		f.InfoLog.Println("Starting RPC server on port", os.Getenv("RPC_PORT"))
-		err := rpc.Register(new(RPCServer))
-		if err != nil {
-			f.ErrorLog.Println(err)
-			return
-		}
-		listen, err := net.Listen("tcp", "127.0.0.1:"+os.Getenv("RPC_PORT"))
+// listenRPC opens the listener of the RPC server used by the cli's maintenance mode commands. It
+// returns a nil listener when no RPC_PORT is set.
+func (f *Fenix) listenRPC() (net.Listener, error) {
+	if f.config.RPCPort == "" {
+		return nil, nil
+	}
+
+	f.InfoLog.Println("Starting RPC server on port", f.config.RPCPort)
+	err := rpc.Register(new(RPCServer))
+	if err != nil {
+		return nil, err
+	}
+	return net.Listen("tcp", "127.0.0.1:"+f.config.RPCPort)
+}
+
+// serveRPC accepts RPC connections until Shutdown closes listen
+func (f *Fenix) serveRPC(listen net.Listener) {
+	for {
+		rpcConn, err := listen.Accept()
 		if err != nil {
-			f.ErrorLog.Println(err)
-			return
-		}
-		for {
-			rpcConn, err := listen.Accept()
-			if err != nil {
-				continue
+			// listener closed by Shutdown
+			if errors.Is(err, net.ErrClosed) {
+				return
 			}
-			go rpc.ServeConn(rpcConn)
+			continue
 		}
+		go rpc.ServeConn(rpcConn)
 	}
 }
+
+// Env returns the raw value of the setting named key from the environment, .env or the config file.
+// The files are not exported to the process environment, so applications read their own settings
+// here rather than with os.Getenv.
+func (f *Fenix) Env(key string) string {
+	return f.config.Get(key)
+}
@@ -0,0 +1,188 @@
+package fenix
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/gomodule/redigo/redis"
+)
+
+// HealthCheck reports whether a dependency of the application is reachable; it should give up
+// when ctx is done
+type HealthCheck func(ctx context.Context) error
+
+// readinessTimeout bounds how long /readyz waits for all of the checks
+const readinessTimeout = 5 * time.Second
+
+// ComponentStatus is the result of one health check
+type ComponentStatus struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Readiness is the body of a /readyz response
+type Readiness struct {
+	Status     string                     `json:"status"`
+	Reason     string                     `json:"reason,omitempty"`
+	Components map[string]ComponentStatus `json:"components,omitempty"`
+}
+
+// AddHealthCheck adds a check to /readyz under name, next to the ones Fenix registers for the
+// database, redis, badger, smtp and every disk
+func (f *Fenix) AddHealthCheck(name string, check HealthCheck) {
+	f.healthMu.Lock()
+	defer f.healthMu.Unlock()
+
+	if f.healthChecks == nil {
+		f.healthChecks = make(map[string]HealthCheck)
+	}
+	f.healthChecks[name] = check
+}
+
+// healthz is the liveness probe: if Fenix can answer, it is alive
+func (f *Fenix) healthz(w http.ResponseWriter, r *http.Request) {
+	_ = f.WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz is the readiness probe. It runs every check concurrently and answers 503 if any of them fail,
+// or without running them while the application is in maintenance mode or shutting down.
+func (f *Fenix) readyz(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case f.ShuttingDown():
+		_ = f.WriteJSON(w, http.StatusServiceUnavailable, Readiness{Status: "unavailable", Reason: "shutting down"})
+		return
+	case maintenanceMode:
+		_ = f.WriteJSON(w, http.StatusServiceUnavailable, Readiness{Status: "unavailable", Reason: "maintenance mode"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	result := Readiness{
+		Status:     "ok",
+		Components: f.runHealthChecks(ctx),
+	}
+
+	status := http.StatusOK
+	for _, c := range result.Components {
+		if c.Status != "ok" {
+			result.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	_ = f.WriteJSON(w, status, result)
+}
+
+func (f *Fenix) runHealthChecks(ctx context.Context) map[string]ComponentStatus {
+	checks := f.builtinHealthChecks()
+
+	f.healthMu.Lock()
+	for name, check := range f.healthChecks {
+		checks[name] = check
+	}
+	f.healthMu.Unlock()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]ComponentStatus, len(checks))
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check HealthCheck) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check(ctx)
+			status := ComponentStatus{
+				Status:    "ok",
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				status.Status = "error"
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[name] = status
+			mu.Unlock()
+		}(name, check)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// builtinHealthChecks returns a check for every subsystem that is configured
+func (f *Fenix) builtinHealthChecks() map[string]HealthCheck {
+	checks := make(map[string]HealthCheck)
+
+	if f.DB.Pool != nil {
+		checks["database"] = func(ctx context.Context) error {
+			return f.DB.Pool.PingContext(ctx)
+		}
+	}
+
+	if redisPool := f.redisPool; redisPool != nil {
+		checks["redis"] = func(ctx context.Context) error {
+			conn, err := redisPool.GetContext(ctx)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			_, err = redis.DoContext(conn, ctx, "PING")
+			return err
+		}
+	}
+
+	if badgerConn := f.badgerConn; badgerConn != nil {
+		checks["badger"] = func(ctx context.Context) error {
+			if badgerConn.IsClosed() {
+				return badger.ErrDBClosed
+			}
+			return badgerConn.View(func(txn *badger.Txn) error { return nil })
+		}
+	}
+
+	if f.Mail.Host != "" && (f.Mail.API == "" || f.Mail.API == "smtp") {
+		addr := net.JoinHostPort(f.Mail.Host, strconv.Itoa(f.Mail.Port))
+		checks["smtp"] = func(ctx context.Context) error {
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		}
+	}
+
+	for name, fs := range f.FileSystems {
+		name, fs := name, fs
+		checks["disk:"+name] = func(ctx context.Context) error {
+			// a missing key is fine; any other error means the disk cannot be reached
+			errs := make(chan error, 1)
+			go func() {
+				_, err := fs.Exists(".fenix-health-check")
+				errs <- err
+			}()
+
+			select {
+			case err := <-errs:
+				return err
+			case <-ctx.Done():
+				return fmt.Errorf("disk %s: %w", name, ctx.Err())
+			}
+		}
+	}
+
+	return checks
+}
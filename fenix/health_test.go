@@ -0,0 +1,71 @@
+package fenix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func getReadiness(t *testing.T, f *Fenix) (int, Readiness) {
+	rr := httptest.NewRecorder()
+	f.readyz(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+	var body Readiness
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return rr.Code, body
+}
+
+func TestFenix_Healthz(t *testing.T) {
+	rr := httptest.NewRecorder()
+	testFenix.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/healthz", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected 200 from /healthz, got %d", rr.Code)
+	}
+}
+
+func TestFenix_Readyz(t *testing.T) {
+	code, body := getReadiness(t, &testFenix)
+	if code != http.StatusOK || body.Status != "ok" {
+		t.Errorf("expected ready, got %d %+v", code, body)
+	}
+	if c, ok := body.Components["disk:memory"]; !ok || c.Status != "ok" {
+		t.Errorf("expected a passing check for the memory disk, got %+v", body.Components)
+	}
+
+	f := &Fenix{}
+	f.AddHealthCheck("payments", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+
+	code, body = getReadiness(t, f)
+	if code != http.StatusServiceUnavailable || body.Status != "unavailable" {
+		t.Errorf("expected not ready, got %d %+v", code, body)
+	}
+	if c := body.Components["payments"]; c.Status != "error" || c.Error != "connection refused" {
+		t.Errorf("unexpected payments status %+v", c)
+	}
+}
+
+func TestFenix_ReadyzMaintenanceAndShutdown(t *testing.T) {
+	maintenanceMode = true
+	code, body := getReadiness(t, &testFenix)
+	maintenanceMode = false
+
+	if code != http.StatusServiceUnavailable || body.Reason != "maintenance mode" {
+		t.Errorf("expected not ready in maintenance mode, got %d %+v", code, body)
+	}
+
+	f := &Fenix{}
+	f.shuttingDown.Store(true)
+
+	code, body = getReadiness(t, f)
+	if code != http.StatusServiceUnavailable || body.Reason != "shutting down" {
+		t.Errorf("expected not ready while shutting down, got %d %+v", code, body)
+	}
+}
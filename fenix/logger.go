@@ -0,0 +1,126 @@
+package fenix
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// createLogger builds the structured logger from the LOG_* settings. When LOG_OUTPUT is file or both,
+// logs are written to logs/<app name>.log and rotated by size; the returned closer closes that file.
+func (f *Fenix) createLogger() (*slog.Logger, io.Closer) {
+	cfg := f.config.Log
+
+	var out io.Writer = os.Stdout
+	var closer io.Closer
+
+	if cfg.Output == "file" || cfg.Output == "both" {
+		name := f.config.AppName
+		if name == "" {
+			name = "fenix"
+		}
+
+		rotator := &lumberjack.Logger{
+			Filename:   filepath.Join(f.RootPath, "logs", name+".log"),
+			MaxSize:    cfg.MaxSize,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     cfg.MaxAge,
+		}
+		closer = rotator
+
+		out = rotator
+		if cfg.Output == "both" {
+			out = io.MultiWriter(os.Stdout, rotator)
+		}
+	}
+
+	opts := &slog.HandlerOptions{
+		Level: logLevel(cfg.Level, f.Debug),
+	}
+
+	var handler slog.Handler
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(out, opts)
+	} else {
+		handler = slog.NewTextHandler(out, opts)
+	}
+
+	return slog.New(&requestIDHandler{Handler: handler}), closer
+}
+
+// logLevel parses LOG_LEVEL; an empty level means debug in debug mode and info otherwise
+func logLevel(level string, debug bool) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	case "info":
+		return slog.LevelInfo
+	}
+
+	if debug {
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
+
+// legacyLoggers returns the InfoLog and ErrorLog shims, which write through logger at the info
+// and error levels
+func legacyLoggers(logger *slog.Logger) (*log.Logger, *log.Logger) {
+	return slog.NewLogLogger(logger.Handler(), slog.LevelInfo), slog.NewLogLogger(logger.Handler(), slog.LevelError)
+}
+
+// requestIDHandler adds the request id set by chi's RequestID middleware to every record logged
+// with a request's context, e.g. f.Logger.InfoContext(r.Context(), "user logged in")
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h *requestIDHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := middleware.GetReqID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &requestIDHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *requestIDHandler) WithGroup(name string) slog.Handler {
+	return &requestIDHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// RequestLogger logs every request once it has been served, with its status, size and duration.
+// It replaces chi's Logger middleware in debug mode.
+func (f *Fenix) RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		defer func() {
+			f.Logger.InfoContext(r.Context(), "request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", ww.Status(),
+				"bytes", ww.BytesWritten(),
+				"duration", time.Since(start),
+				"remote_addr", r.RemoteAddr,
+			)
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
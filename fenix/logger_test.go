@@ -0,0 +1,107 @@
+package fenix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/wtran29/fenix/fenix/config"
+)
+
+func TestLogger_RequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(&requestIDHandler{Handler: slog.NewJSONHandler(&buf, nil)})
+
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "host/abc-000001")
+	logger.InfoContext(ctx, "user logged in", "user_id", 1)
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+
+	if line["request_id"] != "host/abc-000001" {
+		t.Errorf("expected request id in log line, got %v", line)
+	}
+
+	// lines logged without a request have no request id
+	buf.Reset()
+	logger.With("component", "scheduler").Info("job ran")
+	if bytes.Contains(buf.Bytes(), []byte("request_id")) {
+		t.Errorf("unexpected request id in %s", buf.String())
+	}
+}
+
+func TestLogger_RequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	f := &Fenix{Logger: slog.New(&requestIDHandler{Handler: slog.NewJSONHandler(&buf, nil)})}
+
+	handler := middleware.RequestID(f.RequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/brew", nil))
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+
+	if line["status"] != float64(http.StatusTeapot) || line["path"] != "/brew" || line["request_id"] == nil {
+		t.Errorf("unexpected request log line %v", line)
+	}
+}
+
+func TestLogger_Levels(t *testing.T) {
+	var tests = []struct {
+		level    string
+		debug    bool
+		expected slog.Level
+	}{
+		{"", false, slog.LevelInfo},
+		{"", true, slog.LevelDebug},
+		{"warn", true, slog.LevelWarn},
+		{"ERROR", false, slog.LevelError},
+	}
+
+	for _, e := range tests {
+		if got := logLevel(e.level, e.debug); got != e.expected {
+			t.Errorf("level %q, debug %v: expected %s, got %s", e.level, e.debug, e.expected, got)
+		}
+	}
+}
+
+func TestLogger_File(t *testing.T) {
+	root := t.TempDir()
+	f := &Fenix{
+		RootPath: root,
+		config: config.Config{
+			AppName: "myapp",
+			Log:     config.Log{Format: "json", Output: "file", MaxSize: 1},
+		},
+	}
+
+	logger, closer := f.createLogger()
+	if closer == nil {
+		t.Fatal("expected a closer for file output")
+	}
+
+	_, errorLog := legacyLoggers(logger)
+	errorLog.Println("something broke")
+	_ = closer.Close()
+
+	content, err := os.ReadFile(filepath.Join(root, "logs", "myapp.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(content, []byte(`"level":"ERROR"`)) || !bytes.Contains(content, []byte("something broke")) {
+		t.Errorf("unexpected log file contents: %s", content)
+	}
+}
@@ -3,7 +3,9 @@ package mailer
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"text/template"
@@ -11,6 +13,7 @@ import (
 
 	apimail "github.com/ainsleyclark/go-mail"
 	"github.com/vanng822/go-premailer/premailer"
+	"github.com/wtran29/fenix/fenix/queue"
 	mail "github.com/xhit/go-simple-mail/v2"
 )
 
@@ -24,11 +27,22 @@ type Mail struct {
 	Encryption  string
 	FromAddress string
 	FromName    string
-	Jobs        chan Message
-	Results     chan Result
-	API         string
-	APIKey      string
-	APIUrl      string
+	// Jobs and Results are shared by every caller, so a caller may read another's result. When
+	// JobQueue is set, messages sent to Jobs are passed on to Queue, and their Result only says
+	// whether the queue took them. Results are dropped when nobody reads them.
+	//
+	// Deprecated: use Queue, which keeps mail until it is sent and reports results by message id.
+	Jobs    chan Message
+	Results chan Result
+	API     string
+	APIKey  string
+	APIUrl  string
+	Logger  *slog.Logger
+	// JobQueue holds mail passed to Queue; it is set by UseQueue
+	JobQueue *queue.Queue
+	// OnResult, if set, is called with the result of every attempt to send a message passed to Queue
+	// or sent by ListenForMail
+	OnResult func(Result)
 }
 
 type Message struct {
@@ -42,32 +56,81 @@ type Message struct {
 }
 
 type Result struct {
+	// ID is the id returned by Queue; it is empty for messages ListenForMail sent itself
+	ID      string
 	Success bool
 	Error   error
+	// Attempt counts the attempts to send a queued message, starting at 1
+	Attempt int
+	// Final is false when a queued message failed but will be tried again
+	Final bool
 }
 
-func (m *Mail) ListenForMail() {
+// ListenForMail sends messages from the Jobs channel until stop or Jobs is closed. Once stop is closed
+// it sends the messages already waiting in Jobs, then returns. Jobs is left open, so that a late
+// sender blocks instead of panicking.
+func (m *Mail) ListenForMail(stop <-chan struct{}) {
 	for {
-		msg := <-m.Jobs
-		err := m.Send(msg)
-		if err != nil {
-			m.Results <- Result{false, err}
-		} else {
-			m.Results <- Result{true, nil}
+		select {
+		case msg, ok := <-m.Jobs:
+			if !ok {
+				return
+			}
+			m.sendFromJobs(msg)
+		case <-stop:
+			for {
+				select {
+				case msg, ok := <-m.Jobs:
+					if !ok {
+						return
+					}
+					m.sendFromJobs(msg)
+				default:
+					return
+				}
+			}
 		}
 	}
 }
 
+// sendFromJobs passes a message taken from Jobs to the job queue, or sends it when there is none,
+// and puts the result on Results
+func (m *Mail) sendFromJobs(msg Message) {
+	var res Result
+	if m.JobQueue != nil {
+		id, err := m.Queue(context.Background(), msg)
+		res = Result{ID: id, Success: err == nil, Error: err, Final: err != nil}
+	} else {
+		err := m.Send(msg)
+		res = Result{Success: err == nil, Error: err, Attempt: 1, Final: true}
+		m.report(res)
+	}
+
+	// the old listener blocked here until someone read the result; nobody has to any more
+	select {
+	case m.Results <- res:
+	default:
+	}
+}
+
 func (m *Mail) Send(msg Message) error {
 	// Determines if using API or SMTP
 	if len(m.API) > 0 && len(m.APIKey) > 0 && len(m.APIUrl) > 0 && m.API != "smtp" {
-		fmt.Println("sending", m.API)
+		m.logger().Debug("sending mail", "transport", m.API, "to", msg.To, "subject", msg.Subject)
 		return m.SelectAPI(msg)
 	}
-	fmt.Println("sending", m.API)
+	m.logger().Debug("sending mail", "transport", "smtp", "to", msg.To, "subject", msg.Subject)
 	return m.SendSMTPMessage(msg)
 }
 
+// logger returns the configured logger, or the default one
+func (m *Mail) logger() *slog.Logger {
+	if m.Logger != nil {
+		return m.Logger
+	}
+	return slog.Default()
+}
+
 func (m *Mail) SelectAPI(msg Message) error {
 	switch m.API {
 	case "mailgun", "sparkpost", "sendgrid":
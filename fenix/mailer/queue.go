@@ -0,0 +1,117 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wtran29/fenix/fenix/queue"
+)
+
+// QueueName is the job queue that mail passed to Queue goes through
+const QueueName = "mail"
+
+// ErrNoQueue is returned by Queue and Status when UseQueue has not been called
+var ErrNoQueue = errors.New("mailer: no job queue; call UseQueue first")
+
+// Status is where a queued message is on its way out
+type Status string
+
+const (
+	// StatusQueued messages wait to be sent, for the first time or again after a failed attempt
+	StatusQueued Status = "queued"
+	// StatusSending messages are being sent right now
+	StatusSending Status = "sending"
+	// StatusDelivered messages were accepted by the mail server or API
+	StatusDelivered Status = "delivered"
+	// StatusFailed messages used up their attempts without being accepted
+	StatusFailed Status = "failed"
+)
+
+// Delivery is the state of a message passed to Queue
+type Delivery struct {
+	ID       string
+	Status   Status
+	Attempts int
+	// Error is from the last failed attempt
+	Error     string
+	UpdatedAt time.Time
+}
+
+// UseQueue sends mail passed to Queue through q, which keeps it until it is accepted and retries
+// failed attempts with its backoff. It adds the handler for QueueName to q.
+func (m *Mail) UseQueue(q *queue.Queue) {
+	m.JobQueue = q
+	q.Handle(QueueName, m.sendJob)
+}
+
+// Queue stores msg to be sent in the background and returns its id, for Status and the results
+// passed to OnResult. Data is stored as JSON, so templates see structs as maps, which they read the
+// same way; attachments must still exist when the message is sent.
+func (m *Mail) Queue(ctx context.Context, msg Message) (string, error) {
+	if m.JobQueue == nil {
+		return "", ErrNoQueue
+	}
+	return m.JobQueue.Push(ctx, QueueName, msg)
+}
+
+// Status returns the state of the message with id. Delivered messages are forgotten after the
+// queue's Retention, after which queue.ErrNotFound is returned.
+func (m *Mail) Status(ctx context.Context, id string) (*Delivery, error) {
+	if m.JobQueue == nil {
+		return nil, ErrNoQueue
+	}
+
+	job, err := m.JobQueue.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Delivery{
+		ID:        job.ID,
+		Status:    StatusQueued,
+		Attempts:  job.Attempts,
+		Error:     job.LastError,
+		UpdatedAt: job.UpdatedAt,
+	}
+	switch job.Status {
+	case queue.StatusRunning:
+		d.Status = StatusSending
+	case queue.StatusDone:
+		d.Status = StatusDelivered
+	case queue.StatusDead:
+		d.Status = StatusFailed
+	}
+	return d, nil
+}
+
+// sendJob sends a queued message; returning the error makes the queue retry it
+func (m *Mail) sendJob(ctx context.Context, job *queue.Job) error {
+	var msg Message
+	if err := job.Decode(&msg); err != nil {
+		err = queue.Permanent(fmt.Errorf("mailer: decoding message: %w", err))
+		m.report(Result{ID: job.ID, Error: err, Attempt: job.Attempts, Final: true})
+		return err
+	}
+
+	err := m.Send(msg)
+	if err != nil {
+		m.logger().Warn("sending mail failed", "id", job.ID, "to", msg.To, "attempt", job.Attempts, "error", err)
+	}
+
+	m.report(Result{
+		ID:      job.ID,
+		Success: err == nil,
+		Error:   err,
+		Attempt: job.Attempts,
+		Final:   err == nil || job.Attempts >= job.MaxAttempts,
+	})
+	return err
+}
+
+func (m *Mail) report(res Result) {
+	if m.OnResult != nil {
+		m.OnResult(res)
+	}
+}
@@ -0,0 +1,99 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wtran29/fenix/fenix/queue"
+)
+
+func waitForStatus(t *testing.T, m *Mail, id string, want Status) *Delivery {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		d, err := m.Status(context.Background(), id)
+		if err == nil && d.Status == want {
+			return d
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("message %s never became %s", id, want)
+	return nil
+}
+
+func TestMail_Queue(t *testing.T) {
+	ctx := context.Background()
+
+	m := mailer
+	m.Jobs, m.Results = nil, nil
+
+	if _, err := m.Queue(ctx, Message{}); !errors.Is(err, ErrNoQueue) {
+		t.Fatalf("expected ErrNoQueue before UseQueue, got %v", err)
+	}
+
+	var mu sync.Mutex
+	results := make(map[string][]Result)
+	m.OnResult = func(res Result) {
+		mu.Lock()
+		results[res.ID] = append(results[res.ID], res)
+		mu.Unlock()
+	}
+
+	q := &queue.Queue{
+		Store:        &queue.MemoryStore{},
+		MaxAttempts:  3,
+		PollInterval: 10 * time.Millisecond,
+		Backoff:      func(int) time.Duration { return 10 * time.Millisecond },
+	}
+	m.UseQueue(q)
+	q.Start()
+	defer func() { _ = q.Stop(ctx) }()
+
+	msg := Message{
+		From:     "me@here.com",
+		FromName: "Joe",
+		To:       "you@there.com",
+		Subject:  "test",
+		Template: "test",
+		Data:     map[string]string{"Name": "Joe"},
+	}
+
+	sent, err := m.Queue(ctx, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg.To = "not_an_email_address"
+	failed, err := m.Queue(ctx, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := waitForStatus(t, &m, sent, StatusDelivered); d.Attempts != 1 {
+		t.Errorf("expected the message to be delivered on the first attempt, got %+v", d)
+	}
+	if d := waitForStatus(t, &m, failed, StatusFailed); d.Attempts != 3 || d.Error == "" {
+		t.Errorf("expected the message to fail after 3 attempts, got %+v", d)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if res := results[sent]; len(res) != 1 || !res[0].Success || !res[0].Final {
+		t.Errorf("expected one final success for %s, got %+v", sent, res)
+	}
+
+	res := results[failed]
+	if len(res) != 3 {
+		t.Fatalf("expected a result for every attempt of %s, got %+v", failed, res)
+	}
+	for i, r := range res {
+		if r.Success || r.Error == nil || r.Attempt != i+1 || r.Final != (i == 2) {
+			t.Errorf("unexpected result for attempt %d: %+v", i+1, r)
+		}
+	}
+}
@@ -53,7 +53,7 @@ func TestMain(m *testing.M) {
 	}
 
 	time.Sleep(2 * time.Second)
-	go mailer.ListenForMail()
+	go mailer.ListenForMail(nil)
 
 	code := m.Run()
 
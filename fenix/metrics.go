@@ -0,0 +1,172 @@
+package fenix
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/robfig/cron/v3"
+	"github.com/wtran29/fenix/fenix/mailer"
+	"github.com/wtran29/fenix/fenix/queue"
+)
+
+// Metrics holds the Prometheus collectors for an application. Each Metrics has its own registry,
+// so several applications (or tests) in one process do not collide.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	cacheLookups *prometheus.CounterVec
+	mailSent     *prometheus.CounterVec
+	jobRuns      *prometheus.CounterVec
+	jobDuration  prometheus.Histogram
+	queueJobs    *prometheus.CounterVec
+}
+
+// NewMetrics creates the collectors and registers them, along with the Go runtime and process collectors
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fenix_http_requests_total",
+			Help: "HTTP requests served, by method, route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "fenix_http_request_duration_seconds",
+			Help:    "Time taken to serve HTTP requests, by method and route pattern.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fenix_cache_lookups_total",
+			Help: "Cache lookups, by backend and result (hit or miss).",
+		}, []string{"backend", "result"}),
+		mailSent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fenix_mail_sent_total",
+			Help: "Mail messages sent, by result (success or failure).",
+		}, []string{"result"}),
+		jobRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fenix_scheduler_job_runs_total",
+			Help: "Scheduled job runs, by result (success or panic).",
+		}, []string{"result"}),
+		jobDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "fenix_scheduler_job_duration_seconds",
+			Help:    "Time taken by scheduled jobs.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		queueJobs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fenix_queue_job_runs_total",
+			Help: "Queued job runs, by queue and result (success, retry or dead).",
+		}, []string{"queue", "result"}),
+	}
+
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.cacheLookups,
+		m.mailSent,
+		m.jobRuns,
+		m.jobDuration,
+		m.queueJobs,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{})
+}
+
+// Middleware counts and times every request by its chi route pattern, e.g. /users/{id}, so that
+// metrics do not grow with every distinct url. Requests that match no route are labelled "unmatched".
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			// "/*" is left when only the mount of the application's routes matched
+			if pattern := rctx.RoutePattern(); pattern != "" && pattern != "/*" {
+				route = pattern
+			}
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		m.httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+		m.httpDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// CacheLookup returns a function for a cache's OnLookup hook that counts hits and misses for backend
+func (m *Metrics) CacheLookup(backend string) func(hit bool) {
+	hits := m.cacheLookups.WithLabelValues(backend, "hit")
+	misses := m.cacheLookups.WithLabelValues(backend, "miss")
+
+	return func(hit bool) {
+		if hit {
+			hits.Inc()
+		} else {
+			misses.Inc()
+		}
+	}
+}
+
+// MailResult is used as the mailer's OnResult hook, counting successful and failed sends
+func (m *Metrics) MailResult(res mailer.Result) {
+	if res.Success {
+		m.mailSent.WithLabelValues("success").Inc()
+	} else {
+		m.mailSent.WithLabelValues("failure").Inc()
+	}
+}
+
+// JobWrapper counts and times scheduled jobs; use it with cron.WithChain
+func (m *Metrics) JobWrapper() cron.JobWrapper {
+	return func(j cron.Job) cron.Job {
+		return cron.FuncJob(func() {
+			start := time.Now()
+			result := "panic"
+
+			defer func() {
+				m.jobRuns.WithLabelValues(result).Inc()
+				m.jobDuration.Observe(time.Since(start).Seconds())
+			}()
+
+			j.Run()
+			result = "success"
+		})
+	}
+}
+
+// QueueResult is used as the job queue's OnResult hook, counting runs by how they ended
+func (m *Metrics) QueueResult(job *queue.Job, err error) {
+	result := "retry"
+	switch job.Status {
+	case queue.StatusDone:
+		result = "success"
+	case queue.StatusDead:
+		result = "dead"
+	}
+	m.queueJobs.WithLabelValues(job.Queue, result).Inc()
+}
+
+// WatchDB exports the connection pool statistics of db, labelled with dbName
+func (m *Metrics) WatchDB(db *sql.DB, dbName string) error {
+	return m.Registry.Register(collectors.NewDBStatsCollector(db, dbName))
+}
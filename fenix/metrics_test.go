@@ -0,0 +1,104 @@
+package fenix
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/wtran29/fenix/fenix/config"
+	"github.com/wtran29/fenix/fenix/mailer"
+)
+
+func scrape(t *testing.T, h http.Handler) string {
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 scraping metrics, got %d", rr.Code)
+	}
+	body, _ := io.ReadAll(rr.Body)
+	return string(body)
+}
+
+func TestMetrics_HTTP(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	infoLog, errorLog := legacyLoggers(logger)
+	f := &Fenix{
+		Logger:   logger,
+		InfoLog:  infoLog,
+		ErrorLog: errorLog,
+		Session:  scs.New(),
+		Metrics:  NewMetrics(),
+		config:   config.Config{Metrics: config.Metrics{Enabled: true, Path: "/metrics"}},
+	}
+	f.Routes = f.routes().(*chi.Mux)
+	f.Routes.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	handler := f.Handler()
+	for _, path := range []string{"/users/1", "/users/2", "/nowhere"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	body := scrape(t, handler)
+
+	for _, expected := range []string{
+		`fenix_http_requests_total{method="GET",route="/users/{id}",status="200"} 2`,
+		`fenix_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`fenix_http_request_duration_seconds_count{method="GET",route="/users/{id}"} 2`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %s in metrics output", expected)
+		}
+	}
+}
+
+func TestMetrics_Subsystems(t *testing.T) {
+	m := NewMetrics()
+
+	lookup := m.CacheLookup("redis")
+	lookup(true)
+	lookup(false)
+	lookup(false)
+
+	m.MailResult(mailer.Result{Success: true})
+	m.MailResult(mailer.Result{Success: false, Error: errors.New("smtp down")})
+
+	job := m.JobWrapper()(jobFunc(func() {}))
+	job.Run()
+
+	db, err := sql.Open("pgx", "host=localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := m.WatchDB(db, "fenix"); err != nil {
+		t.Fatal(err)
+	}
+
+	body := scrape(t, m.Handler())
+
+	for _, expected := range []string{
+		`fenix_cache_lookups_total{backend="redis",result="hit"} 1`,
+		`fenix_cache_lookups_total{backend="redis",result="miss"} 2`,
+		`fenix_mail_sent_total{result="success"} 1`,
+		`fenix_mail_sent_total{result="failure"} 1`,
+		`fenix_scheduler_job_runs_total{result="success"} 1`,
+		`go_sql_open_connections{db_name="fenix"} 0`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %s in metrics output", expected)
+		}
+	}
+}
+
+type jobFunc func()
+
+func (j jobFunc) Run() { j() }
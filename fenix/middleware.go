@@ -3,7 +3,6 @@ package fenix
 import (
 	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
 
 	"github.com/justinas/nosurf"
@@ -16,16 +15,15 @@ func (f *Fenix) SessionLoad(next http.Handler) http.Handler {
 
 func (f *Fenix) NoSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
-	secure, _ := strconv.ParseBool(f.config.cookie.secure)
 
 	csrfHandler.ExemptGlob("/api/*")
 
 	csrfHandler.SetBaseCookie(http.Cookie{
 		HttpOnly: true,
 		Path:     "/",
-		Secure:   secure,
+		Secure:   f.config.Cookie.Secure,
 		SameSite: http.SameSiteStrictMode,
-		Domain:   f.config.cookie.domain,
+		Domain:   f.config.Cookie.Domain,
 	})
 
 	return csrfHandler
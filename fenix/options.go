@@ -0,0 +1,131 @@
+package fenix
+
+import (
+	"database/sql"
+	"log/slog"
+	"os"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/wtran29/fenix/fenix/cache"
+	"github.com/wtran29/fenix/fenix/config"
+	"github.com/wtran29/fenix/fenix/mailer"
+	"github.com/wtran29/fenix/fenix/queue"
+	"github.com/wtran29/fenix/fenix/render"
+)
+
+// Option configures a Fenix created with NewWithOptions
+type Option func(*options)
+
+type options struct {
+	rootPath   string
+	config     *config.Config
+	skipDotEnv bool
+	db         *sql.DB
+	cache      cache.Cache
+	mailer     *mailer.Mail
+	queue      *queue.Queue
+	renderer   *render.Render
+	session    *scs.SessionManager
+	logger     *slog.Logger
+}
+
+// WithRootPath sets the application's root folder; it defaults to the working directory
+func WithRootPath(rootPath string) Option {
+	return func(o *options) {
+		o.rootPath = rootPath
+	}
+}
+
+// WithConfig uses cfg as is instead of reading .env, the environment and config files
+func WithConfig(cfg *config.Config) Option {
+	return func(o *options) {
+		o.config = cfg
+	}
+}
+
+// WithoutDotEnv reads settings from the process environment only, so no .env or config file is needed
+func WithoutDotEnv() Option {
+	return func(o *options) {
+		o.skipDotEnv = true
+	}
+}
+
+// WithDB uses an existing connection pool instead of connecting with the DATABASE_* settings.
+// DB.DataType is still taken from DATABASE_TYPE. The pool belongs to the caller, so Shutdown
+// leaves it open.
+func WithDB(pool *sql.DB) Option {
+	return func(o *options) {
+		o.db = pool
+	}
+}
+
+// WithCache uses c instead of the cache named by CACHE. The cache belongs to the caller, so
+// Shutdown leaves its connection open.
+func WithCache(c cache.Cache) Option {
+	return func(o *options) {
+		o.cache = c
+	}
+}
+
+// WithMailer uses m instead of a mailer built from the SMTP_* and MAILER_* settings. The mail
+// listener is only started if m has a Jobs channel.
+func WithMailer(m mailer.Mail) Option {
+	return func(o *options) {
+		o.mailer = &m
+	}
+}
+
+// WithQueue uses q instead of a job queue built from the QUEUE_* settings. It is started with the
+// application and stopped on shutdown.
+func WithQueue(q *queue.Queue) Option {
+	return func(o *options) {
+		o.queue = q
+	}
+}
+
+// WithRenderer uses r instead of the renderer named by RENDERER
+func WithRenderer(r *render.Render) Option {
+	return func(o *options) {
+		o.renderer = r
+	}
+}
+
+// WithSession uses s instead of a session manager built from the COOKIE_* and SESSION_TYPE settings
+func WithSession(s *scs.SessionManager) Option {
+	return func(o *options) {
+		o.session = s
+	}
+}
+
+// WithLogger uses logger instead of one built from the LOG_* settings; InfoLog and ErrorLog write through it
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// NewWithOptions creates and initializes a Fenix. Anything not supplied through an option is built
+// from the configuration, in the same way as New. It never exits the process; every failure is
+// returned as an error.
+func NewWithOptions(opts ...Option) (*Fenix, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.rootPath == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		o.rootPath = wd
+	}
+
+	f := &Fenix{}
+	err := f.setup(o)
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
@@ -0,0 +1,222 @@
+package fenix
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/wtran29/fenix/fenix/cache"
+	"github.com/wtran29/fenix/fenix/config"
+	"github.com/wtran29/fenix/fenix/mailer"
+	"github.com/wtran29/fenix/fenix/queue"
+	"github.com/wtran29/fenix/fenix/render"
+	"github.com/wtran29/fenix/fenix/session"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Port:        "4000",
+		Key:         "abcdefghijklmnopqrstuvwxyz123456",
+		Renderer:    "jet",
+		SessionType: "cookie",
+		Uploads: config.Uploads{
+			MaxUploadSize: 10 << 20,
+		},
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	sess := scs.New()
+	renderer := &render.Render{Renderer: "go"}
+	jobs := &queue.Queue{Store: &queue.MemoryStore{}}
+
+	f, err := NewWithOptions(
+		WithRootPath(t.TempDir()),
+		WithConfig(testConfig()),
+		WithSession(sess),
+		WithRenderer(renderer),
+		WithMailer(mailer.Mail{FromAddress: "me@here.com"}),
+		WithQueue(jobs),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = jobs.Stop(context.Background()) }()
+
+	if f.Session != sess || f.Render != renderer {
+		t.Error("injected session or renderer was not used")
+	}
+	if f.Mail.FromAddress != "me@here.com" {
+		t.Error("injected mailer was not used")
+	}
+	if f.Queue != jobs {
+		t.Error("injected job queue was not used")
+	}
+	if f.mailDone != nil {
+		t.Error("mail listener started for a mailer without a jobs channel")
+	}
+	if f.EncryptionKey != testConfig().Key {
+		t.Error("encryption key not taken from the config")
+	}
+
+	// applications add their own middleware after New
+	f.Routes.Use(func(next http.Handler) http.Handler { return next })
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/fenix/files/none", nil)
+	f.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for an unsigned link, got %d", rr.Code)
+	}
+}
+
+func TestNewWithOptions_DBError(t *testing.T) {
+	cfg := testConfig()
+	cfg.Database = config.Database{
+		Type:    "postgres",
+		Host:    "127.0.0.1",
+		Port:    1,
+		User:    "fenix",
+		Name:    "fenix",
+		SSLMode: "disable",
+	}
+
+	_, err := NewWithOptions(WithRootPath(t.TempDir()), WithConfig(cfg))
+	if err == nil {
+		t.Error("expected an error connecting to a database that is not there")
+	}
+}
+
+func TestNewWithOptions_WithoutDotEnv(t *testing.T) {
+	t.Setenv("KEY", "")
+	t.Setenv("CACHE", "memcached")
+
+	_, err := NewWithOptions(WithRootPath(t.TempDir()), WithoutDotEnv())
+
+	var cfgErr *config.Error
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("expected a config error, got %v", err)
+	}
+	if len(cfgErr.Fields) != 2 {
+		t.Errorf("expected 2 invalid settings, got %d: %s", len(cfgErr.Fields), err)
+	}
+}
+
+func TestShutdown_ClosesOnlyOwnConnections(t *testing.T) {
+	cfg := testConfig()
+	cfg.SessionType = "badger"
+
+	owner, err := NewWithOptions(WithRootPath(t.TempDir()), WithConfig(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	borrower, err := NewWithOptions(WithRootPath(t.TempDir()), WithConfig(cfg), WithCache(&cache.BadgerCache{Conn: conn}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if owner.badgerConn == borrower.badgerConn {
+		t.Fatal("expected each instance to have its own badger connection")
+	}
+
+	if err := owner.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !owner.badgerConn.IsClosed() {
+		t.Error("expected the connection Fenix opened to be closed")
+	}
+	if conn.IsClosed() {
+		t.Error("shutting down one instance closed the connection of another")
+	}
+
+	if err := borrower.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if conn.IsClosed() {
+		t.Error("expected the injected cache to be left open")
+	}
+}
+
+func TestNewWithOptions_ClosesConnectionsOnError(t *testing.T) {
+	root := t.TempDir()
+	cfg := testConfig()
+	cfg.SessionType = "badger"
+	cfg.Disks.Default = "nope"
+
+	_, err := NewWithOptions(WithRootPath(root), WithConfig(cfg))
+	if err == nil {
+		t.Fatal("expected an error for an unknown default disk")
+	}
+
+	// badger locks its directory until the connection is closed
+	conn, err := badger.Open(badger.DefaultOptions(root + "/tmp/badger").WithLogger(nil))
+	if err != nil {
+		t.Fatalf("expected the badger connection opened before the error to be closed: %s", err)
+	}
+	conn.Close()
+}
+
+func TestNewWithOptions_MailJobsUseQueue(t *testing.T) {
+	f, err := NewWithOptions(WithRootPath(t.TempDir()), WithConfig(testConfig()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Shutdown(context.Background())
+
+	if _, ok := f.Queue.Store.(*queue.MemoryStore); !ok {
+		t.Errorf("expected a memory store without redis or a database, got %T", f.Queue.Store)
+	}
+
+	f.Mail.Jobs <- mailer.Message{To: "you@there.com", Subject: "Hello"}
+
+	var res mailer.Result
+	select {
+	case res = <-f.Mail.Results:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no result for a message sent to Jobs")
+	}
+	if !res.Success || res.ID == "" {
+		t.Fatalf("expected the message to be queued, got %+v", res)
+	}
+
+	if _, err := f.Mail.Status(context.Background(), res.ID); err != nil {
+		t.Errorf("expected the queue to know the message: %s", err)
+	}
+}
+
+func TestShutdown_StopsSQLiteSessionCleanup(t *testing.T) {
+	cfg := testConfig()
+	cfg.SessionType = "sqlite"
+	cfg.SessionDB = "sessions.db"
+
+	f, err := NewWithOptions(WithRootPath(t.TempDir()), WithConfig(cfg))
+	if errors.Is(err, session.ErrNoSQLite) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := f.Session.Store.(*session.SQLiteStore); !ok {
+		t.Fatalf("expected a sqlite session store, got %T", f.Session.Store)
+	}
+
+	if err := f.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.sqliteConn.Ping(); err == nil {
+		t.Error("expected the session database to be closed")
+	}
+}
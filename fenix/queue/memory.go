@@ -0,0 +1,165 @@
+package queue
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps jobs in this process, so they are lost on restart. It is meant for tests and
+// development.
+type MemoryStore struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// copyJob keeps callers from changing a stored job behind the store's back
+func copyJob(j *Job) *Job {
+	c := *j
+	c.Payload = append([]byte(nil), j.Payload...)
+	return &c
+}
+
+func (ms *MemoryStore) Push(ctx context.Context, job *Job) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.jobs == nil {
+		ms.jobs = make(map[string]*Job)
+	}
+	ms.jobs[job.ID] = copyJob(job)
+	return nil
+}
+
+func (ms *MemoryStore) Reserve(ctx context.Context, queues []string, lease time.Duration) (*Job, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
+	for _, name := range queues {
+		var next *Job
+		for _, j := range ms.jobs {
+			if j.Queue != name || (j.Status != StatusPending && j.Status != StatusRunning) || j.RunAt.After(now) {
+				continue
+			}
+			if next == nil || j.RunAt.Before(next.RunAt) {
+				next = j
+			}
+		}
+
+		if next != nil {
+			next.Status = StatusRunning
+			next.Attempts++
+			next.RunAt = now.Add(lease).UTC()
+			next.UpdatedAt = now.UTC()
+			return copyJob(next), nil
+		}
+	}
+	return nil, nil
+}
+
+func (ms *MemoryStore) update(ctx context.Context, job *Job) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.jobs[job.ID]; !ok {
+		return ErrNotFound
+	}
+	ms.jobs[job.ID] = copyJob(job)
+	return nil
+}
+
+func (ms *MemoryStore) Complete(ctx context.Context, job *Job) error {
+	return ms.update(ctx, job)
+}
+
+func (ms *MemoryStore) Retry(ctx context.Context, job *Job) error {
+	return ms.update(ctx, job)
+}
+
+func (ms *MemoryStore) Bury(ctx context.Context, job *Job) error {
+	return ms.update(ctx, job)
+}
+
+func (ms *MemoryStore) Get(ctx context.Context, id string) (*Job, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	j, ok := ms.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyJob(j), nil
+}
+
+func (ms *MemoryStore) Dead(ctx context.Context, queue string) ([]*Job, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var dead []*Job
+	for _, j := range ms.jobs {
+		if j.Queue == queue && j.Status == StatusDead {
+			dead = append(dead, copyJob(j))
+		}
+	}
+	sort.Slice(dead, func(a, b int) bool { return dead[a].UpdatedAt.Before(dead[b].UpdatedAt) })
+	return dead, nil
+}
+
+func (ms *MemoryStore) Requeue(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	j, ok := ms.jobs[id]
+	if !ok || j.Status != StatusDead {
+		return ErrNotFound
+	}
+
+	now := time.Now().UTC()
+	j.Status = StatusPending
+	j.Attempts = 0
+	j.RunAt = now
+	j.UpdatedAt = now
+	return nil
+}
+
+func (ms *MemoryStore) Purge(ctx context.Context, before time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for id, j := range ms.jobs {
+		if j.Status == StatusDone && j.UpdatedAt.Before(before) {
+			delete(ms.jobs, id)
+		}
+	}
+	return nil
+}
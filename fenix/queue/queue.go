@@ -0,0 +1,505 @@
+// Package queue runs background jobs on a pool of workers. Jobs are pushed to named queues with a JSON
+// payload, kept in a Store until they succeed, and retried with exponential backoff when they fail;
+// a job that keeps failing ends up in the dead letter queue of its queue.
+//
+// A job is run at least once. If a worker dies while running one, the job is handed to another worker
+// once its lease runs out, so handlers should be safe to run twice.
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	mrand "math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status is where a job is in its life
+type Status string
+
+const (
+	// StatusPending jobs wait for RunAt to come round
+	StatusPending Status = "pending"
+	// StatusRunning jobs are held by a worker until RunAt, when their lease runs out
+	StatusRunning Status = "running"
+	// StatusDone jobs succeeded; they are kept for Queue.Retention so their status can be looked up
+	StatusDone Status = "done"
+	// StatusDead jobs failed for good and wait in the dead letter queue for Requeue
+	StatusDead Status = "dead"
+)
+
+// Job is a unit of work stored in a queue
+type Job struct {
+	ID      string          `json:"id"`
+	Queue   string          `json:"queue"`
+	Payload json.RawMessage `json:"payload"`
+	Status  Status          `json:"status"`
+	// Attempts counts how often the job has been started
+	Attempts    int    `json:"attempts"`
+	MaxAttempts int    `json:"max_attempts"`
+	LastError   string `json:"last_error,omitempty"`
+	// RunAt is when a pending job is due, or when the lease of a running one runs out
+	RunAt     time.Time `json:"run_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Decode unmarshals the payload of the job into v
+func (j *Job) Decode(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// ErrNotFound is returned when there is no job with the id asked for
+var ErrNotFound = errors.New("queue: job not found")
+
+// Store keeps jobs for a Queue. Every method that takes a job updates it in the store as a whole.
+type Store interface {
+	// Push adds a new job
+	Push(ctx context.Context, job *Job) error
+	// Reserve takes the next due job from one of queues, in that order of preference, and leases it
+	// to the caller until lease from now. The job is returned running, with its attempt counted. It
+	// returns nil and no error when no job is due.
+	Reserve(ctx context.Context, queues []string, lease time.Duration) (*Job, error)
+	// Complete marks a reserved job done
+	Complete(ctx context.Context, job *Job) error
+	// Retry makes a reserved job pending again, due at job.RunAt
+	Retry(ctx context.Context, job *Job) error
+	// Bury moves a reserved job to the dead letter queue
+	Bury(ctx context.Context, job *Job) error
+	// Get returns the job with id, or ErrNotFound
+	Get(ctx context.Context, id string) (*Job, error)
+	// Dead lists the dead letter queue of queue, oldest first
+	Dead(ctx context.Context, queue string) ([]*Job, error)
+	// Requeue makes a dead job pending again with no attempts counted, or returns ErrNotFound if
+	// there is no dead job with id
+	Requeue(ctx context.Context, id string) error
+	// Purge deletes done jobs that finished before before
+	Purge(ctx context.Context, before time.Time) error
+}
+
+// Handler runs a job. Returning an error schedules a retry, or buries the job once it has used up
+// its attempts, or straight away if the error is Permanent.
+type Handler func(ctx context.Context, job *Job) error
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps an error that retrying will not fix, such as a payload that cannot be decoded, so
+// the job goes straight to the dead letter queue
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// ExponentialBackoff waits base before the first retry and twice as long before each one after, up
+// to max, with up to a fifth added at random so failed jobs do not all retry at once
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d + time.Duration(mrand.Int63n(int64(d/5)+1))
+	}
+}
+
+// Queue pushes jobs to a Store and runs them on a pool of workers. The zero value needs only a Store;
+// every other field has a default.
+type Queue struct {
+	Store Store
+	// Workers is how many jobs run at once; it defaults to 5
+	Workers int
+	// PollInterval is how often idle workers look for due jobs; it defaults to a second. Jobs pushed
+	// through this Queue wake an idle worker straight away.
+	PollInterval time.Duration
+	// Lease is how long a job may run before it is handed to another worker; it defaults to 5 minutes.
+	// The context given to the handler is cancelled when the lease runs out.
+	Lease time.Duration
+	// MaxAttempts is how often a job is tried unless it was pushed with Attempts; it defaults to 5
+	MaxAttempts int
+	// Backoff is how long to wait before retrying a job that failed attempt times; it defaults to
+	// ExponentialBackoff(time.Second, time.Hour)
+	Backoff func(attempt int) time.Duration
+	// Retention is how long done jobs are kept so their status can be looked up; it defaults to a day
+	Retention time.Duration
+	// Logger defaults to discarding everything
+	Logger *slog.Logger
+	// OnResult, if set, is called after every run with the job, whose Status tells whether it is done,
+	// pending a retry or dead, and the error of the handler
+	OnResult func(job *Job, err error)
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	names    []string
+	next     atomic.Uint64
+
+	wake     chan struct{}
+	stop     chan struct{}
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	started  bool
+	stopOnce sync.Once
+}
+
+// JobOption configures a job pushed with Push
+type JobOption func(*Job)
+
+// Delay runs the job no sooner than d from now
+func Delay(d time.Duration) JobOption {
+	return func(j *Job) {
+		j.RunAt = time.Now().Add(d)
+	}
+}
+
+// At runs the job no sooner than t
+func At(t time.Time) JobOption {
+	return func(j *Job) {
+		j.RunAt = t
+	}
+}
+
+// Attempts replaces Queue.MaxAttempts for the job
+func Attempts(n int) JobOption {
+	return func(j *Job) {
+		j.MaxAttempts = n
+	}
+}
+
+// Handle runs jobs pushed to the queue called name with h. Workers only take jobs from queues that
+// have a handler, so jobs for a queue without one wait in the store.
+func (q *Queue) Handle(name string, h Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.handlers == nil {
+		q.handlers = make(map[string]Handler)
+	}
+	if _, ok := q.handlers[name]; !ok {
+		q.names = append(q.names, name)
+		sort.Strings(q.names)
+	}
+	q.handlers[name] = h
+}
+
+// Push adds a job with payload, marshalled to JSON, to the queue called name and returns its id
+func (q *Queue) Push(ctx context.Context, name string, payload interface{}, opts ...JobOption) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("queue: encoding payload: %w", err)
+	}
+
+	now := time.Now().UTC()
+	job := &Job{
+		ID:          newID(),
+		Queue:       name,
+		Payload:     data,
+		Status:      StatusPending,
+		MaxAttempts: q.maxAttempts(),
+		RunAt:       now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+	job.RunAt = job.RunAt.UTC()
+
+	if err := q.Store.Push(ctx, job); err != nil {
+		return "", err
+	}
+
+	if !job.RunAt.After(now) {
+		q.notify()
+	}
+	return job.ID, nil
+}
+
+// Get returns the job with id, or ErrNotFound
+func (q *Queue) Get(ctx context.Context, id string) (*Job, error) {
+	return q.Store.Get(ctx, id)
+}
+
+// Dead lists the jobs of the queue called name that failed for good
+func (q *Queue) Dead(ctx context.Context, name string) ([]*Job, error) {
+	return q.Store.Dead(ctx, name)
+}
+
+// Requeue runs a dead job again, with all its attempts
+func (q *Queue) Requeue(ctx context.Context, id string) error {
+	if err := q.Store.Requeue(ctx, id); err != nil {
+		return err
+	}
+	q.notify()
+	return nil
+}
+
+// Start starts the workers, and a janitor that purges done jobs once they are past Retention.
+// Calling it again does nothing.
+func (q *Queue) Start() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.started {
+		return
+	}
+	q.started = true
+
+	q.wake = make(chan struct{}, 1)
+	q.stop = make(chan struct{})
+
+	// jobs keep running after Stop is called, until the context given to Stop is done
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+
+	workers := q.Workers
+	if workers <= 0 {
+		workers = 5
+	}
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.work(ctx)
+	}
+
+	q.wg.Add(1)
+	go q.purge(ctx)
+}
+
+// Stop stops taking new jobs and waits for running ones to finish. If ctx is done first, the running
+// jobs are cancelled, and retried like any other job that failed.
+func (q *Queue) Stop(ctx context.Context) error {
+	q.mu.RLock()
+	started := q.started
+	q.mu.RUnlock()
+	if !started {
+		return nil
+	}
+
+	q.stopOnce.Do(func() { close(q.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// notify wakes an idle worker, if there is one, to take a job that was just pushed
+func (q *Queue) notify() {
+	q.mu.RLock()
+	wake := q.wake
+	q.mu.RUnlock()
+
+	if wake == nil {
+		return
+	}
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Queue) work(ctx context.Context) {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		default:
+		}
+
+		job, err := q.reserve(ctx)
+		if err != nil {
+			q.logger().Error("reserving job", "error", err)
+		}
+		if job == nil {
+			select {
+			case <-q.stop:
+				return
+			case <-q.wake:
+			case <-time.After(q.pollInterval()):
+			}
+			continue
+		}
+
+		q.run(ctx, job)
+	}
+}
+
+// reserve asks for a job from every queue with a handler, starting at a different queue each time so
+// that a busy queue does not starve the others
+func (q *Queue) reserve(ctx context.Context) (*Job, error) {
+	q.mu.RLock()
+	names := make([]string, 0, len(q.names))
+	if n := len(q.names); n > 0 {
+		start := int(q.next.Add(1) % uint64(n))
+		names = append(names, q.names[start:]...)
+		names = append(names, q.names[:start]...)
+	}
+	q.mu.RUnlock()
+
+	if len(names) == 0 {
+		return nil, nil
+	}
+	return q.Store.Reserve(ctx, names, q.lease())
+}
+
+// run runs job and records the outcome in the store
+func (q *Queue) run(ctx context.Context, job *Job) {
+	q.mu.RLock()
+	h := q.handlers[job.Queue]
+	q.mu.RUnlock()
+
+	logger := q.logger().With("queue", job.Queue, "job", job.ID, "attempt", job.Attempts)
+
+	start := time.Now()
+	err := q.call(ctx, h, job)
+	if err == nil {
+		job.Status = StatusDone
+		job.LastError = ""
+	} else {
+		job.LastError = err.Error()
+	}
+	job.UpdatedAt = time.Now().UTC()
+
+	// the outcome must be recorded even when Stop gave up waiting on the job
+	storeCtx := context.WithoutCancel(ctx)
+
+	var storeErr error
+	var permanent permanentError
+	switch {
+	case err == nil:
+		storeErr = q.Store.Complete(storeCtx, job)
+		logger.Debug("job done", "took", time.Since(start))
+	case errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts:
+		job.Status = StatusDead
+		storeErr = q.Store.Bury(storeCtx, job)
+		logger.Error("job failed for good", "error", err)
+	default:
+		job.Status = StatusPending
+		job.RunAt = time.Now().Add(q.backoff(job.Attempts)).UTC()
+		storeErr = q.Store.Retry(storeCtx, job)
+		logger.Warn("job failed, will retry", "error", err, "retry_at", job.RunAt)
+	}
+
+	if storeErr != nil {
+		logger.Error("recording job result", "error", storeErr)
+	}
+
+	if q.OnResult != nil {
+		q.OnResult(job, err)
+	}
+}
+
+// call runs h within the lease of job, turning a panic into an error
+func (q *Queue) call(ctx context.Context, h Handler, job *Job) (err error) {
+	if h == nil {
+		return Permanent(fmt.Errorf("queue: no handler for queue %s", job.Queue))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("queue: handler panicked: %v", r)
+		}
+	}()
+
+	ctx, cancel := context.WithDeadline(ctx, job.RunAt)
+	defer cancel()
+
+	return h(ctx, job)
+}
+
+// purge deletes done jobs past Retention every hour
+func (q *Queue) purge(ctx context.Context) {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		if err := q.Store.Purge(ctx, time.Now().Add(-q.retention())); err != nil {
+			q.logger().Error("purging done jobs", "error", err)
+		}
+
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (q *Queue) pollInterval() time.Duration {
+	if q.PollInterval <= 0 {
+		return time.Second
+	}
+	return q.PollInterval
+}
+
+func (q *Queue) lease() time.Duration {
+	if q.Lease <= 0 {
+		return 5 * time.Minute
+	}
+	return q.Lease
+}
+
+func (q *Queue) maxAttempts() int {
+	if q.MaxAttempts <= 0 {
+		return 5
+	}
+	return q.MaxAttempts
+}
+
+func (q *Queue) backoff(attempt int) time.Duration {
+	if q.Backoff == nil {
+		return ExponentialBackoff(time.Second, time.Hour)(attempt)
+	}
+	return q.Backoff(attempt)
+}
+
+func (q *Queue) retention() time.Duration {
+	if q.Retention <= 0 {
+		return 24 * time.Hour
+	}
+	return q.Retention
+}
+
+func (q *Queue) logger() *slog.Logger {
+	if q.Logger == nil {
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return q.Logger
+}
+
+// newID returns a random id of 32 hex characters
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,212 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testQueue(s Store) *Queue {
+	return &Queue{
+		Store:        s,
+		Workers:      3,
+		PollInterval: 10 * time.Millisecond,
+		Backoff:      func(int) time.Duration { return 10 * time.Millisecond },
+	}
+}
+
+// waitFor polls the status of job id until it is want
+func waitFor(t *testing.T, q *Queue, id string, want Status) *Job {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := q.Get(context.Background(), id)
+		if err == nil && job.Status == want {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s never became %s", id, want)
+	return nil
+}
+
+func TestQueue_Run(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			q := testQueue(s)
+
+			var mu sync.Mutex
+			var got []int
+			q.Handle("numbers", func(ctx context.Context, job *Job) error {
+				var p struct{ N int }
+				if err := job.Decode(&p); err != nil {
+					return Permanent(err)
+				}
+				mu.Lock()
+				got = append(got, p.N)
+				mu.Unlock()
+				return nil
+			})
+
+			q.Start()
+			defer func() { _ = q.Stop(ctx) }()
+
+			var ids []string
+			for i := 0; i < 10; i++ {
+				id, err := q.Push(ctx, "numbers", map[string]int{"n": i})
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, id)
+			}
+
+			for _, id := range ids {
+				waitFor(t, q, id, StatusDone)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(got) != 10 {
+				t.Errorf("expected every job to run once, got %v", got)
+			}
+		})
+	}
+}
+
+func TestQueue_RetryAndDeadLetter(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			q := testQueue(s)
+
+			var results []Status
+			var mu sync.Mutex
+			q.OnResult = func(job *Job, err error) {
+				mu.Lock()
+				results = append(results, job.Status)
+				mu.Unlock()
+			}
+
+			var flaky atomic.Int32
+			q.Handle("flaky", func(ctx context.Context, job *Job) error {
+				if flaky.Add(1) < 3 {
+					return errors.New("not yet")
+				}
+				return nil
+			})
+			q.Handle("broken", func(ctx context.Context, job *Job) error {
+				return errors.New("always fails")
+			})
+			q.Handle("panics", func(ctx context.Context, job *Job) error {
+				panic("boom")
+			})
+
+			q.Start()
+			defer func() { _ = q.Stop(ctx) }()
+
+			flakyID, _ := q.Push(ctx, "flaky", nil)
+			brokenID, _ := q.Push(ctx, "broken", nil, Attempts(2))
+			panicsID, _ := q.Push(ctx, "panics", nil, Attempts(1))
+
+			if job := waitFor(t, q, flakyID, StatusDone); job.Attempts != 3 || job.LastError != "" {
+				t.Errorf("expected the flaky job to succeed on its third attempt, got %+v", job)
+			}
+			if job := waitFor(t, q, brokenID, StatusDead); job.Attempts != 2 || job.LastError != "always fails" {
+				t.Errorf("expected the broken job to be buried after 2 attempts, got %+v", job)
+			}
+			if job := waitFor(t, q, panicsID, StatusDead); job.LastError != "queue: handler panicked: boom" {
+				t.Errorf("expected the panic to be recorded, got %q", job.LastError)
+			}
+
+			dead, err := q.Dead(ctx, "broken")
+			if err != nil || len(dead) != 1 || dead[0].ID != brokenID {
+				t.Fatalf("expected the broken job in the dead letter queue, got %+v, %v", dead, err)
+			}
+
+			mu.Lock()
+			if len(results) != 6 {
+				t.Errorf("expected OnResult for every run, got %v", results)
+			}
+			mu.Unlock()
+
+			// fixed, so the requeued job now succeeds
+			q.Handle("broken", func(ctx context.Context, job *Job) error { return nil })
+			if err := q.Requeue(ctx, brokenID); err != nil {
+				t.Fatal(err)
+			}
+			waitFor(t, q, brokenID, StatusDone)
+		})
+	}
+}
+
+func TestQueue_Delay(t *testing.T) {
+	ctx := context.Background()
+	q := testQueue(&MemoryStore{})
+
+	ran := make(chan time.Time, 1)
+	q.Handle("later", func(ctx context.Context, job *Job) error {
+		ran <- time.Now()
+		return nil
+	})
+	q.Start()
+	defer func() { _ = q.Stop(ctx) }()
+
+	start := time.Now()
+	if _, err := q.Push(ctx, "later", nil, Delay(100*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case at := <-ran:
+		if at.Sub(start) < 100*time.Millisecond {
+			t.Errorf("delayed job ran after %s", at.Sub(start))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("delayed job never ran")
+	}
+}
+
+func TestQueue_Stop(t *testing.T) {
+	q := testQueue(&MemoryStore{})
+
+	started := make(chan struct{})
+	q.Handle("slow", func(ctx context.Context, job *Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	q.Start()
+
+	id, _ := q.Push(context.Background(), "slow", nil)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := q.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected Stop to give up on the slow job, got %v", err)
+	}
+
+	// the cancelled run is recorded, so the job runs again later
+	job, err := q.Get(context.Background(), id)
+	if err != nil || job.Status != StatusPending || job.Attempts != 1 {
+		t.Errorf("expected the job to be pending a retry, got %+v, %v", job, err)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, time.Minute)
+
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 20: time.Minute} {
+		got := backoff(attempt)
+		if got < want || got > want+want/5 {
+			t.Errorf("attempt %d waits %s; want %s plus up to a fifth", attempt, got, want)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisStore keeps each job as JSON under its own key, and the ids of jobs in lists and sorted sets
+// per queue:
+//
+//	ready:<queue>     list of due jobs, pushed on the left and taken from the right
+//	delayed:<queue>   sorted set of pending jobs by when they are due
+//	reserved:<queue>  sorted set of running jobs by when their lease runs out
+//	dead:<queue>      list of jobs that failed for good
+//	done              sorted set of done jobs by when they finished, for Purge
+type RedisStore struct {
+	Conn *redis.Pool
+	// Prefix is put in front of every key, like RedisCache.Prefix
+	Prefix string
+}
+
+func (rs *RedisStore) key(parts ...string) string {
+	return rs.Prefix + ":fenix-queue:" + strings.Join(parts, ":")
+}
+
+func (rs *RedisStore) do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	conn, err := rs.Conn.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return redis.DoContext(conn, ctx, cmd, args...)
+}
+
+func (rs *RedisStore) script(ctx context.Context, s *redis.Script, args ...interface{}) (interface{}, error) {
+	conn, err := rs.Conn.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return s.DoContext(ctx, conn, args...)
+}
+
+// pushScript stores job ARGV[2] under KEYS[1] and adds its id ARGV[1] to the ready list KEYS[2] if it
+// is due at ARGV[3] by now, ARGV[4], or to the delayed set KEYS[3] otherwise
+var pushScript = redis.NewScript(3, `
+redis.call('SET', KEYS[1], ARGV[2])
+if tonumber(ARGV[3]) <= tonumber(ARGV[4]) then
+	redis.call('LPUSH', KEYS[2], ARGV[1])
+else
+	redis.call('ZADD', KEYS[3], ARGV[3], ARGV[1])
+end
+return 1
+`)
+
+func (rs *RedisStore) Push(ctx context.Context, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	_, err = rs.script(ctx, pushScript,
+		rs.key("job", job.ID), rs.key("ready", job.Queue), rs.key("delayed", job.Queue),
+		job.ID, data, job.RunAt.UnixMilli(), time.Now().UnixMilli())
+	return err
+}
+
+// reserveScript goes through the queues in ARGV[4:], and in each makes due delayed jobs and jobs whose
+// lease ran out ready, then leases the oldest ready job until ARGV[2] + ARGV[3] and returns it. The
+// keys depend on the queues, so they are built here from the prefix in ARGV[1].
+var reserveScript = redis.NewScript(0, `
+local prefix = ARGV[1]
+local now = tonumber(ARGV[2])
+for i = 4, #ARGV do
+	local ready = prefix .. 'ready:' .. ARGV[i]
+	local reserved = prefix .. 'reserved:' .. ARGV[i]
+	for _, set in ipairs({prefix .. 'delayed:' .. ARGV[i], reserved}) do
+		local due = redis.call('ZRANGEBYSCORE', set, '-inf', now, 'LIMIT', 0, 100)
+		for _, id in ipairs(due) do
+			redis.call('ZREM', set, id)
+			redis.call('LPUSH', ready, id)
+		end
+	end
+	while true do
+		local id = redis.call('RPOP', ready)
+		if not id then
+			break
+		end
+		local job = redis.call('GET', prefix .. 'job:' .. id)
+		if job then
+			redis.call('ZADD', reserved, now + tonumber(ARGV[3]), id)
+			return job
+		end
+	end
+end
+return false
+`)
+
+func (rs *RedisStore) Reserve(ctx context.Context, queues []string, lease time.Duration) (*Job, error) {
+	args := []interface{}{rs.key(), time.Now().UnixMilli(), lease.Milliseconds()}
+	for _, q := range queues {
+		args = append(args, q)
+	}
+
+	data, err := redis.Bytes(rs.script(ctx, reserveScript, args...))
+	if errors.Is(err, redis.ErrNil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, err
+	}
+
+	// the job is in the reserved set now, so no other worker can take it
+	now := time.Now().UTC()
+	job.Status = StatusRunning
+	job.Attempts++
+	job.RunAt = now.Add(lease)
+	job.UpdatedAt = now
+
+	data, err = json.Marshal(&job)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := rs.do(ctx, "SET", rs.key("job", job.ID), data); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// settleScript takes the id ARGV[1] out of the reserved set KEYS[2], stores job ARGV[2] under KEYS[1]
+// and adds the id to KEYS[3]: a list if ARGV[3] is "list", otherwise a sorted set with score ARGV[4]
+var settleScript = redis.NewScript(3, `
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('SET', KEYS[1], ARGV[2])
+if ARGV[3] == 'list' then
+	redis.call('LPUSH', KEYS[3], ARGV[1])
+else
+	redis.call('ZADD', KEYS[3], ARGV[4], ARGV[1])
+end
+return 1
+`)
+
+func (rs *RedisStore) settle(ctx context.Context, job *Job, to, kind string, score int64) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	_, err = rs.script(ctx, settleScript,
+		rs.key("job", job.ID), rs.key("reserved", job.Queue), to,
+		job.ID, data, kind, score)
+	return err
+}
+
+func (rs *RedisStore) Complete(ctx context.Context, job *Job) error {
+	return rs.settle(ctx, job, rs.key("done"), "set", job.UpdatedAt.UnixMilli())
+}
+
+func (rs *RedisStore) Retry(ctx context.Context, job *Job) error {
+	return rs.settle(ctx, job, rs.key("delayed", job.Queue), "set", job.RunAt.UnixMilli())
+}
+
+func (rs *RedisStore) Bury(ctx context.Context, job *Job) error {
+	return rs.settle(ctx, job, rs.key("dead", job.Queue), "list", 0)
+}
+
+func (rs *RedisStore) Get(ctx context.Context, id string) (*Job, error) {
+	data, err := redis.Bytes(rs.do(ctx, "GET", rs.key("job", id)))
+	if errors.Is(err, redis.ErrNil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (rs *RedisStore) Dead(ctx context.Context, queue string) ([]*Job, error) {
+	ids, err := redis.Strings(rs.do(ctx, "LRANGE", rs.key("dead", queue), 0, -1))
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	// the newest job was pushed on the left, so the list is read backwards
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[len(ids)-1-i] = rs.key("job", id)
+	}
+
+	values, err := redis.ByteSlices(rs.do(ctx, "MGET", args...))
+	if err != nil {
+		return nil, err
+	}
+
+	dead := make([]*Job, 0, len(values))
+	for _, data := range values {
+		if data == nil {
+			continue
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			return nil, err
+		}
+		dead = append(dead, &job)
+	}
+	return dead, nil
+}
+
+// requeueScript moves the id ARGV[1] from the dead list KEYS[2] to the ready list KEYS[3], storing job
+// ARGV[2] under KEYS[1], and returns 0 if the id was not in the dead list
+var requeueScript = redis.NewScript(3, `
+if redis.call('LREM', KEYS[2], 0, ARGV[1]) == 0 then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2])
+redis.call('LPUSH', KEYS[3], ARGV[1])
+return 1
+`)
+
+func (rs *RedisStore) Requeue(ctx context.Context, id string) error {
+	job, err := rs.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job.Status != StatusDead {
+		return ErrNotFound
+	}
+
+	now := time.Now().UTC()
+	job.Status = StatusPending
+	job.Attempts = 0
+	job.RunAt = now
+	job.UpdatedAt = now
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	moved, err := redis.Int(rs.script(ctx, requeueScript,
+		rs.key("job", id), rs.key("dead", job.Queue), rs.key("ready", job.Queue), id, data))
+	if err != nil {
+		return err
+	}
+	if moved == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// purgeScript deletes the jobs in the done set KEYS[1] that finished before ARGV[1], 1000 at a time,
+// and returns how many it deleted. Job keys are built from the prefix in ARGV[2].
+var purgeScript = redis.NewScript(1, `
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', '(' .. ARGV[1], 'LIMIT', 0, 1000)
+for _, id in ipairs(ids) do
+	redis.call('DEL', ARGV[2] .. 'job:' .. id)
+	redis.call('ZREM', KEYS[1], id)
+end
+return #ids
+`)
+
+func (rs *RedisStore) Purge(ctx context.Context, before time.Time) error {
+	for {
+		n, err := redis.Int(rs.script(ctx, purgeScript, rs.key("done"), strconv.FormatInt(before.UnixMilli(), 10), rs.key()))
+		if err != nil || n < 1000 {
+			return err
+		}
+	}
+}
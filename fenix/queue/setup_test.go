@@ -0,0 +1,53 @@
+package queue
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+)
+
+var testRedisStore RedisStore
+
+func TestMain(m *testing.M) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+
+	pool := redis.Pool{
+		MaxIdle:     50,
+		MaxActive:   1000,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", s.Addr())
+		},
+	}
+
+	testRedisStore.Conn = &pool
+	testRedisStore.Prefix = "test-fenix"
+
+	code := m.Run()
+
+	_ = pool.Close()
+	s.Close()
+	os.Exit(code)
+}
+
+// testStores returns an empty store of every kind that can run without a database server
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+
+	conn := testRedisStore.Conn.Get()
+	defer conn.Close()
+	if _, err := conn.Do("FLUSHALL"); err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]Store{
+		"memory": &MemoryStore{},
+		"redis":  &testRedisStore,
+	}
+}
@@ -0,0 +1,221 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLStore keeps jobs in a table of a Postgres or MySQL (8 or later) database, created by
+// "fenix make queue". Workers take jobs with SELECT ... FOR UPDATE SKIP LOCKED, so any number of them
+// can share the table without waiting on each other. MySQL connections need parseTime=true.
+type SQLStore struct {
+	DB *sql.DB
+	// Dialect is postgres or mysql; postgresql and mariadb are accepted too
+	Dialect string
+	// Table defaults to jobs
+	Table string
+}
+
+const jobColumns = "id, queue, payload, status, attempts, max_attempts, last_error, run_at, created_at, updated_at"
+
+func (s *SQLStore) table() string {
+	if s.Table == "" {
+		return "jobs"
+	}
+	return s.Table
+}
+
+// rebind turns the ? placeholders of query into $1, $2... for Postgres
+func (s *SQLStore) rebind(query string) string {
+	if s.Dialect != "postgres" && s.Dialect != "postgresql" {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJob(row scanner) (*Job, error) {
+	var job Job
+	var payload string
+
+	err := row.Scan(&job.ID, &job.Queue, &payload, &job.Status, &job.Attempts, &job.MaxAttempts,
+		&job.LastError, &job.RunAt, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Payload = []byte(payload)
+	return &job, nil
+}
+
+func (s *SQLStore) Push(ctx context.Context, job *Job) error {
+	query := s.rebind("INSERT INTO " + s.table() + " (" + jobColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+
+	_, err := s.DB.ExecContext(ctx, query, job.ID, job.Queue, string(job.Payload), job.Status, job.Attempts,
+		job.MaxAttempts, job.LastError, job.RunAt.UTC(), job.CreatedAt.UTC(), job.UpdatedAt.UTC())
+	return err
+}
+
+// Reserve treats running jobs whose lease ran out as due, since run_at holds the end of the lease
+func (s *SQLStore) Reserve(ctx context.Context, queues []string, lease time.Duration) (*Job, error) {
+	if len(queues) == 0 {
+		return nil, nil
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now().UTC()
+
+	// one query per queue keeps the order of preference, and each uses the (queue, status, run_at) index
+	var job *Job
+	for _, name := range queues {
+		query := s.rebind("SELECT " + jobColumns + " FROM " + s.table() +
+			" WHERE queue = ? AND status IN (?, ?) AND run_at <= ?" +
+			" ORDER BY run_at LIMIT 1 FOR UPDATE SKIP LOCKED")
+
+		job, err = scanJob(tx.QueryRowContext(ctx, query, name, StatusPending, StatusRunning, now))
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+	if job == nil {
+		return nil, nil
+	}
+
+	job.Status = StatusRunning
+	job.Attempts++
+	job.RunAt = now.Add(lease)
+	job.UpdatedAt = now
+
+	query := s.rebind("UPDATE " + s.table() + " SET status = ?, attempts = ?, run_at = ?, updated_at = ? WHERE id = ?")
+	if _, err := tx.ExecContext(ctx, query, job.Status, job.Attempts, job.RunAt, job.UpdatedAt, job.ID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (s *SQLStore) update(ctx context.Context, job *Job) error {
+	query := s.rebind("UPDATE " + s.table() +
+		" SET status = ?, attempts = ?, last_error = ?, run_at = ?, updated_at = ? WHERE id = ?")
+
+	res, err := s.DB.ExecContext(ctx, query, job.Status, job.Attempts, job.LastError, job.RunAt.UTC(),
+		job.UpdatedAt.UTC(), job.ID)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLStore) Complete(ctx context.Context, job *Job) error {
+	return s.update(ctx, job)
+}
+
+func (s *SQLStore) Retry(ctx context.Context, job *Job) error {
+	return s.update(ctx, job)
+}
+
+func (s *SQLStore) Bury(ctx context.Context, job *Job) error {
+	return s.update(ctx, job)
+}
+
+// Ready returns an error if the jobs table cannot be read, usually because "fenix make queue" has not
+// been run
+func (s *SQLStore) Ready(ctx context.Context) error {
+	rows, err := s.DB.QueryContext(ctx, "SELECT id FROM "+s.table()+" WHERE 1 = 0")
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+func (s *SQLStore) Get(ctx context.Context, id string) (*Job, error) {
+	query := s.rebind("SELECT " + jobColumns + " FROM " + s.table() + " WHERE id = ?")
+
+	job, err := scanJob(s.DB.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return job, err
+}
+
+func (s *SQLStore) Dead(ctx context.Context, queue string) ([]*Job, error) {
+	query := s.rebind("SELECT " + jobColumns + " FROM " + s.table() +
+		" WHERE queue = ? AND status = ? ORDER BY updated_at")
+
+	rows, err := s.DB.QueryContext(ctx, query, queue, StatusDead)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dead []*Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		dead = append(dead, job)
+	}
+	return dead, rows.Err()
+}
+
+func (s *SQLStore) Requeue(ctx context.Context, id string) error {
+	now := time.Now().UTC()
+	query := s.rebind("UPDATE " + s.table() +
+		" SET status = ?, attempts = 0, run_at = ?, updated_at = ? WHERE id = ? AND status = ?")
+
+	res, err := s.DB.ExecContext(ctx, query, StatusPending, now, now, id, StatusDead)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLStore) Purge(ctx context.Context, before time.Time) error {
+	query := s.rebind("DELETE FROM " + s.table() + " WHERE status = ? AND updated_at < ?")
+
+	_, err := s.DB.ExecContext(ctx, query, StatusDone, before.UTC())
+	return err
+}
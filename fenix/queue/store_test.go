@@ -0,0 +1,168 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testJob(queue string, runAt time.Time) *Job {
+	now := time.Now().UTC()
+	return &Job{
+		ID:          newID(),
+		Queue:       queue,
+		Payload:     []byte(`{"n":1}`),
+		Status:      StatusPending,
+		MaxAttempts: 3,
+		RunAt:       runAt.UTC(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+func TestStore_ReserveOrder(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			first := testJob("a", time.Now().Add(-time.Second))
+			second := testJob("a", time.Now())
+			later := testJob("a", time.Now().Add(time.Hour))
+			other := testJob("b", time.Now())
+			for _, j := range []*Job{first, second, later, other} {
+				if err := s.Push(ctx, j); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			job, err := s.Reserve(ctx, []string{"a", "b"}, time.Minute)
+			if err != nil || job == nil || job.ID != first.ID {
+				t.Fatalf("expected the oldest due job, got %+v, %v", job, err)
+			}
+			if job.Status != StatusRunning || job.Attempts != 1 || string(job.Payload) != `{"n":1}` {
+				t.Errorf("reserved job not updated: %+v", job)
+			}
+
+			job, _ = s.Reserve(ctx, []string{"a", "b"}, time.Minute)
+			if job == nil || job.ID != second.ID {
+				t.Fatalf("expected the next due job of a, got %+v", job)
+			}
+
+			job, _ = s.Reserve(ctx, []string{"a", "b"}, time.Minute)
+			if job == nil || job.ID != other.ID {
+				t.Fatalf("expected the job of b once a had none due, got %+v", job)
+			}
+
+			if job, err = s.Reserve(ctx, []string{"a", "b"}, time.Minute); job != nil || err != nil {
+				t.Errorf("expected no due job, got %+v, %v", job, err)
+			}
+
+			stored, err := s.Get(ctx, first.ID)
+			if err != nil || stored.Status != StatusRunning {
+				t.Errorf("expected the stored job to be running, got %+v, %v", stored, err)
+			}
+			if _, err := s.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("expected ErrNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestStore_LeaseExpiry(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			job := testJob("a", time.Now())
+			_ = s.Push(ctx, job)
+
+			if j, _ := s.Reserve(ctx, []string{"a"}, 50*time.Millisecond); j == nil {
+				t.Fatal("expected to reserve the job")
+			}
+			if j, _ := s.Reserve(ctx, []string{"a"}, time.Minute); j != nil {
+				t.Fatal("expected a leased job not to be handed out twice")
+			}
+
+			time.Sleep(100 * time.Millisecond)
+			j, err := s.Reserve(ctx, []string{"a"}, time.Minute)
+			if err != nil || j == nil || j.ID != job.ID || j.Attempts != 2 {
+				t.Errorf("expected the job back once its lease ran out, got %+v, %v", j, err)
+			}
+		})
+	}
+}
+
+func TestStore_Settle(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 3; i++ {
+				_ = s.Push(ctx, testJob("a", time.Now()))
+			}
+
+			done, _ := s.Reserve(ctx, []string{"a"}, time.Minute)
+			done.Status = StatusDone
+			if err := s.Complete(ctx, done); err != nil {
+				t.Fatal(err)
+			}
+
+			retried, _ := s.Reserve(ctx, []string{"a"}, time.Minute)
+			retried.Status = StatusPending
+			retried.LastError = "try again"
+			retried.RunAt = time.Now().Add(50 * time.Millisecond).UTC()
+			if err := s.Retry(ctx, retried); err != nil {
+				t.Fatal(err)
+			}
+
+			dead, _ := s.Reserve(ctx, []string{"a"}, time.Minute)
+			dead.Status = StatusDead
+			dead.LastError = "broken"
+			if err := s.Bury(ctx, dead); err != nil {
+				t.Fatal(err)
+			}
+
+			if j, _ := s.Reserve(ctx, []string{"a"}, time.Minute); j != nil {
+				t.Fatalf("expected the retry not to be due yet, got %+v", j)
+			}
+			time.Sleep(100 * time.Millisecond)
+			j, _ := s.Reserve(ctx, []string{"a"}, time.Minute)
+			if j == nil || j.ID != retried.ID || j.Attempts != 2 || j.LastError != "try again" {
+				t.Fatalf("expected the retried job, got %+v", j)
+			}
+
+			list, err := s.Dead(ctx, "a")
+			if err != nil || len(list) != 1 || list[0].ID != dead.ID || list[0].LastError != "broken" {
+				t.Fatalf("expected the buried job in the dead letter queue, got %+v, %v", list, err)
+			}
+
+			if err := s.Requeue(ctx, done.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("expected ErrNotFound requeueing a done job, got %v", err)
+			}
+			if err := s.Requeue(ctx, dead.ID); err != nil {
+				t.Fatal(err)
+			}
+			j, _ = s.Reserve(ctx, []string{"a"}, time.Minute)
+			if j == nil || j.ID != dead.ID || j.Attempts != 1 {
+				t.Errorf("expected the requeued job with its attempts reset, got %+v", j)
+			}
+			if list, _ := s.Dead(ctx, "a"); len(list) != 0 {
+				t.Errorf("expected an empty dead letter queue, got %d jobs", len(list))
+			}
+
+			if err := s.Purge(ctx, time.Now().Add(-time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Get(ctx, done.ID); err != nil {
+				t.Errorf("expected a recent done job to be kept, got %v", err)
+			}
+			if err := s.Purge(ctx, time.Now().Add(time.Second)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Get(ctx, done.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("expected the done job to be purged, got %v", err)
+			}
+		})
+	}
+}
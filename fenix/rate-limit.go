@@ -0,0 +1,136 @@
+package fenix
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/wtran29/fenix/fenix/auth"
+	"github.com/wtran29/fenix/fenix/ratelimit"
+)
+
+// RateLimitOption configures RateLimit
+type RateLimitOption func(*rateLimitOptions)
+
+type rateLimitOptions struct {
+	algorithm  ratelimit.Algorithm
+	burst      int
+	keys       []ratelimit.KeyFunc
+	onLimited  http.HandlerFunc
+	failClosed bool
+}
+
+// LimitAlgorithm replaces the algorithm set by RATELIMIT_ALGORITHM
+func LimitAlgorithm(a ratelimit.Algorithm) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.algorithm = a
+	}
+}
+
+// LimitBurst lets a token bucket hold up to n requests; it only applies to ratelimit.TokenBucket
+func LimitBurst(n int) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.burst = n
+	}
+}
+
+// LimitBy counts requests by the given keys together instead of by route and IP, e.g.
+// LimitBy(ratelimit.ByRoute, app.ByUser). Requests for which every key is "" are counted by IP.
+func LimitBy(keys ...ratelimit.KeyFunc) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.keys = keys
+	}
+}
+
+// OnLimited replaces the plain 429 Too Many Requests sent to requests over the limit
+func OnLimited(h http.HandlerFunc) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.onLimited = h
+	}
+}
+
+// LimitFailClosed answers requests with 503 Service Unavailable while the cache cannot count them,
+// instead of letting them through. Use it on logins, password resets and two-factor codes, so taking
+// the cache down does not lift their limits.
+func LimitFailClosed() RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.failClosed = true
+	}
+}
+
+// RateLimit is middleware that allows requests every per, counted by route and client IP, and turns
+// the rest away with 429 Too Many Requests. Counters are kept in f.Cache, so the limit is shared by
+// every instance using the same redis or badger cache. Use it per route, or on a route group:
+//
+//	r.With(app.RateLimit(5, time.Minute)).Post("/users/login", handlers.PostUserLogin)
+//
+//	r.Group(func(r chi.Router) {
+//		r.Use(app.RequireAuth(app.TokenGuard()))
+//		r.Use(app.RateLimit(100, time.Minute, fenix.LimitBy(app.ByUser)))
+//		...
+//	})
+//
+// If the cache fails, the error is logged and requests are let through, or turned away with
+// LimitFailClosed.
+func (f *Fenix) RateLimit(requests int, per time.Duration, opts ...RateLimitOption) func(http.Handler) http.Handler {
+	o := rateLimitOptions{
+		algorithm: f.rateLimitAlgorithm(),
+		keys:      []ratelimit.KeyFunc{ratelimit.ByRoute, ratelimit.ByIP},
+		onLimited: f.ErrorTooManyRequests,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(next http.Handler) http.Handler {
+		store, ok := f.Cache.(ratelimit.Store)
+		if !ok {
+			f.Logger.Warn("rate limit disabled: cache cannot hold counters", "cache", fmt.Sprintf("%T", f.Cache))
+			return next
+		}
+
+		m := &ratelimit.Middleware{
+			Limiter: &ratelimit.Limiter{
+				Store:     store,
+				Requests:  requests,
+				Per:       per,
+				Algorithm: o.algorithm,
+				Burst:     o.burst,
+				// limits of different sizes on the same route must not share counters
+				Prefix: fmt.Sprintf("ratelimit:%d/%s", requests, per),
+			},
+			Key:           ratelimit.Keys(o.keys...),
+			OnLimited:     o.onLimited,
+			FailClosed:    o.failClosed,
+			OnUnavailable: f.ErrorServiceUnavailable,
+			OnError: func(r *http.Request, err error) {
+				f.Logger.WarnContext(r.Context(), "rate limit check failed", "path", r.URL.Path, "error", err)
+			},
+		}
+		return m.Handler(next)
+	}
+}
+
+// ByUser counts requests by the id of the user RequireAuth found, or else of the user signed in to
+// the session, and those of visitors by IP; pass it to LimitBy. The user found by RequireAuth must be
+// an auth.Identifiable.
+func (f *Fenix) ByUser(r *http.Request) string {
+	if user, ok := auth.UserFrom(r.Context()).(auth.Identifiable); ok {
+		return "user:" + user.AuthID()
+	}
+	if user := f.sessionUser(r); user != "" {
+		return "user:" + user
+	}
+	return ratelimit.ByIP(r)
+}
+
+func (f *Fenix) rateLimitAlgorithm() ratelimit.Algorithm {
+	switch f.config.Limits.Algorithm {
+	case "sliding":
+		return ratelimit.SlidingWindow
+	case "token":
+		return ratelimit.TokenBucket
+	default:
+		return ratelimit.FixedWindow
+	}
+}
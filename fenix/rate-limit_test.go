@@ -0,0 +1,98 @@
+package fenix
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/wtran29/fenix/fenix/auth"
+	"github.com/wtran29/fenix/fenix/cache"
+)
+
+func TestFenix_RateLimit(t *testing.T) {
+	f := &Fenix{Cache: &cache.MemoryCache{}, Session: testFenix.Session, Logger: testFenix.Logger}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	mux := chi.NewRouter()
+	mux.Use(f.Session.LoadAndSave)
+	mux.With(f.RateLimit(2, time.Minute)).Post("/login", ok)
+	mux.With(f.RateLimit(2, time.Minute)).Post("/forgot", ok)
+	mux.Group(func(r chi.Router) {
+		r.Use(f.RateLimit(3, time.Minute, LimitBy(f.ByUser)))
+		r.Get("/api/a", ok)
+		r.Get("/api/b", ok)
+	})
+
+	do := func(method, target, ip string) int {
+		req := httptest.NewRequest(method, target, nil)
+		req.RemoteAddr = ip + ":1234"
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := do("POST", "/login", "10.0.0.1"); code != http.StatusOK {
+			t.Fatalf("request %d got %d", i, code)
+		}
+	}
+	if code := do("POST", "/login", "10.0.0.1"); code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 over the limit, got %d", code)
+	}
+	if code := do("POST", "/forgot", "10.0.0.1"); code != http.StatusOK {
+		t.Error("expected each route to have its own limit")
+	}
+	if code := do("POST", "/login", "10.0.0.2"); code != http.StatusOK {
+		t.Error("expected each client to have its own limit")
+	}
+
+	// keyed by user only, the routes of the group share one limit
+	do("GET", "/api/a", "10.0.0.3")
+	do("GET", "/api/b", "10.0.0.3")
+	do("GET", "/api/a", "10.0.0.3")
+	if code := do("GET", "/api/b", "10.0.0.3"); code != http.StatusTooManyRequests {
+		t.Errorf("expected the group to share a limit, got %d", code)
+	}
+}
+
+// identity is a user RequireAuth could have found
+type identity string
+
+func (i identity) AuthID() string { return string(i) }
+
+func TestFenix_ByUser(t *testing.T) {
+	f := &Fenix{Cache: &cache.MemoryCache{}, Session: testFenix.Session, Logger: testFenix.Logger}
+
+	mux := chi.NewRouter()
+	mux.Use(f.Session.LoadAndSave)
+	mux.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user := r.Header.Get("X-Test-User"); user != "" {
+				r = r.WithContext(auth.WithUser(r.Context(), identity(user)))
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	mux.With(f.RateLimit(2, time.Minute, LimitBy(f.ByUser))).Get("/api", func(w http.ResponseWriter, r *http.Request) {})
+
+	do := func(user, ip string) int {
+		req := httptest.NewRequest("GET", "/api", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("X-Test-User", user)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// the same user from different addresses shares one limit
+	do("7", "10.0.0.1")
+	do("7", "10.0.0.2")
+	if code := do("7", "10.0.0.3"); code != http.StatusTooManyRequests {
+		t.Errorf("expected the user to be limited across addresses, got %d", code)
+	}
+	if code := do("8", "10.0.0.3"); code != http.StatusOK {
+		t.Errorf("expected another user to have their own limit, got %d", code)
+	}
+}
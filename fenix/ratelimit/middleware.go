@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// KeyFunc returns the key a request is counted under, or "" if it has none
+type KeyFunc func(r *http.Request) string
+
+// ByIP counts requests by client address. Behind a proxy, set RemoteAddr with middleware that only
+// believes forwarded headers from trusted proxies, as Fenix's RealIP does; chi's RealIP believes any
+// client, who could then get a fresh limit with every request.
+func ByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "ip:" + r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// ByRoute counts requests by method and chi route pattern, so each route has its own limit. In a
+// route group the pattern is that of the group.
+func ByRoute(r *http.Request) string {
+	pattern := ""
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		pattern = rctx.RoutePattern()
+	}
+	if pattern == "" {
+		pattern = r.URL.Path
+	}
+	return "route:" + r.Method + " " + pattern
+}
+
+// ByHeader counts requests by the value of a header, such as X-Tenant. The value is hashed, so
+// secrets like tokens are not written to the store. Anyone can send any value, so a client can get a
+// fresh limit by changing it; to limit API clients, key on the user once their token has been
+// checked, as fenix's ByUser does after RequireAuth.
+func ByHeader(name string) KeyFunc {
+	return func(r *http.Request) string {
+		if v := r.Header.Get(name); v != "" {
+			sum := sha256.Sum256([]byte(v))
+			return "header:" + name + ":" + hex.EncodeToString(sum[:])
+		}
+		return ""
+	}
+}
+
+// Keys counts requests by every key together, e.g. Keys(ByRoute, ByIP) for a limit per route and
+// client. Keys that are "" are left out.
+func Keys(keys ...KeyFunc) KeyFunc {
+	return func(r *http.Request) string {
+		var parts []string
+		for _, key := range keys {
+			if k := key(r); k != "" {
+				parts = append(parts, k)
+			}
+		}
+		return strings.Join(parts, "|")
+	}
+}
+
+// FirstOf counts requests by the first key that is not "", e.g. FirstOf(ByHeader("X-API-Key"), ByIP)
+func FirstOf(keys ...KeyFunc) KeyFunc {
+	return func(r *http.Request) string {
+		for _, key := range keys {
+			if k := key(r); k != "" {
+				return k
+			}
+		}
+		return ""
+	}
+}
+
+// Middleware applies a Limiter to every request it handles
+type Middleware struct {
+	Limiter *Limiter
+	// Key defaults to ByIP; requests whose key is "" are counted by IP
+	Key KeyFunc
+	// OnLimited answers requests over the limit; it defaults to a plain 429 Too Many Requests
+	OnLimited http.HandlerFunc
+	// OnError is told when the store fails. The request is let through rather than lock everyone out,
+	// unless FailClosed is set.
+	OnError func(r *http.Request, err error)
+	// FailClosed turns requests away while the store fails, for limits that guard against guessing,
+	// such as on logins, which must not be lifted by taking the store down
+	FailClosed bool
+	// OnUnavailable answers requests turned away by FailClosed; it defaults to a plain 503 Service
+	// Unavailable
+	OnUnavailable http.HandlerFunc
+}
+
+// Handler sets the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers on every
+// response, and Retry-After on those it turns away
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := ""
+		if m.Key != nil {
+			key = m.Key(r)
+		}
+		if key == "" {
+			key = ByIP(r)
+		}
+
+		res, err := m.Limiter.Allow(r.Context(), key)
+		if err != nil {
+			if m.OnError != nil {
+				m.OnError(r, err)
+			}
+			if m.FailClosed {
+				if m.OnUnavailable != nil {
+					m.OnUnavailable(w, r)
+					return
+				}
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", seconds(res.Reset))
+
+		if !res.Allowed {
+			w.Header().Set("Retry-After", seconds(max(res.RetryAfter, time.Second)))
+			if m.OnLimited != nil {
+				m.OnLimited(w, r)
+				return
+			}
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// seconds rounds d up to whole seconds, as the headers want
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,280 @@
+// Package ratelimit throttles requests, keeping its counters in any fenix cache, so that limits are
+// shared by every instance using the same Redis or Badger backend.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wtran29/fenix/fenix/cache"
+)
+
+// Store keeps the state of a Limiter; every cache in fenix/cache is one
+type Store interface {
+	cache.Atomic
+	cache.ContextCache
+}
+
+type Algorithm int
+
+const (
+	// FixedWindow allows Requests in each window of Per, starting on the clock. A client can make
+	// up to twice Requests around the boundary of two windows.
+	FixedWindow Algorithm = iota
+	// SlidingWindow weighs the count of the previous window by how much of it still overlaps the
+	// last Per, which smooths out the boundary of FixedWindow
+	SlidingWindow
+	// TokenBucket refills Requests tokens every Per, up to Burst, and each request takes one
+	TokenBucket
+)
+
+// Limiter allows Requests every Per for each key. Only allowed requests count towards the limit.
+type Limiter struct {
+	Store     Store
+	Requests  int
+	Per       time.Duration
+	Algorithm Algorithm
+	// Burst is how many tokens a TokenBucket holds; it defaults to Requests
+	Burst int
+	// Prefix keeps the keys of this limiter apart from those of others sharing the store
+	Prefix string
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// Result is the outcome of Allow
+type Result struct {
+	Allowed bool
+	Limit   int
+	// Remaining is how many more requests would be allowed right now
+	Remaining int
+	// Reset is how long until the limit is fully restored
+	Reset time.Duration
+	// RetryAfter is how long to wait before the next request is allowed, if this one was not
+	RetryAfter time.Duration
+}
+
+// lockAttempts and lockRetryDelay bound how long a TokenBucket waits for the lock on a bucket
+const (
+	lockAttempts   = 50
+	lockRetryDelay = 2 * time.Millisecond
+)
+
+// ErrBusy is returned when a token bucket stayed locked by other requests for too long
+var ErrBusy = errors.New("ratelimit: bucket is busy")
+
+// Allow records a request for key and reports whether it is within the limit
+func (l *Limiter) Allow(ctx context.Context, key string) (Result, error) {
+	if l.Requests <= 0 || l.Per <= 0 {
+		return Result{}, fmt.Errorf("ratelimit: invalid limit of %d per %s", l.Requests, l.Per)
+	}
+
+	key = l.prefix() + ":" + key
+
+	switch l.Algorithm {
+	case SlidingWindow:
+		return l.slidingWindow(ctx, key)
+	case TokenBucket:
+		return l.tokenBucket(ctx, key)
+	default:
+		return l.fixedWindow(ctx, key)
+	}
+}
+
+func (l *Limiter) prefix() string {
+	if l.Prefix == "" {
+		return "ratelimit"
+	}
+	return l.Prefix
+}
+
+func (l *Limiter) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
+
+// count adds a request to the counter of the window starting at start, which is created to live for
+// ttl, and takes it back if the new count is over limit
+func (l *Limiter) count(ctx context.Context, key string, start time.Time, ttl time.Duration, over func(n int64) bool) (int64, bool, error) {
+	key = key + ":" + strconv.FormatInt(start.UnixNano(), 10)
+
+	if _, err := l.Store.SetNX(ctx, key, []byte("0"), ttl); err != nil {
+		return 0, false, err
+	}
+
+	n, err := l.Store.Increment(ctx, key, 1)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if over(n) {
+		_, err = l.Store.Decrement(ctx, key, 1)
+		return n - 1, false, err
+	}
+	return n, true, nil
+}
+
+func (l *Limiter) fixedWindow(ctx context.Context, key string) (Result, error) {
+	now := l.clock()
+	start := now.Truncate(l.Per)
+	reset := start.Add(l.Per).Sub(now)
+
+	n, allowed, err := l.count(ctx, key, start, l.Per, func(n int64) bool {
+		return n > int64(l.Requests)
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := Result{
+		Allowed:   allowed,
+		Limit:     l.Requests,
+		Remaining: max(l.Requests-int(n), 0),
+		Reset:     reset,
+	}
+	if !allowed {
+		res.RetryAfter = reset
+	}
+	return res, nil
+}
+
+func (l *Limiter) slidingWindow(ctx context.Context, key string) (Result, error) {
+	now := l.clock()
+	start := now.Truncate(l.Per)
+	elapsed := now.Sub(start)
+	// the share of the previous window that still falls within the last Per
+	weight := 1 - float64(elapsed)/float64(l.Per)
+
+	previous, err := l.windowCount(ctx, key, start.Add(-l.Per))
+	if err != nil {
+		return Result{}, err
+	}
+
+	// the counter is read again as the previous window during the next one
+	n, allowed, err := l.count(ctx, key, start, 2*l.Per, func(n int64) bool {
+		return float64(previous)*weight+float64(n) > float64(l.Requests)
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	estimate := int(math.Ceil(float64(previous)*weight)) + int(n)
+	res := Result{
+		Allowed:   allowed,
+		Limit:     l.Requests,
+		Remaining: max(l.Requests-estimate, 0),
+		// the previous window has no weight left once this one ends
+		Reset: l.Per - elapsed,
+	}
+
+	if !allowed {
+		if room := float64(l.Requests - int(n) - 1); room < 0 || previous == 0 {
+			res.RetryAfter = l.Per - elapsed
+		} else {
+			// when previous*(1-t/Per) + n + 1 drops to Requests
+			t := time.Duration(float64(l.Per) * (1 - room/float64(previous)))
+			res.RetryAfter = max(t-elapsed, 0)
+		}
+	}
+	return res, nil
+}
+
+func (l *Limiter) windowCount(ctx context.Context, key string, start time.Time) (int64, error) {
+	b, err := l.Store.GetContext(ctx, key+":"+strconv.FormatInt(start.UnixNano(), 10))
+	if errors.Is(err, cache.ErrCacheMiss) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(b), 10, 64)
+}
+
+func (l *Limiter) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.Requests)
+}
+
+// tokenBucket keeps "tokens:unix nano of the last request" under key, read and written under a lock
+func (l *Limiter) tokenBucket(ctx context.Context, key string) (Result, error) {
+	token, err := l.lock(ctx, key)
+	if err != nil {
+		return Result{}, err
+	}
+	defer func() { _ = l.Store.Unlock(context.WithoutCancel(ctx), key, token) }()
+
+	now := l.clock()
+	burst := l.burst()
+	rate := float64(l.Requests) / float64(l.Per) // tokens per nanosecond
+
+	tokens := burst
+	b, err := l.Store.GetContext(ctx, key)
+	switch {
+	case err == nil:
+		if t, at, ok := parseBucket(string(b)); ok {
+			tokens = math.Min(burst, t+float64(now.Sub(at))*rate)
+		}
+	case !errors.Is(err, cache.ErrCacheMiss):
+		return Result{}, err
+	}
+
+	res := Result{Limit: int(burst)}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration(math.Ceil((1 - tokens) / rate))
+	}
+	res.Remaining = int(tokens)
+	res.Reset = time.Duration(math.Ceil((burst - tokens) / rate))
+
+	// an untouched bucket is full again after Reset, so it need not be kept any longer
+	state := strconv.FormatFloat(tokens, 'f', -1, 64) + ":" + strconv.FormatInt(now.UnixNano(), 10)
+	if err := l.Store.SetContext(ctx, key, []byte(state), res.Reset+time.Second); err != nil {
+		return Result{}, err
+	}
+	return res, nil
+}
+
+func (l *Limiter) lock(ctx context.Context, key string) (string, error) {
+	for i := 0; i < lockAttempts; i++ {
+		token, err := l.Store.Lock(ctx, key, time.Second)
+		if !errors.Is(err, cache.ErrLocked) {
+			return token, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(lockRetryDelay):
+		}
+	}
+	return "", ErrBusy
+}
+
+func parseBucket(s string) (float64, time.Time, bool) {
+	tokens, at, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, time.Time{}, false
+	}
+
+	t, err := strconv.ParseFloat(tokens, 64)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	ns, err := strconv.ParseInt(at, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	return t, time.Unix(0, ns), true
+}
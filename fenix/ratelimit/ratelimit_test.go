@@ -0,0 +1,245 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wtran29/fenix/fenix/cache"
+)
+
+// testLimiter returns a limiter on a fresh memory cache whose clock is moved with the returned func
+func testLimiter(requests int, per time.Duration, algorithm Algorithm) (*Limiter, func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	l := &Limiter{
+		Store:     &cache.MemoryCache{},
+		Requests:  requests,
+		Per:       per,
+		Algorithm: algorithm,
+		now:       func() time.Time { return now },
+	}
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+func allowed(t *testing.T, l *Limiter, key string, n int) int {
+	t.Helper()
+
+	count := 0
+	for i := 0; i < n; i++ {
+		res, err := l.Allow(context.Background(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed {
+			count++
+		}
+	}
+	return count
+}
+
+func TestLimiter_FixedWindow(t *testing.T) {
+	l, advance := testLimiter(3, time.Minute, FixedWindow)
+
+	if got := allowed(t, l, "a", 5); got != 3 {
+		t.Errorf("allowed %d requests; want 3", got)
+	}
+	if got := allowed(t, l, "b", 1); got != 1 {
+		t.Error("another key shares the limit")
+	}
+
+	res, _ := l.Allow(context.Background(), "a")
+	if res.Remaining != 0 || res.RetryAfter != time.Minute || res.Reset != time.Minute {
+		t.Errorf("wrong result when limited: %+v", res)
+	}
+
+	advance(time.Minute)
+	res, _ = l.Allow(context.Background(), "a")
+	if !res.Allowed || res.Remaining != 2 {
+		t.Errorf("limit not restored in the next window: %+v", res)
+	}
+}
+
+func TestLimiter_SlidingWindow(t *testing.T) {
+	l, advance := testLimiter(10, time.Minute, SlidingWindow)
+
+	if got := allowed(t, l, "a", 10); got != 10 {
+		t.Fatalf("allowed %d requests; want 10", got)
+	}
+
+	// half the previous window still counts, so only 5 more fit
+	advance(90 * time.Second)
+	if got := allowed(t, l, "a", 10); got != 5 {
+		t.Errorf("allowed %d requests half way into the next window; want 5", got)
+	}
+
+	res, _ := l.Allow(context.Background(), "a")
+	if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > 30*time.Second {
+		t.Errorf("wrong result when limited: %+v", res)
+	}
+
+	advance(2 * time.Minute)
+	if got := allowed(t, l, "a", 10); got != 10 {
+		t.Errorf("allowed %d requests once both windows passed; want 10", got)
+	}
+}
+
+func TestLimiter_TokenBucket(t *testing.T) {
+	l, advance := testLimiter(1, time.Second, TokenBucket)
+	l.Burst = 5
+
+	if got := allowed(t, l, "a", 10); got != 5 {
+		t.Errorf("allowed a burst of %d; want 5", got)
+	}
+
+	res, _ := l.Allow(context.Background(), "a")
+	if res.Allowed || res.RetryAfter != time.Second || res.Limit != 5 {
+		t.Errorf("wrong result when empty: %+v", res)
+	}
+
+	advance(2 * time.Second)
+	if got := allowed(t, l, "a", 10); got != 2 {
+		t.Errorf("allowed %d requests after 2 tokens were added; want 2", got)
+	}
+
+	advance(time.Hour)
+	res, _ = l.Allow(context.Background(), "a")
+	if !res.Allowed || res.Remaining != 4 {
+		t.Errorf("bucket not refilled up to the burst: %+v", res)
+	}
+}
+
+func TestLimiter_Concurrent(t *testing.T) {
+	for _, algorithm := range []Algorithm{FixedWindow, SlidingWindow, TokenBucket} {
+		l, _ := testLimiter(20, time.Minute, algorithm)
+
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		count := 0
+
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := l.Allow(context.Background(), "a")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if res.Allowed {
+					mu.Lock()
+					count++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if count != 20 {
+			t.Errorf("algorithm %d allowed %d concurrent requests; want 20", algorithm, count)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	l, _ := testLimiter(2, time.Minute, FixedWindow)
+	m := &Middleware{Limiter: l, Key: ByHeader("X-API-Key")}
+
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := request("one")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("first request got %d", rr.Code)
+	}
+	if rr.Header().Get("RateLimit-Limit") != "2" || rr.Header().Get("RateLimit-Remaining") != "1" ||
+		rr.Header().Get("RateLimit-Reset") != "60" {
+		t.Errorf("wrong headers: %v", rr.Header())
+	}
+
+	request("one")
+	rr = request("one")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("third request got %d; want 429", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "60" {
+		t.Errorf("wrong Retry-After: %q", rr.Header().Get("Retry-After"))
+	}
+
+	if rr = request("two"); rr.Code != http.StatusOK {
+		t.Error("another API key shares the limit")
+	}
+
+	// without a key, requests fall back to the IP
+	request("")
+	request("")
+	if rr = request(""); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("request without a key got %d; want 429", rr.Code)
+	}
+}
+
+func TestMiddleware_FailsOpen(t *testing.T) {
+	var failed error
+	m := &Middleware{
+		Limiter: &Limiter{Store: &cache.MemoryCache{}},
+		OnError: func(r *http.Request, err error) { failed = err },
+	}
+
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rr.Code != http.StatusOK || failed == nil {
+		t.Errorf("got %d and error %v; want 200 and the error reported", rr.Code, failed)
+	}
+}
+
+func TestMiddleware_FailClosed(t *testing.T) {
+	m := &Middleware{
+		Limiter:    &Limiter{Store: &cache.MemoryCache{}},
+		FailClosed: true,
+	}
+
+	called := false
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rr.Code != http.StatusServiceUnavailable || called {
+		t.Errorf("got %d, handler called: %v; want 503 without calling the handler", rr.Code, called)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/users/login", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	if got := Keys(ByRoute, ByIP)(req); got != "route:POST /users/login|ip:10.0.0.1" {
+		t.Errorf("got %q", got)
+	}
+	if got := FirstOf(ByHeader("Authorization"), ByIP)(req); got != "ip:10.0.0.1" {
+		t.Errorf("got %q", got)
+	}
+
+	req.Header.Set("Authorization", "Bearer secret-token")
+	got := ByHeader("Authorization")(req)
+	if strings.Contains(got, "secret-token") || !strings.HasPrefix(got, "header:Authorization:") {
+		t.Errorf("expected the header value to be hashed, got %q", got)
+	}
+}
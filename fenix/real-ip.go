@@ -0,0 +1,90 @@
+package fenix
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// parseTrustedProxies reads TRUSTED_PROXIES, a list of addresses and CIDR ranges such as 10.0.0.0/8
+func parseTrustedProxies(list []string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		if p, err := netip.ParsePrefix(s); err == nil {
+			proxies = append(proxies, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+		}
+		proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return proxies, nil
+}
+
+// trusted reports whether addr is one of the trusted proxies
+func (f *Fenix) trusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range f.trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// RealIP is middleware that sets r.RemoteAddr to the client address passed on by a proxy in
+// X-Forwarded-For or X-Real-IP, as rate limits and logs expect. Only the proxies in TRUSTED_PROXIES
+// are believed; anyone else could send a new address with every request. X-Forwarded-For is read from
+// the right, so the address taken is the last one added by a proxy that is not trusted.
+func (f *Fenix) RealIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(f.trustedProxies) > 0 {
+			if ip := f.forwardedFor(r); ip != "" {
+				r.RemoteAddr = ip
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardedFor returns the client address a trusted proxy passed on with r, or "" if there is none
+func (f *Fenix) forwardedFor(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil || !f.trusted(peer) {
+		return ""
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		client := ""
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			client = addr.Unmap().String()
+			if !f.trusted(addr) {
+				break
+			}
+		}
+		return client
+	}
+
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap().String()
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+package fenix
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFenix_RealIP(t *testing.T) {
+	proxies, err := parseTrustedProxies([]string{"10.0.0.0/8", " 192.168.1.1", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &Fenix{trustedProxies: proxies}
+
+	tests := []struct {
+		name   string
+		peer   string
+		header http.Header
+		want   string
+	}{
+		{"direct client", "203.0.113.9:1234", nil, "203.0.113.9:1234"},
+		{"spoofed by a client", "203.0.113.9:1234", http.Header{"X-Forwarded-For": {"1.2.3.4"}}, "203.0.113.9:1234"},
+		{"from a trusted proxy", "10.1.2.3:1234", http.Header{"X-Forwarded-For": {"198.51.100.7"}}, "198.51.100.7"},
+		{"chain of trusted proxies", "10.1.2.3:1234", http.Header{"X-Forwarded-For": {"198.51.100.7, 192.168.1.1"}}, "198.51.100.7"},
+		{"spoofed in front of a proxy", "10.1.2.3:1234", http.Header{"X-Forwarded-For": {"1.2.3.4, 198.51.100.7"}}, "198.51.100.7"},
+		{"x-real-ip", "192.168.1.1:1234", http.Header{"X-Real-Ip": {"198.51.100.8"}}, "198.51.100.8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := f.RealIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = r.RemoteAddr }))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.peer
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseTrustedProxies([]string{"proxy.example.com"}); err == nil {
+		t.Error("expected an error for a host name")
+	}
+}
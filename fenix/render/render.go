@@ -6,11 +6,13 @@ import (
 	"html/template"
 	"log"
 	"net/http"
+	"path/filepath"
 	"strings"
 
 	"github.com/CloudyKit/jet/v6"
 	"github.com/alexedwards/scs/v2"
 	"github.com/justinas/nosurf"
+	"github.com/wtran29/fenix/fenix/auth"
 )
 
 type Render struct {
@@ -21,6 +23,9 @@ type Render struct {
 	ServerName string
 	JetViews   *jet.Set
 	Session    *scs.SessionManager
+	// Gate and User give views the can function, to hide what the current user may not do
+	Gate *auth.Gate
+	User func(r *http.Request) interface{}
 }
 
 type TemplateData struct {
@@ -51,6 +56,25 @@ func (f *Render) defaultData(td *TemplateData, r *http.Request) *TemplateData {
 	return td
 }
 
+// can returns the can function of views for r, as in {{ if can("posts.edit", post) }}. The user is
+// looked up on the first call, once per page, and every call after asks the Gate about that same
+// user; a user that queries its permissions, like the app's User, should keep them for the life of
+// the value so a page with many checks costs one query.
+func (f *Render) can(r *http.Request) func(action string, subject ...interface{}) bool {
+	var user interface{}
+	looked := false
+
+	return func(action string, subject ...interface{}) bool {
+		if f.Gate == nil || f.User == nil {
+			return false
+		}
+		if !looked {
+			user, looked = f.User(r), true
+		}
+		return f.Gate.Can(user, action, subject...)
+	}
+}
+
 func (f *Render) Page(w http.ResponseWriter, r *http.Request, view string, variables, data interface{}) error {
 	switch strings.ToLower(f.Renderer) {
 	case "go":
@@ -64,7 +88,8 @@ func (f *Render) Page(w http.ResponseWriter, r *http.Request, view string, varia
 
 // GoPage renders the standard Go template
 func (f *Render) GoPage(w http.ResponseWriter, r *http.Request, view string, data interface{}) error {
-	tpl := template.Must(template.ParseFiles(fmt.Sprintf("%s/views/%s.page.tmpl", f.RootPath, view)))
+	file := fmt.Sprintf("%s/views/%s.page.tmpl", f.RootPath, view)
+	tpl := template.Must(template.New(filepath.Base(file)).Funcs(template.FuncMap{"can": f.can(r)}).ParseFiles(file))
 
 	td := &TemplateData{}
 	if data != nil {
@@ -80,12 +105,15 @@ func (f *Render) GoPage(w http.ResponseWriter, r *http.Request, view string, dat
 
 // JetPage renders the template using the Jet template engine
 func (f *Render) JetPage(w http.ResponseWriter, r *http.Request, tplName string, variables, data interface{}) error {
-	var vars jet.VarMap
-
-	if variables == nil {
-		vars = make(jet.VarMap)
-	} else {
-		vars = variables.(jet.VarMap)
+	// copy the caller's variables, which may be shared between requests, before adding can to them
+	vars := make(jet.VarMap)
+	if variables != nil {
+		for name, v := range variables.(jet.VarMap) {
+			vars[name] = v
+		}
+	}
+	if _, ok := vars["can"]; !ok {
+		vars.Set("can", f.can(r))
 	}
 
 	td := &TemplateData{}
@@ -0,0 +1,305 @@
+package fenix
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/justinas/nosurf"
+	"github.com/wtran29/fenix/fenix/cache"
+)
+
+// sessionUserKey is the session key holding the id of the signed in user
+const sessionUserKey = "userID"
+
+// responseCacheTag is on every cached response; each is also tagged with its path
+const responseCacheTag = "fenix-response"
+
+// CacheOption configures CacheResponses
+type CacheOption func(*responseCacheOptions)
+
+type responseCacheOptions struct {
+	ignoreQuery bool
+	varyHeaders []string
+	perUser     bool
+	key         func(r *http.Request) string
+}
+
+// IgnoreQuery leaves the query string out of the cache key, so /posts?page=2 is served from /posts
+func IgnoreQuery() CacheOption {
+	return func(o *responseCacheOptions) {
+		o.ignoreQuery = true
+	}
+}
+
+// VaryByHeader adds the values of the named request headers to the cache key, e.g. Accept-Language
+func VaryByHeader(names ...string) CacheOption {
+	return func(o *responseCacheOptions) {
+		o.varyHeaders = append(o.varyHeaders, names...)
+	}
+}
+
+// VaryByUser caches responses for signed in users too, keyed by the user id in their session.
+// Without it, requests from signed in users are never cached. Requests with an Authorization header
+// are never cached either way, since the session does not say whose credentials they carry.
+func VaryByUser() CacheOption {
+	return func(o *responseCacheOptions) {
+		o.perUser = true
+	}
+}
+
+// CacheKey replaces the path, query and vary headers in the cache key with the result of key; the
+// user id is still added when VaryByUser is set
+func CacheKey(key func(r *http.Request) string) CacheOption {
+	return func(o *responseCacheOptions) {
+		o.key = key
+	}
+}
+
+// cachedResponse is a full response as stored in the cache
+type cachedResponse struct {
+	Status       int
+	Header       http.Header
+	Body         []byte
+	ETag         string
+	LastModified time.Time
+}
+
+// CacheResponses is middleware that caches whole GET responses in f.Cache for ttl, so a page is only
+// rendered once per ttl. Cached responses carry an ETag and Last-Modified and answer conditional
+// requests with 304. Other methods, requests with an Authorization header, signed in users (unless
+// VaryByUser is set), and responses that are not 200, set cookies or say no-store or private are
+// passed through untouched. Use it per route:
+//
+//	r.With(app.CacheResponses(5 * time.Minute)).Get("/", handlers.Home)
+//
+// What belongs to one visitor is never cached: responses of requests that changed the session, such
+// as by showing its flash message, and responses that contain the visitor's CSRF token. Pages with
+// forms are rendered every time.
+func (f *Fenix) CacheResponses(ttl time.Duration, opts ...CacheOption) func(http.Handler) http.Handler {
+	var o responseCacheOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if f.Cache == nil || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// a token guard may answer with the user's own data, under a key that would not say whose
+			if r.Header.Get("Authorization") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user := f.sessionUser(r)
+			if user != "" && !o.perUser {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// a flash message or error is for this visitor only, and a cached page would never show it
+			if f.sessionHasMessage(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := responseCacheKey(r, &o, user)
+			if cached, err := cache.Get[cachedResponse](r.Context(), f.Cache, key); err == nil {
+				w.Header().Set("X-Cache", "HIT")
+				serveCachedResponse(w, r, &cached)
+				return
+			}
+
+			// HEAD responses have no body worth keeping
+			if r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := &responseRecorder{header: make(http.Header), status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			resp := cachedResponse{
+				Status:       rec.status,
+				Header:       rec.header,
+				Body:         rec.body.Bytes(),
+				LastModified: time.Now().UTC().Truncate(time.Second),
+			}
+
+			if cacheable(&resp) && !f.personal(r, &resp) {
+				sum := sha256.Sum256(resp.Body)
+				resp.ETag = `"` + hex.EncodeToString(sum[:16]) + `"`
+
+				tags := []string{responseCacheTag, responseCacheTag + ":" + r.URL.Path}
+				if err := cache.SetTagged(r.Context(), f.Cache, key, resp, ttl, tags...); err != nil {
+					f.Logger.WarnContext(r.Context(), "could not cache response", "path", r.URL.Path, "error", err)
+				}
+
+				w.Header().Set("X-Cache", "MISS")
+				serveCachedResponse(w, r, &resp)
+				return
+			}
+
+			writeResponse(w, &resp)
+		})
+	}
+}
+
+// ForgetResponses drops the cached responses for the given paths, or every cached response if no
+// path is given, for instance after the content behind them has changed
+func (f *Fenix) ForgetResponses(ctx context.Context, paths ...string) error {
+	if len(paths) == 0 {
+		return f.Cache.InvalidateTags(ctx, responseCacheTag)
+	}
+
+	tags := make([]string, len(paths))
+	for i, p := range paths {
+		tags[i] = responseCacheTag + ":" + p
+	}
+	return f.Cache.InvalidateTags(ctx, tags...)
+}
+
+// sessionUser returns the id of the signed in user, or "" if there is none
+func (f *Fenix) sessionUser(r *http.Request) string {
+	if f.Session == nil || !f.Session.Exists(r.Context(), sessionUserKey) {
+		return ""
+	}
+	return fmt.Sprint(f.Session.Get(r.Context(), sessionUserKey))
+}
+
+// sessionHasMessage reports whether the session holds a flash message or error waiting to be shown
+func (f *Fenix) sessionHasMessage(r *http.Request) bool {
+	if f.Session == nil {
+		return false
+	}
+	return f.Session.Exists(r.Context(), "flash") || f.Session.Exists(r.Context(), "error")
+}
+
+// personal reports whether resp belongs to the visitor it was made for: the request changed their
+// session, which the session middleware saves with a cookie on the outer writer, or the body holds
+// their CSRF token
+func (f *Fenix) personal(r *http.Request, resp *cachedResponse) bool {
+	if f.Session != nil && f.Session.Status(r.Context()) != scs.Unmodified {
+		return true
+	}
+
+	token := nosurf.Token(r)
+	return token != "" && bytes.Contains(resp.Body, []byte(token))
+}
+
+func responseCacheKey(r *http.Request, o *responseCacheOptions, user string) string {
+	var b strings.Builder
+
+	if o.key != nil {
+		b.WriteString(o.key(r))
+	} else {
+		b.WriteString(r.URL.Path)
+		if !o.ignoreQuery {
+			// Encode sorts by key, so the order of the parameters does not matter
+			b.WriteString("?" + r.URL.Query().Encode())
+		}
+		for _, h := range o.varyHeaders {
+			b.WriteString("\n" + http.CanonicalHeaderKey(h) + ": " + r.Header.Get(h))
+		}
+	}
+
+	if o.perUser {
+		b.WriteString("\nuser: " + user)
+	}
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return responseCacheTag + ":" + hex.EncodeToString(sum[:])
+}
+
+// cacheable reports whether a response may be shared with other visitors
+func cacheable(resp *cachedResponse) bool {
+	if resp.Status != http.StatusOK || len(resp.Header.Values("Set-Cookie")) > 0 {
+		return false
+	}
+
+	cc := strings.ToLower(resp.Header.Get("Cache-Control"))
+	return !strings.Contains(cc, "no-store") && !strings.Contains(cc, "private")
+}
+
+// serveCachedResponse writes resp, or 304 Not Modified if the client already has it
+func serveCachedResponse(w http.ResponseWriter, r *http.Request, resp *cachedResponse) {
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("ETag", resp.ETag)
+	w.Header().Set("Last-Modified", resp.LastModified.Format(http.TimeFormat))
+
+	if notModified(r, resp) {
+		w.Header().Del("Content-Length")
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(resp.Status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(resp.Body)
+	}
+}
+
+// notModified applies If-None-Match, or If-Modified-Since when there is no If-None-Match
+func notModified(r *http.Request, resp *cachedResponse) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == resp.ETag || tag == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		t, err := http.ParseTime(ims)
+		return err == nil && !resp.LastModified.After(t)
+	}
+	return false
+}
+
+func writeResponse(w http.ResponseWriter, resp *cachedResponse) {
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.Status)
+	_, _ = w.Write(resp.Body)
+}
+
+// responseRecorder buffers a response so it can be cached before it is sent
+type responseRecorder struct {
+	header      http.Header
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+}
+
+func (rec *responseRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.status = status
+	rec.wroteHeader = true
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.body.Write(b)
+}
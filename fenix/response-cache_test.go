@@ -0,0 +1,175 @@
+package fenix
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/justinas/nosurf"
+	"github.com/wtran29/fenix/fenix/cache"
+)
+
+func TestFenix_CacheResponses(t *testing.T) {
+	f := &Fenix{Cache: &cache.MemoryCache{}, Session: testFenix.Session, Logger: testFenix.Logger}
+
+	calls := 0
+	mux := chi.NewRouter()
+	mux.Use(f.Session.LoadAndSave)
+	mux.With(f.CacheResponses(time.Minute)).Get("/page", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("rendered"))
+	})
+	mux.With(f.CacheResponses(time.Minute)).Get("/missing", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.NotFound(w, r)
+	})
+	mux.With(f.CacheResponses(time.Minute)).Post("/page", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	})
+
+	get := func(method, target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := get("GET", "/page?a=1&b=2", nil)
+	if first.Header().Get("X-Cache") != "MISS" || first.Body.String() != "rendered" {
+		t.Fatalf("expected a rendered miss, got %q %q", first.Header().Get("X-Cache"), first.Body.String())
+	}
+
+	// the order of the query parameters does not matter
+	second := get("GET", "/page?b=2&a=1", nil)
+	if second.Header().Get("X-Cache") != "HIT" || second.Body.String() != "rendered" || calls != 1 {
+		t.Fatalf("expected a hit without rendering, got %q after %d calls", second.Header().Get("X-Cache"), calls)
+	}
+	if second.Header().Get("Content-Type") != "text/plain" {
+		t.Error("expected the cached headers to be served")
+	}
+
+	etag := first.Header().Get("ETag")
+	if rr := get("GET", "/page?a=1&b=2", http.Header{"If-None-Match": {etag}}); rr.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching ETag, got %d", rr.Code)
+	}
+	lastModified := first.Header().Get("Last-Modified")
+	if rr := get("GET", "/page?a=1&b=2", http.Header{"If-Modified-Since": {lastModified}}); rr.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for If-Modified-Since, got %d", rr.Code)
+	}
+
+	if rr := get("GET", "/page?a=2", nil); rr.Header().Get("X-Cache") != "MISS" {
+		t.Error("expected a different query to miss")
+	}
+
+	calls = 0
+	get("POST", "/page", nil)
+	get("GET", "/page?a=1&b=2", http.Header{"Authorization": {"Bearer token"}})
+	get("GET", "/missing", nil)
+	get("GET", "/missing", nil)
+	if calls != 4 {
+		t.Errorf("expected POST, authenticated and 404 requests to bypass the cache, %d of 4 did", calls)
+	}
+
+	if err := f.ForgetResponses(context.Background(), "/page"); err != nil {
+		t.Fatal(err)
+	}
+	if rr := get("GET", "/page?a=1&b=2", nil); rr.Header().Get("X-Cache") != "MISS" {
+		t.Error("expected a miss after ForgetResponses")
+	}
+}
+
+func TestFenix_CacheResponses_VaryByUser(t *testing.T) {
+	f := &Fenix{Cache: &cache.MemoryCache{}, Session: testFenix.Session, Logger: testFenix.Logger}
+
+	mux := chi.NewRouter()
+	mux.Use(f.Session.LoadAndSave)
+	mux.Get("/login/{id}", func(w http.ResponseWriter, r *http.Request) {
+		f.Session.Put(r.Context(), sessionUserKey, chi.URLParam(r, "id"))
+	})
+	mux.With(f.CacheResponses(time.Minute, VaryByUser())).Get("/me", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("user " + f.sessionUser(r)))
+	})
+
+	session := func(id string) *http.Cookie {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", "/login/"+id, nil))
+		return rr.Result().Cookies()[0]
+	}
+
+	for _, id := range []string{"1", "2", "1"} {
+		req := httptest.NewRequest("GET", "/me", nil)
+		req.AddCookie(session(id))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Body.String() != "user "+id {
+			t.Errorf("expected the page of user %s, got %q", id, rr.Body.String())
+		}
+	}
+}
+
+func TestFenix_CacheResponses_Personal(t *testing.T) {
+	f := &Fenix{Cache: &cache.MemoryCache{}, Session: testFenix.Session, Logger: testFenix.Logger}
+
+	calls := 0
+	mux := chi.NewRouter()
+	mux.Use(f.Session.LoadAndSave)
+	mux.Get("/flash", func(w http.ResponseWriter, r *http.Request) {
+		f.Session.Put(r.Context(), "flash", "saved")
+	})
+	mux.With(f.CacheResponses(time.Minute, VaryByUser())).Get("/page", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte("page " + f.Session.PopString(r.Context(), "flash") + r.Header.Get("Authorization")))
+	})
+	mux.With(f.CacheResponses(time.Minute)).Get("/form", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte(`<input name="csrf_token" value="` + nosurf.Token(r) + `">`))
+	})
+	handler := nosurf.New(mux)
+
+	get := func(target string, prepare func(r *http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		if prepare != nil {
+			prepare(req)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// token authenticated requests are not cached under the anonymous key, even with VaryByUser
+	get("/page", func(r *http.Request) { r.Header.Set("Authorization", "Bearer alice") })
+	if rr := get("/page", nil); rr.Body.String() != "page " {
+		t.Errorf("expected the anonymous page, got %q", rr.Body.String())
+	}
+
+	// a visitor's flash message is shown to them and not cached for anyone else
+	_ = f.ForgetResponses(context.Background())
+	cookie := get("/flash", nil).Result().Cookies()
+	rr := get("/page", func(r *http.Request) {
+		for _, c := range cookie {
+			r.AddCookie(c)
+		}
+	})
+	if rr.Body.String() != "page saved" {
+		t.Errorf("expected the flash message, got %q", rr.Body.String())
+	}
+	if rr := get("/page", nil); rr.Body.String() != "page " {
+		t.Errorf("expected another visitor not to see the flash message, got %q", rr.Body.String())
+	}
+
+	// pages with the visitor's CSRF token are rendered every time
+	calls = 0
+	get("/form", nil)
+	get("/form", nil)
+	if calls != 2 {
+		t.Errorf("expected pages with a CSRF token to bypass the cache, %d of 2 rendered", calls)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"path"
 	"path/filepath"
+
+	"github.com/wtran29/fenix/fenix/cmd/filesystems"
 )
 
 func (f *Fenix) ReadJSON(w http.ResponseWriter, r *http.Request, data interface{}) error {
@@ -83,6 +85,20 @@ func (f *Fenix) DownloadFile(w http.ResponseWriter, r *http.Request, pathToFile,
 	return nil
 }
 
+// DownloadFromDisk streams key from a file system to the client as an attachment, without a temp file
+func (f *Fenix) DownloadFromDisk(w http.ResponseWriter, r *http.Request, fs filesystems.FS, key, fileName string) error {
+	rc, err := fs.Open(key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, err = io.Copy(w, rc)
+	return err
+}
+
 // Status 400 - Bad Request: The server cannot process the request due to a client error,
 // such as invalid syntax or missing parameters.
 func (f *Fenix) ErrorBadRequest(w http.ResponseWriter, r *http.Request) {
@@ -117,6 +133,11 @@ func (f *Fenix) ErrorMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
 	f.ErrorStatus(w, http.StatusMethodNotAllowed)
 }
 
+// Status 429 - Too Many Requests: The client has sent too many requests in a given amount of time.
+func (f *Fenix) ErrorTooManyRequests(w http.ResponseWriter, r *http.Request) {
+	f.ErrorStatus(w, http.StatusTooManyRequests)
+}
+
 // Status 503 - Service Unavailable: The server is currently unavailable, often due to maintenance or overload.
 func (f *Fenix) ErrorServiceUnavailable(w http.ResponseWriter, r *http.Request) {
 	f.ErrorStatus(w, http.StatusServiceUnavailable)
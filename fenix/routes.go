@@ -10,18 +10,43 @@ import (
 func (f *Fenix) routes() http.Handler {
 	mux := chi.NewRouter()
 	mux.Use(middleware.RequestID)
-	mux.Use(middleware.RealIP)
+	mux.Use(f.RealIP)
 	if f.Debug {
-		mux.Use(middleware.Logger)
+		mux.Use(f.RequestLogger)
 	}
 	mux.Use(middleware.Recoverer)
 	mux.Use(f.SessionLoad)
+	if f.Sessions != nil {
+		mux.Use(f.Sessions.Track)
+	}
 	mux.Use(f.NoSurf)
 	mux.Use(f.CheckForMaintenanceMode)
 
 	return mux
 }
 
+// Handler returns the handler served by ListenAndServe: Fenix's own endpoints, which do not need
+// a session, in front of the application's routes. Routes is left free of routes, so that
+// applications can still add middleware to it.
+func (f *Fenix) Handler() http.Handler {
+	mux := chi.NewRouter()
+	if f.Metrics != nil {
+		mux.Use(f.Metrics.Middleware)
+		mux.Method(http.MethodGet, f.config.Metrics.Path, f.Metrics.Handler())
+	}
+
+	// liveness and readiness probes for load balancers and orchestrators
+	mux.Get("/healthz", f.healthz)
+	mux.Get("/readyz", f.readyz)
+
+	// signed links from TemporaryURL, for disks that cannot presign their own
+	mux.Get(temporaryFilesPath+"/{disk}", f.serveTemporaryFile)
+
+	mux.Mount("/", f.Routes)
+
+	return mux
+}
+
 // Routes are fenix specific routes that are mounted in the routes file
 func Routes() http.Handler {
 	r := chi.NewRouter()
@@ -1,38 +1,172 @@
 package fenix
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
+// ShutdownHook is a function registered with OnShutdown, run while the application shuts down
+type ShutdownHook func(ctx context.Context) error
+
 func (f *Fenix) ListenAndServe() error {
 	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%s", os.Getenv("PORT")),
+		Addr:         fmt.Sprintf(":%s", f.config.Port),
 		ErrorLog:     f.ErrorLog,
-		Handler:      f.Routes,
+		Handler:      f.Handler(),
 		IdleTimeout:  30 * time.Second,
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 600 * time.Second,
 	}
 
-	if f.DB.Pool != nil {
-		defer f.DB.Pool.Close()
+	// the listeners are created here, not in the goroutines, so that Shutdown can safely close them
+	rpcListener, err := f.listenRPC()
+	if err != nil {
+		f.ErrorLog.Println(err)
+	}
+
+	f.serverMu.Lock()
+	if f.ShuttingDown() {
+		f.serverMu.Unlock()
+		if rpcListener != nil {
+			_ = rpcListener.Close()
+		}
+		return nil
+	}
+	f.server = srv
+	f.rpcListener = rpcListener
+	f.serverMu.Unlock()
+
+	if rpcListener != nil {
+		go f.serveRPC(rpcListener)
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		f.InfoLog.Printf("Listening on port %s", f.config.Port)
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	select {
+	case err := <-serverErr:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	case s := <-quit:
+		f.InfoLog.Println("Received signal", s.String())
 	}
 
-	if redisPool != nil {
-		defer redisPool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), f.shutdownTimeout())
+	defer cancel()
 
+	return f.Shutdown(ctx)
+}
+
+// OnShutdown registers a hook to run during Shutdown, after the http server, scheduler, job queue and
+// mail queue have stopped, but before the database, redis and badger connections are closed
+func (f *Fenix) OnShutdown(hook ShutdownHook) {
+	f.shutdownHooks = append(f.shutdownHooks, hook)
+}
+
+// ShuttingDown reports whether Shutdown has been called
+func (f *Fenix) ShuttingDown() bool {
+	return f.shuttingDown.Load()
+}
+
+// Shutdown gracefully stops the application: in-flight requests are drained, the scheduler and job
+// queue are stopped, queued mail is sent, shutdown hooks run, and finally the connection pools are closed. It is safe to call
+// more than once; only the first call does any work.
+func (f *Fenix) Shutdown(ctx context.Context) error {
+	if !f.shuttingDown.CompareAndSwap(false, true) {
+		return nil
 	}
 
-	if badgerConn != nil {
-		defer badgerConn.Close()
+	var errs []error
+
+	// give load balancers polling /readyz time to take us out of rotation
+	if f.config.ShutdownDelay > 0 {
+		select {
+		case <-time.After(f.config.ShutdownDelay):
+		case <-ctx.Done():
+		}
 	}
 
-	go f.listenRPC()
+	f.serverMu.Lock()
+	srv, rpcListener := f.server, f.rpcListener
+	f.serverMu.Unlock()
 
-	f.InfoLog.Printf("Listening on port %s", os.Getenv("PORT"))
-	return srv.ListenAndServe()
+	// stop accepting requests and wait for the in-flight ones to finish
+	if srv != nil {
+		f.InfoLog.Println("Shutting down http server")
+		if err := srv.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("http server: %w", err))
+		}
+	}
 
+	// stop the scheduler and wait for running jobs
+	if f.Scheduler != nil {
+		select {
+		case <-f.Scheduler.Stop().Done():
+		case <-ctx.Done():
+			errs = append(errs, fmt.Errorf("scheduler: %w", ctx.Err()))
+		}
+	}
+
+	// drain the mail channel before the job queue stops, as its messages are passed on to the queue
+	if f.mailDone != nil {
+		close(f.mailStop)
+		select {
+		case <-f.mailDone:
+		case <-ctx.Done():
+			errs = append(errs, fmt.Errorf("mail queue: %w", ctx.Err()))
+		}
+	}
+
+	// stop taking jobs and wait for running ones
+	if f.Queue != nil {
+		if err := f.Queue.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("job queue: %w", err))
+		}
+	}
+
+	// user registered hooks, run in the order they were registered
+	for _, hook := range f.shutdownHooks {
+		if err := hook(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if rpcListener != nil {
+		_ = rpcListener.Close()
+	}
+
+	// close the connections Fenix opened, newest first
+	if err := f.closeOwned(); err != nil {
+		errs = append(errs, err)
+	}
+
+	f.InfoLog.Println("Shutdown complete")
+
+	if f.logCloser != nil {
+		_ = f.logCloser.Close()
+	}
+
+	return errors.Join(errs...)
+}
+
+// shutdownTimeout is how long Shutdown may take before in-flight work is abandoned; defaults to 30 seconds
+func (f *Fenix) shutdownTimeout() time.Duration {
+	if f.config.ShutdownTimeout > 0 {
+		return f.config.ShutdownTimeout
+	}
+	return 30 * time.Second
 }
@@ -0,0 +1,111 @@
+package fenix
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// freePort returns a port nothing is listening on
+func freePort(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	return port
+}
+
+func TestShutdown_DrainsRequests(t *testing.T) {
+	cfg := testConfig()
+	cfg.Port = freePort(t)
+
+	f, err := NewWithOptions(WithRootPath(t.TempDir()), WithConfig(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	f.Routes.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	})
+
+	var hookRan atomic.Bool
+	f.OnShutdown(func(ctx context.Context) error {
+		hookRan.Store(true)
+		return nil
+	})
+
+	served := make(chan error, 1)
+	go func() {
+		served <- f.ListenAndServe()
+	}()
+
+	url := "http://127.0.0.1:" + cfg.Port + "/slow"
+	type response struct {
+		body string
+		err  error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		// the server may still be starting
+		var resp *http.Response
+		var err error
+		for i := 0; i < 50; i++ {
+			resp, err = http.Get(url)
+			if err == nil {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- response{body: string(body), err: err}
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the slow request never reached the server")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := f.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown failed: %s", err)
+	}
+
+	res := <-responses
+	if res.err != nil || res.body != "done" {
+		t.Errorf("expected the in-flight request to complete, got %q, %v", res.body, res.err)
+	}
+	if !hookRan.Load() {
+		t.Error("shutdown hook did not run")
+	}
+	if !f.ShuttingDown() {
+		t.Error("expected ShuttingDown to report true")
+	}
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("ListenAndServe returned %s", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("ListenAndServe did not return after Shutdown")
+	}
+}
@@ -0,0 +1,133 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// badgerIndexPrefix starts with BadgerPrefix's "fenix-session" but not with BadgerPrefix, so the
+// index is kept by BadgerCache.Empty and left out of BadgerStore.All
+const badgerIndexPrefix = "fenix-session-index:"
+
+// BadgerIndex keeps each record under a key of its user, and the user of each session under its own
+// key, both expiring with the session:
+//
+//	fenix-session-index:user:<userID>:<id>  the record
+//	fenix-session-index:id:<id>             the user of the session
+type BadgerIndex struct {
+	DB *badger.DB
+}
+
+func badgerUserKey(userID, id string) []byte {
+	return []byte(badgerIndexPrefix + "user:" + userID + ":" + id)
+}
+
+func badgerIDKey(id string) []byte {
+	return []byte(badgerIndexPrefix + "id:" + id)
+}
+
+func (bi *BadgerIndex) Save(ctx context.Context, rec Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ttl := time.Until(rec.Expiry)
+	if ttl <= 0 {
+		return bi.Remove(ctx, rec.UserID, rec.ID)
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	return bi.DB.Update(func(txn *badger.Txn) error {
+		if err := txn.SetEntry(badger.NewEntry(badgerUserKey(rec.UserID, rec.ID), data).WithTTL(ttl)); err != nil {
+			return err
+		}
+		return txn.SetEntry(badger.NewEntry(badgerIDKey(rec.ID), []byte(rec.UserID)).WithTTL(ttl))
+	})
+}
+
+func (bi *BadgerIndex) User(ctx context.Context, userID string) ([]Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var recs []Record
+	prefix := badgerUserKey(userID, "")
+
+	err := bi.DB.View(func(txn *badger.Txn) error {
+		iter := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer iter.Close()
+
+		for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+			var rec Record
+			err := iter.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &rec)
+			})
+			if err != nil {
+				return err
+			}
+			// a user id that starts with userID followed by ":" shares the prefix
+			if rec.UserID == userID {
+				recs = append(recs, rec)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recs, nil
+}
+
+func (bi *BadgerIndex) Find(ctx context.Context, id string) (*Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var rec Record
+	err := bi.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(badgerIDKey(id))
+		if err != nil {
+			return err
+		}
+		userID, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		item, err = txn.Get(badgerUserKey(string(userID), id))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &rec)
+		})
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (bi *BadgerIndex) Remove(ctx context.Context, userID, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return bi.DB.Update(func(txn *badger.Txn) error {
+		if err := txn.Delete(badgerUserKey(userID, id)); err != nil {
+			return err
+		}
+		return txn.Delete(badgerIDKey(id))
+	})
+}
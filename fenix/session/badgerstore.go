@@ -0,0 +1,87 @@
+package session
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// BadgerPrefix is put in front of the token of every session kept by a BadgerStore, so the sessions can
+// share a database with a cache
+const BadgerPrefix = "fenix-session:"
+
+// BadgerStore keeps sessions in a Badger database, which expires them with the TTL of each entry
+type BadgerStore struct {
+	DB *badger.DB
+}
+
+// NewBadgerStore returns a store that keeps sessions in db. The store does not close db.
+func NewBadgerStore(db *badger.DB) *BadgerStore {
+	return &BadgerStore{DB: db}
+}
+
+// Find returns the data of the session with token, and false if it does not exist or has expired
+func (bs *BadgerStore) Find(token string) ([]byte, bool, error) {
+	var b []byte
+
+	err := bs.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(BadgerPrefix + token))
+		if err != nil {
+			return err
+		}
+		b, err = item.ValueCopy(nil)
+		return err
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+// Commit stores the data of the session with token until expiry
+func (bs *BadgerStore) Commit(token string, b []byte, expiry time.Time) error {
+	ttl := time.Until(expiry)
+	if ttl <= 0 {
+		return bs.Delete(token)
+	}
+
+	return bs.DB.Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(badger.NewEntry([]byte(BadgerPrefix+token), b).WithTTL(ttl))
+	})
+}
+
+// Delete removes the session with token
+func (bs *BadgerStore) Delete(token string) error {
+	return bs.DB.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(BadgerPrefix + token))
+	})
+}
+
+// All returns the data of every session that has not expired, by token
+func (bs *BadgerStore) All() (map[string][]byte, error) {
+	sessions := make(map[string][]byte)
+	prefix := []byte(BadgerPrefix)
+
+	err := bs.DB.View(func(txn *badger.Txn) error {
+		iter := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer iter.Close()
+
+		for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+			item := iter.Item()
+			b, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			sessions[string(item.Key()[len(prefix):])] = b
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
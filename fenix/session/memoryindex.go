@@ -0,0 +1,61 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryIndex keeps the index in this process, like the memory store of cookie sessions
+type MemoryIndex struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func (mi *MemoryIndex) Save(ctx context.Context, rec Record) error {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	if mi.records == nil {
+		mi.records = make(map[string]Record)
+	}
+	mi.records[rec.ID] = rec
+	return nil
+}
+
+func (mi *MemoryIndex) User(ctx context.Context, userID string) ([]Record, error) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	now := time.Now()
+	var recs []Record
+	for id, rec := range mi.records {
+		if !rec.Expiry.After(now) {
+			delete(mi.records, id)
+			continue
+		}
+		if rec.UserID == userID {
+			recs = append(recs, rec)
+		}
+	}
+	return recs, nil
+}
+
+func (mi *MemoryIndex) Find(ctx context.Context, id string) (*Record, error) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	rec, ok := mi.records[id]
+	if !ok || !rec.Expiry.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return &rec, nil
+}
+
+func (mi *MemoryIndex) Remove(ctx context.Context, userID, id string) error {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	delete(mi.records, id)
+	return nil
+}
@@ -0,0 +1,122 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisIndex keeps the records of each user in a hash by session id, and the user of each session
+// under its own key:
+//
+//	user:<userID>  hash of records, which expires with the last of its sessions
+//	id:<id>        the user of the session
+type RedisIndex struct {
+	Conn *redis.Pool
+	// Prefix is put in front of every key, like RedisCache.Prefix
+	Prefix string
+}
+
+func (ri *RedisIndex) key(kind, name string) string {
+	return ri.Prefix + ":fenix-session-index:" + kind + ":" + name
+}
+
+func (ri *RedisIndex) do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	conn, err := ri.Conn.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return redis.DoContext(conn, ctx, cmd, args...)
+}
+
+// saveScript stores record ARGV[2] under the id ARGV[1] in the hash KEYS[1] and the user ARGV[3] under
+// KEYS[2], both until ARGV[4], without cutting short the life of the hash
+var saveScript = redis.NewScript(2, `
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('SET', KEYS[2], ARGV[3])
+redis.call('PEXPIREAT', KEYS[2], ARGV[4])
+local ttl = redis.call('PTTL', KEYS[1])
+local want = tonumber(ARGV[4]) - tonumber(ARGV[5])
+if ttl < want then
+	redis.call('PEXPIRE', KEYS[1], want)
+end
+return 1
+`)
+
+func (ri *RedisIndex) Save(ctx context.Context, rec Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	conn, err := ri.Conn.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = saveScript.DoContext(ctx, conn, ri.key("user", rec.UserID), ri.key("id", rec.ID),
+		rec.ID, data, rec.UserID, rec.Expiry.UnixMilli(), time.Now().UnixMilli())
+	return err
+}
+
+func (ri *RedisIndex) User(ctx context.Context, userID string) ([]Record, error) {
+	values, err := redis.StringMap(ri.do(ctx, "HGETALL", ri.key("user", userID)))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	recs := make([]Record, 0, len(values))
+	for id, data := range values {
+		var rec Record
+		if err := json.Unmarshal([]byte(data), &rec); err != nil {
+			return nil, err
+		}
+		if !rec.Expiry.After(now) {
+			if _, err := ri.do(ctx, "HDEL", ri.key("user", userID), id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+func (ri *RedisIndex) Find(ctx context.Context, id string) (*Record, error) {
+	userID, err := redis.String(ri.do(ctx, "GET", ri.key("id", id)))
+	if errors.Is(err, redis.ErrNil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := redis.Bytes(ri.do(ctx, "HGET", ri.key("user", userID), id))
+	if errors.Is(err, redis.ErrNil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (ri *RedisIndex) Remove(ctx context.Context, userID, id string) error {
+	if _, err := ri.do(ctx, "HDEL", ri.key("user", userID), id); err != nil {
+		return err
+	}
+	_, err := ri.do(ctx, "DEL", ri.key("id", id))
+	return err
+}
@@ -0,0 +1,289 @@
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// ErrNotFound is returned when there is no signed-in session with an id
+var ErrNotFound = errors.New("session: not found")
+
+// keys Track keeps in each signed-in session
+const (
+	idKey       = "fenix.session_id"
+	seenKey     = "fenix.last_seen"
+	signedInKey = "fenix.signed_in"
+)
+
+// Info describes a signed-in session. ID is derived from the session token, which it does not give
+// away, so it is safe to show to the user and to post back to Revoke.
+type Info struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+// Record is what an Index keeps about a session: its Info and the token it is stored under
+type Record struct {
+	Info
+	Token string `json:"token"`
+}
+
+// Index keeps the signed-in sessions of each user, next to the store that keeps their data.
+// Records may be dropped once they reach their Expiry.
+type Index interface {
+	// Save adds the record, or replaces the one with the same ID
+	Save(ctx context.Context, rec Record) error
+	// User returns the records of the sessions of the user with userID, in no particular order
+	User(ctx context.Context, userID string) ([]Record, error)
+	// Find returns the record of the session with id, or ErrNotFound
+	Find(ctx context.Context, id string) (*Record, error)
+	// Remove drops the record of the session with id of the user with userID
+	Remove(ctx context.Context, userID, id string) error
+}
+
+// Registry lists and revokes the sessions of a user. Its Track middleware records the sessions in
+// Index as they are used, so it must run inside Manager.LoadAndSave.
+type Registry struct {
+	Manager *scs.SessionManager
+	Index   Index
+	// UserKey is the session key holding the id of the signed-in user; userID by default
+	UserKey string
+	// Touch is how often the last seen time of a session is updated; a minute by default
+	Touch  time.Duration
+	Logger *slog.Logger
+	// OnRevoke, if set, is called with the id of the user whose sessions were revoked, to drop what
+	// could sign the revoked browsers back in, such as remember-me tokens
+	OnRevoke func(ctx context.Context, userID string) error
+}
+
+// ID returns the id that a session with token is known by
+func ID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:16])
+}
+
+func (r *Registry) userKey() string {
+	if r.UserKey == "" {
+		return "userID"
+	}
+	return r.UserKey
+}
+
+func (r *Registry) touch() time.Duration {
+	if r.Touch <= 0 {
+		return time.Minute
+	}
+	return r.Touch
+}
+
+func (r *Registry) logger() *slog.Logger {
+	if r.Logger == nil {
+		return slog.Default()
+	}
+	return r.Logger
+}
+
+// user returns the id of the signed-in user of the session in ctx, or "" if nobody is signed in
+func (r *Registry) user(ctx context.Context) string {
+	if !r.Manager.Exists(ctx, r.userKey()) {
+		return ""
+	}
+	return fmt.Sprint(r.Manager.Get(ctx, r.userKey()))
+}
+
+// Current returns the id of the session in ctx, once Track has recorded it, so that it can be told
+// apart in a list from Sessions or spared by RevokeAll
+func (r *Registry) Current(ctx context.Context) string {
+	return r.Manager.GetString(ctx, idKey)
+}
+
+// Track records the signed-in session of each request, and drops the record when the user signs
+// out or the token is renewed
+func (r *Registry) Track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		before, beforeUser := r.Current(ctx), r.user(ctx)
+
+		next.ServeHTTP(w, req)
+
+		r.track(req, before, beforeUser)
+	})
+}
+
+func (r *Registry) track(req *http.Request, before, beforeUser string) {
+	ctx := req.Context()
+
+	var id, user string
+	// a destroyed session must not be touched, or it would be committed again
+	destroyed := r.Manager.Status(ctx) == scs.Destroyed
+	if !destroyed {
+		user = r.user(ctx)
+		// a new session has no token until it is committed, so it is recorded on its next request
+		if token := r.Manager.Token(ctx); user != "" && token != "" {
+			id = ID(token)
+		}
+	}
+
+	if before != "" && (before != id || user != beforeUser) {
+		if err := r.Index.Remove(ctx, beforeUser, before); err != nil {
+			r.logger().WarnContext(ctx, "removing session from index failed", "id", before, "error", err)
+		}
+	}
+
+	if id == "" {
+		if before != "" && !destroyed {
+			r.Manager.Remove(ctx, idKey)
+			r.Manager.Remove(ctx, seenKey)
+			r.Manager.Remove(ctx, signedInKey)
+		}
+		return
+	}
+
+	now := time.Now()
+	// times are kept as unix nanoseconds, which the gob codec of scs takes without registering time.Time
+	if id == before && user == beforeUser && now.Sub(time.Unix(0, r.Manager.GetInt64(ctx, seenKey))) < r.touch() {
+		return
+	}
+
+	// a renewed token keeps the time the user signed in
+	signedIn := time.Unix(0, r.Manager.GetInt64(ctx, signedInKey))
+	if user != beforeUser || !r.Manager.Exists(ctx, signedInKey) {
+		signedIn = now
+		r.Manager.Put(ctx, signedInKey, signedIn.UnixNano())
+	}
+	r.Manager.Put(ctx, idKey, id)
+	r.Manager.Put(ctx, seenKey, now.UnixNano())
+
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		ip = req.RemoteAddr
+	}
+
+	rec := Record{
+		Info: Info{
+			ID:        id,
+			UserID:    user,
+			IP:        ip,
+			UserAgent: req.UserAgent(),
+			CreatedAt: signedIn.UTC(),
+			LastSeen:  now.UTC(),
+			Expiry:    r.Manager.Deadline(ctx).UTC(),
+		},
+		Token: r.Manager.Token(ctx),
+	}
+	if err := r.Index.Save(ctx, rec); err != nil {
+		r.logger().WarnContext(ctx, "saving session to index failed", "id", id, "error", err)
+	}
+}
+
+// Sessions returns the signed-in sessions of the user with userID, most recently seen first.
+// Records of sessions that have ended are dropped from the index on the way.
+func (r *Registry) Sessions(ctx context.Context, userID string) ([]Info, error) {
+	recs, err := r.Index.User(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sessions := make([]Info, 0, len(recs))
+	for _, rec := range recs {
+		alive := rec.Expiry.After(now)
+		if alive {
+			if alive, err = r.exists(ctx, rec.Token); err != nil {
+				return nil, err
+			}
+		}
+
+		if !alive {
+			if err := r.Index.Remove(ctx, userID, rec.ID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		sessions = append(sessions, rec.Info)
+	}
+
+	sort.Slice(sessions, func(a, b int) bool { return sessions[a].LastSeen.After(sessions[b].LastSeen) })
+	return sessions, nil
+}
+
+// Revoke ends the session with id, signing its user out, and calls OnRevoke. A request that is being
+// served for the session when it is revoked can still save it once more.
+func (r *Registry) Revoke(ctx context.Context, id string) error {
+	rec, err := r.Index.Find(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := r.revoke(ctx, rec); err != nil {
+		return err
+	}
+	return r.onRevoke(ctx, rec.UserID)
+}
+
+// RevokeAll ends every session of the user with userID but those in except, such as Current after
+// the user changed their password, and calls OnRevoke
+func (r *Registry) RevokeAll(ctx context.Context, userID string, except ...string) error {
+	recs, err := r.Index.User(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+next:
+	for i := range recs {
+		for _, id := range except {
+			if recs[i].ID == id {
+				continue next
+			}
+		}
+		if err := r.revoke(ctx, &recs[i]); err != nil {
+			return err
+		}
+	}
+	return r.onRevoke(ctx, userID)
+}
+
+func (r *Registry) onRevoke(ctx context.Context, userID string) error {
+	if r.OnRevoke == nil {
+		return nil
+	}
+	return r.OnRevoke(ctx, userID)
+}
+
+func (r *Registry) revoke(ctx context.Context, rec *Record) error {
+	var err error
+	if s, ok := r.Manager.Store.(scs.CtxStore); ok {
+		err = s.DeleteCtx(ctx, rec.Token)
+	} else {
+		err = r.Manager.Store.Delete(rec.Token)
+	}
+	if err != nil {
+		return err
+	}
+	return r.Index.Remove(ctx, rec.UserID, rec.ID)
+}
+
+func (r *Registry) exists(ctx context.Context, token string) (bool, error) {
+	var found bool
+	var err error
+	if s, ok := r.Manager.Store.(scs.CtxStore); ok {
+		_, found, err = s.FindCtx(ctx, token)
+	} else {
+		_, found, err = r.Manager.Store.Find(token)
+	}
+	return found, err
+}
@@ -0,0 +1,208 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/gomodule/redigo/redis"
+)
+
+// testIndexes returns an empty index of every kind that can run without a database server
+func testIndexes(t *testing.T) map[string]Index {
+	t.Helper()
+
+	s := miniredis.RunT(t)
+	pool := &redis.Pool{Dial: func() (redis.Conn, error) { return redis.Dial("tcp", s.Addr()) }}
+	t.Cleanup(func() { _ = pool.Close() })
+
+	bdb, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = bdb.Close() })
+
+	indexes := map[string]Index{
+		"memory": &MemoryIndex{},
+		"redis":  &RedisIndex{Conn: pool, Prefix: "test-fenix"},
+		"badger": &BadgerIndex{DB: bdb},
+	}
+
+	// SQLite needs cgo
+	sdb, err := OpenSQLite(filepath.Join(t.TempDir(), "sessions.db"))
+	if errors.Is(err, ErrNoSQLite) {
+		return indexes
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sdb.Close() })
+
+	indexes["sqlite"] = &SQLIndex{DB: sdb, Dialect: "sqlite"}
+	return indexes
+}
+
+func TestIndexes(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	for name, index := range testIndexes(t) {
+		t.Run(name, func(t *testing.T) {
+			rec := Record{
+				Info:  Info{ID: "a", UserID: "1", IP: "10.0.0.1", UserAgent: "test", CreatedAt: now, LastSeen: now, Expiry: now.Add(time.Hour)},
+				Token: "token-a",
+			}
+			if err := index.Save(ctx, rec); err != nil {
+				t.Fatal(err)
+			}
+			rec.LastSeen = now.Add(time.Minute)
+			if err := index.Save(ctx, rec); err != nil {
+				t.Fatal(err)
+			}
+
+			other := rec
+			other.ID, other.UserID, other.Token = "b", "12", "token-b"
+			if err := index.Save(ctx, other); err != nil {
+				t.Fatal(err)
+			}
+
+			recs, err := index.User(ctx, "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(recs) != 1 || recs[0].Token != "token-a" || !recs[0].LastSeen.Equal(rec.LastSeen) {
+				t.Fatalf("expected the updated record of user 1, got %+v", recs)
+			}
+
+			found, err := index.Find(ctx, "b")
+			if err != nil || found.UserID != "12" || found.IP != "10.0.0.1" {
+				t.Fatalf("expected the record of session b, got %+v, %v", found, err)
+			}
+
+			if err := index.Remove(ctx, "1", "a"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := index.Find(ctx, "a"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("expected ErrNotFound after Remove, got %v", err)
+			}
+			if recs, _ := index.User(ctx, "1"); len(recs) != 0 {
+				t.Errorf("expected no records after Remove, got %+v", recs)
+			}
+		})
+	}
+}
+
+// client keeps the session cookie of a browser
+type client struct {
+	agent  string
+	cookie *http.Cookie
+}
+
+func (c *client) do(t *testing.T, h http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("User-Agent", c.agent)
+	if c.cookie != nil {
+		req.AddCookie(c.cookie)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	for _, ck := range rr.Result().Cookies() {
+		if ck.Name == "session" {
+			c.cookie = ck
+		}
+	}
+	return rr
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+
+	sm := scs.New()
+	var forgotten []string
+	r := &Registry{Manager: sm, Index: &MemoryIndex{}, OnRevoke: func(ctx context.Context, userID string) error {
+		forgotten = append(forgotten, userID)
+		return nil
+	}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, req *http.Request) {
+		_ = sm.RenewToken(req.Context())
+		sm.Put(req.Context(), "userID", 7)
+	})
+	mux.HandleFunc("/logout", func(w http.ResponseWriter, req *http.Request) {
+		_ = sm.Destroy(req.Context())
+	})
+	mux.HandleFunc("/current", func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(r.Current(req.Context())))
+	})
+	h := sm.LoadAndSave(r.Track(mux))
+
+	phone, laptop, tablet := &client{agent: "phone"}, &client{agent: "laptop"}, &client{agent: "tablet"}
+	for _, c := range []*client{phone, laptop, tablet} {
+		c.do(t, h, "/login")
+	}
+
+	sessions, err := r.Sessions(ctx, "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 3 {
+		t.Fatalf("expected 3 sessions, got %+v", sessions)
+	}
+	for _, s := range sessions {
+		if s.IP != "192.0.2.1" || s.UserAgent == "" || s.LastSeen.IsZero() || s.CreatedAt.IsZero() {
+			t.Errorf("expected the metadata of the session, got %+v", s)
+		}
+	}
+
+	// signing out drops the session from the index
+	tablet.do(t, h, "/logout")
+	if sessions, _ = r.Sessions(ctx, "7"); len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions after signing out, got %+v", sessions)
+	}
+
+	current := phone.do(t, h, "/current").Body.String()
+	if current == "" {
+		t.Fatal("expected the phone to know its session id")
+	}
+
+	if err := r.RevokeAll(ctx, "7", current); err != nil {
+		t.Fatal(err)
+	}
+	if laptop.do(t, h, "/current").Body.String() != "" {
+		t.Error("expected the laptop to be signed out")
+	}
+
+	sessions, _ = r.Sessions(ctx, "7")
+	if len(sessions) != 1 || sessions[0].ID != current || sessions[0].UserAgent != "phone" {
+		t.Fatalf("expected only the phone's session, got %+v", sessions)
+	}
+
+	if err := r.Revoke(ctx, current); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Revoke(ctx, current); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound revoking twice, got %v", err)
+	}
+	if phone.do(t, h, "/current").Body.String() != "" {
+		t.Error("expected the phone to be signed out")
+	}
+	if sessions, _ = r.Sessions(ctx, "7"); len(sessions) != 0 {
+		t.Errorf("expected no sessions, got %+v", sessions)
+	}
+
+	// remember-me tokens could sign the revoked browsers back in
+	if len(forgotten) != 2 || forgotten[0] != "7" || forgotten[1] != "7" {
+		t.Errorf("expected OnRevoke for user 7 after RevokeAll and Revoke, got %v", forgotten)
+	}
+}
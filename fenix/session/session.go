@@ -2,6 +2,7 @@ package session
 
 import (
 	"database/sql"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	"github.com/alexedwards/scs/postgresstore"
 	"github.com/alexedwards/scs/redisstore"
 	"github.com/alexedwards/scs/v2"
+	"github.com/dgraph-io/badger/v3"
 	"github.com/gomodule/redigo/redis"
 )
 
@@ -23,6 +25,9 @@ type Session struct {
 	CookieSecure   string
 	DBPool         *sql.DB
 	RedisPool      *redis.Pool
+	BadgerConn     *badger.DB
+	// Logger is passed to stores that log, such as the SQLite store
+	Logger *slog.Logger
 }
 
 func (f *Session) InitSession() *scs.SessionManager {
@@ -61,6 +66,12 @@ func (f *Session) InitSession() *scs.SessionManager {
 		session.Store = mysqlstore.New(f.DBPool)
 	case "postgres", "postgresql":
 		session.Store = postgresstore.New(f.DBPool)
+	case "badger":
+		session.Store = NewBadgerStore(f.BadgerConn)
+	case "sqlite", "sqlite3":
+		store := NewSQLiteStore(f.DBPool)
+		store.Logger = f.Logger
+		session.Store = store
 	default:
 		// cookie
 	}
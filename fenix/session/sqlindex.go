@@ -0,0 +1,120 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLIndex keeps the index in a table of the sessions database, created by "fenix make session" for
+// Postgres and MySQL and by OpenSQLite for SQLite. MySQL connections need parseTime=true.
+type SQLIndex struct {
+	DB *sql.DB
+	// Dialect is postgres, mysql or sqlite; postgresql, mariadb and sqlite3 are accepted too
+	Dialect string
+	// Table defaults to session_index
+	Table string
+}
+
+const indexColumns = "id, user_id, token, ip, user_agent, created_at, last_seen, expiry"
+
+func (si *SQLIndex) table() string {
+	if si.Table == "" {
+		return "session_index"
+	}
+	return si.Table
+}
+
+// rebind turns the ? placeholders of query into $1, $2... for Postgres
+func (si *SQLIndex) rebind(query string) string {
+	if si.Dialect != "postgres" && si.Dialect != "postgresql" {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (si *SQLIndex) Save(ctx context.Context, rec Record) error {
+	query := "INSERT INTO " + si.table() + " (" + indexColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	switch si.Dialect {
+	case "mysql", "mariadb":
+		query += " ON DUPLICATE KEY UPDATE user_id = VALUES(user_id), token = VALUES(token), ip = VALUES(ip)," +
+			" user_agent = VALUES(user_agent), created_at = VALUES(created_at), last_seen = VALUES(last_seen), expiry = VALUES(expiry)"
+	default:
+		query += " ON CONFLICT (id) DO UPDATE SET user_id = excluded.user_id, token = excluded.token, ip = excluded.ip," +
+			" user_agent = excluded.user_agent, created_at = excluded.created_at, last_seen = excluded.last_seen, expiry = excluded.expiry"
+	}
+
+	_, err := si.DB.ExecContext(ctx, si.rebind(query), rec.ID, rec.UserID, rec.Token, rec.IP, rec.UserAgent,
+		rec.CreatedAt.UTC(), rec.LastSeen.UTC(), rec.Expiry.UTC())
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRecord(row scanner) (*Record, error) {
+	var rec Record
+	err := row.Scan(&rec.ID, &rec.UserID, &rec.Token, &rec.IP, &rec.UserAgent, &rec.CreatedAt, &rec.LastSeen, &rec.Expiry)
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (si *SQLIndex) User(ctx context.Context, userID string) ([]Record, error) {
+	now := time.Now().UTC()
+
+	query := si.rebind("DELETE FROM " + si.table() + " WHERE user_id = ? AND expiry <= ?")
+	if _, err := si.DB.ExecContext(ctx, query, userID, now); err != nil {
+		return nil, err
+	}
+
+	query = si.rebind("SELECT " + indexColumns + " FROM " + si.table() + " WHERE user_id = ?")
+	rows, err := si.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recs []Record
+	for rows.Next() {
+		rec, err := scanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, *rec)
+	}
+	return recs, rows.Err()
+}
+
+func (si *SQLIndex) Find(ctx context.Context, id string) (*Record, error) {
+	query := si.rebind("SELECT " + indexColumns + " FROM " + si.table() + " WHERE id = ? AND expiry > ?")
+
+	rec, err := scanRecord(si.DB.QueryRowContext(ctx, query, id, time.Now().UTC()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return rec, err
+}
+
+func (si *SQLIndex) Remove(ctx context.Context, userID, id string) error {
+	query := si.rebind("DELETE FROM " + si.table() + " WHERE id = ?")
+
+	_, err := si.DB.ExecContext(ctx, query, id)
+	return err
+}
@@ -0,0 +1,8 @@
+//go:build cgo
+
+package session
+
+import _ "github.com/mattn/go-sqlite3"
+
+// sqliteDriver is the database/sql driver OpenSQLite uses
+const sqliteDriver = "sqlite3"
@@ -0,0 +1,6 @@
+//go:build !cgo
+
+package session
+
+// sqliteDriver is empty without cgo, so OpenSQLite returns ErrNoSQLite
+const sqliteDriver = ""
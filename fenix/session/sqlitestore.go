@@ -0,0 +1,162 @@
+package session
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// ErrNoSQLite is returned by OpenSQLite in binaries built without cgo, which the SQLite driver needs
+var ErrNoSQLite = errors.New("session: SQLite sessions need a binary built with cgo (CGO_ENABLED=1)")
+
+// SQLiteStore keeps sessions in the sessions table of a SQLite database, opened with OpenSQLite
+type SQLiteStore struct {
+	DB *sql.DB
+	// Logger is told when removing expired sessions fails; nil means slog.Default()
+	Logger      *slog.Logger
+	stopCleanup chan bool
+}
+
+// OpenSQLite opens the SQLite database at path, creating the file, the sessions table and the table
+// of SQLIndex if they do not exist
+func OpenSQLite(path string) (*sql.DB, error) {
+	if sqliteDriver == "" {
+		return nil, ErrNoSQLite
+	}
+
+	db, err := sql.Open(sqliteDriver, "file:"+path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS sessions (
+		token TEXT PRIMARY KEY,
+		data BLOB NOT NULL,
+		expiry INTEGER NOT NULL
+	)`)
+	if err == nil {
+		_, err = db.Exec("CREATE INDEX IF NOT EXISTS sessions_expiry_idx ON sessions (expiry)")
+	}
+	if err == nil {
+		_, err = db.Exec(`CREATE TABLE IF NOT EXISTS session_index (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		token TEXT NOT NULL,
+		ip TEXT NOT NULL,
+		user_agent TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		last_seen TIMESTAMP NOT NULL,
+		expiry TIMESTAMP NOT NULL
+	)`)
+	}
+	if err == nil {
+		_, err = db.Exec("CREATE INDEX IF NOT EXISTS session_index_user_id_idx ON session_index (user_id)")
+	}
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// NewSQLiteStore returns a store that keeps sessions in db, and removes expired ones every 5 minutes
+func NewSQLiteStore(db *sql.DB) *SQLiteStore {
+	return NewSQLiteStoreWithCleanupInterval(db, 5*time.Minute)
+}
+
+// NewSQLiteStoreWithCleanupInterval returns a store that keeps sessions in db, and removes expired ones
+// every interval; an interval of 0 never removes them
+func NewSQLiteStoreWithCleanupInterval(db *sql.DB, interval time.Duration) *SQLiteStore {
+	s := &SQLiteStore{DB: db}
+
+	if interval > 0 {
+		s.stopCleanup = make(chan bool)
+		go s.startCleanup(interval)
+	}
+	return s
+}
+
+// Find returns the data of the session with token, and false if it does not exist or has expired
+func (s *SQLiteStore) Find(token string) ([]byte, bool, error) {
+	var b []byte
+
+	err := s.DB.QueryRow("SELECT data FROM sessions WHERE token = ? AND expiry > ?", token, time.Now().UnixNano()).Scan(&b)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+// Commit stores the data of the session with token until expiry
+func (s *SQLiteStore) Commit(token string, b []byte, expiry time.Time) error {
+	_, err := s.DB.Exec(`INSERT INTO sessions (token, data, expiry) VALUES (?, ?, ?)
+		ON CONFLICT (token) DO UPDATE SET data = excluded.data, expiry = excluded.expiry`,
+		token, b, expiry.UnixNano())
+	return err
+}
+
+// Delete removes the session with token
+func (s *SQLiteStore) Delete(token string) error {
+	_, err := s.DB.Exec("DELETE FROM sessions WHERE token = ?", token)
+	return err
+}
+
+// All returns the data of every session that has not expired, by token
+func (s *SQLiteStore) All() (map[string][]byte, error) {
+	rows, err := s.DB.Query("SELECT token, data FROM sessions WHERE expiry > ?", time.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make(map[string][]byte)
+	for rows.Next() {
+		var token string
+		var data []byte
+		if err := rows.Scan(&token, &data); err != nil {
+			return nil, err
+		}
+		sessions[token] = data
+	}
+	return sessions, rows.Err()
+}
+
+// StopCleanup stops removing expired sessions, so a store that is not needed any more can be
+// garbage collected
+func (s *SQLiteStore) StopCleanup() {
+	if s.stopCleanup != nil {
+		s.stopCleanup <- true
+	}
+}
+
+func (s *SQLiteStore) startCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.deleteExpired(); err != nil {
+				s.logger().Error("failed to remove expired sessions", "store", "sqlite", "error", err)
+			}
+		case <-s.stopCleanup:
+			return
+		}
+	}
+}
+
+func (s *SQLiteStore) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
+
+func (s *SQLiteStore) deleteExpired() error {
+	_, err := s.DB.Exec("DELETE FROM sessions WHERE expiry <= ?", time.Now().UnixNano())
+	return err
+}
@@ -0,0 +1,126 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/dgraph-io/badger/v3"
+)
+
+type iterableStore interface {
+	scs.Store
+	scs.IterableStore
+}
+
+func testStore(t *testing.T, store iterableStore) {
+	t.Helper()
+
+	if _, found, err := store.Find("missing"); err != nil || found {
+		t.Fatalf("expected a missing session not to be found, got %v, %v", found, err)
+	}
+
+	if err := store.Commit("token", []byte("data"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Commit("token", []byte("updated"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Commit("expired", []byte("old"), time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	b, found, err := store.Find("token")
+	if err != nil || !found || !bytes.Equal(b, []byte("updated")) {
+		t.Fatalf("expected the updated session, got %q, %v, %v", b, found, err)
+	}
+	if _, found, _ := store.Find("expired"); found {
+		t.Error("expected an expired session not to be found")
+	}
+
+	all, err := store.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || !bytes.Equal(all["token"], []byte("updated")) {
+		t.Errorf("expected only the live session from All, got %q", all)
+	}
+
+	if err := store.Delete("token"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := store.Find("token"); found {
+		t.Error("expected a deleted session not to be found")
+	}
+}
+
+func TestBadgerStore(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	testStore(t, NewBadgerStore(db))
+}
+
+func TestSQLiteStore(t *testing.T) {
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "sessions.db"))
+	if errors.Is(err, ErrNoSQLite) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	store := NewSQLiteStoreWithCleanupInterval(db, 0)
+	testStore(t, store)
+
+	if err := store.Commit("expired", []byte("old"), time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.deleteExpired(); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("expected expired sessions to be removed, %d left", n)
+	}
+}
+
+func TestInitSession_Stores(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sess := &Session{SessionType: "badger", BadgerConn: db}
+	if _, ok := sess.InitSession().Store.(*BadgerStore); !ok {
+		t.Error("expected a badger session store")
+	}
+
+	sqlDB, err := OpenSQLite(filepath.Join(t.TempDir(), "sessions.db"))
+	if errors.Is(err, ErrNoSQLite) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	sess = &Session{SessionType: "sqlite", DBPool: sqlDB}
+	store, ok := sess.InitSession().Store.(*SQLiteStore)
+	if !ok {
+		t.Fatal("expected a sqlite session store")
+	}
+	store.StopCleanup()
+}
@@ -0,0 +1,43 @@
+package fenix
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/wtran29/fenix/fenix/cmd/filesystems"
+	"github.com/wtran29/fenix/fenix/cmd/filesystems/memfilesystem"
+)
+
+var testFenix Fenix
+var testDisk memfilesystem.Memory
+
+func TestMain(m *testing.M) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	infoLog, errorLog := legacyLoggers(logger)
+
+	testSession := scs.New()
+	testSession.Lifetime = 24 * time.Hour
+	testSession.Cookie.SameSite = http.SameSiteLaxMode
+
+	testFenix = Fenix{
+		AppName:       "fenix-test",
+		Logger:        logger,
+		InfoLog:       infoLog,
+		ErrorLog:      errorLog,
+		RootPath:      "./testdata",
+		Session:       testSession,
+		EncryptionKey: "abcdefghijklmnopqrstuvwxyz123456",
+		FileSystems: map[string]filesystems.FS{
+			"memory": &testDisk,
+		},
+		defaultDisk: "memory",
+	}
+	testFenix.Routes = testFenix.routes().(*chi.Mux)
+
+	os.Exit(m.Run())
+}
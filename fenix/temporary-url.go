@@ -0,0 +1,84 @@
+package fenix
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/wtran29/fenix/fenix/cmd/filesystems"
+	"github.com/wtran29/fenix/fenix/urlsigner"
+)
+
+// temporaryFilesPath is where Fenix serves signed links for disks that cannot presign their own
+const temporaryFilesPath = "/fenix/files"
+
+// TemporaryURL returns a link to key on the named disk that stops working after ttl, so clients can
+// download it without going through a handler. Disks that support presigning (S3, MinIO) produce
+// their own links; for every other disk the link points at a Fenix route and is signed with the
+// application's encryption key. Only the path and query are signed, so the link keeps working when
+// the application is reached through another host or port than APP_URL, e.g. behind a proxy.
+func (f *Fenix) TemporaryURL(disk, key string, ttl time.Duration) (string, error) {
+	fsys := f.Disk(disk)
+	if fsys == nil {
+		return "", fmt.Errorf("disk %s is not configured", disk)
+	}
+
+	if presigner, ok := fsys.(filesystems.TemporaryURLer); ok {
+		return presigner.TemporaryURL(key, ttl)
+	}
+
+	if disk == "" {
+		disk = f.defaultDisk
+	}
+
+	expires := time.Now().Add(ttl).Unix()
+	link := fmt.Sprintf("%s/%s?key=%s&expires=%d",
+		temporaryFilesPath, url.PathEscape(disk), url.QueryEscape(key), expires)
+
+	signer := urlsigner.Signer{
+		Secret: []byte(f.EncryptionKey),
+	}
+	return f.Server.URL + signer.GenerateTokenFromString(link), nil
+}
+
+// serveTemporaryFile streams the file behind a link made by TemporaryURL, after checking
+// the signature and expiry
+func (f *Fenix) serveTemporaryFile(w http.ResponseWriter, r *http.Request) {
+	signer := urlsigner.Signer{
+		Secret: []byte(f.EncryptionKey),
+	}
+
+	if !signer.VerifyToken(r.URL.RequestURI()) {
+		f.ErrorForbidden(w, r)
+		return
+	}
+
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		f.ErrorForbidden(w, r)
+		return
+	}
+
+	fsys := f.Disk(chi.URLParam(r, "disk"))
+	if fsys == nil {
+		f.ErrorNotFound(w, r)
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	err = f.DownloadFromDisk(w, r, fsys, key, path.Base(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			f.ErrorNotFound(w, r)
+			return
+		}
+		f.ErrorLog.Println(err)
+		f.ErrorIntServerErr(w, r)
+	}
+}
@@ -0,0 +1,94 @@
+package fenix
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestFenix_TemporaryURL(t *testing.T) {
+	err := testDisk.PutStream("reports/2023.txt", strings.NewReader("annual report"), -1, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// mount the package routes the same way an application does
+	testFenix.Routes.Mount("/fenix", Routes())
+
+	ts := httptest.NewServer(testFenix.Handler())
+	defer ts.Close()
+	testFenix.Server.URL = ts.URL
+
+	link, err := testFenix.TemporaryURL("", "reports/2023.txt", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || string(body) != "annual report" {
+		t.Errorf("expected file contents with 200, got %d %q", resp.StatusCode, string(body))
+	}
+
+	// behind a proxy the public URL is not the address the request reaches
+	testFenix.Server.URL = "https://files.example.com"
+	proxied, err := testFenix.TemporaryURL("", "reports/2023.txt", time.Minute)
+	testFenix.Server.URL = ts.URL
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = http.Get(strings.Replace(proxied, "https://files.example.com", ts.URL, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 for a link signed for another host, got %d", resp.StatusCode)
+	}
+
+	// tampering with the key breaks the signature
+	resp, err = http.Get(strings.Replace(link, "2023", "2024", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for tampered link, got %d", resp.StatusCode)
+	}
+
+	// expired links are refused
+	expired, err := testFenix.TemporaryURL("memory", "reports/2023.txt", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = http.Get(expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for expired link, got %d", resp.StatusCode)
+	}
+
+	_, err = testFenix.TemporaryURL("nope", "reports/2023.txt", time.Minute)
+	if err == nil {
+		t.Error("expected error for unknown disk")
+	}
+
+	testFenix.Routes = testFenix.routes().(*chi.Mux)
+}
@@ -7,31 +7,7 @@ type initPaths struct {
 	folderNames []string
 }
 
-type cookieConfig struct {
-	name     string
-	lifetime string
-	persist  string
-	secure   string
-	domain   string
-}
-
-type databaseConfig struct {
-	dsn      string
-	database string
-}
-
 type Database struct {
 	DataType string
 	Pool     *sql.DB
 }
-
-type redisConfig struct {
-	host     string
-	password string
-	prefix   string
-}
-
-type uploadConfig struct {
-	allowedMimeTypes []string
-	maxUploadSize    int64
-}
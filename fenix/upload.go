@@ -2,83 +2,73 @@ package fenix
 
 import (
 	"errors"
-	"fmt"
-	"io"
+	"mime/multipart"
 	"net/http"
-	"os"
 	"path"
 
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/wtran29/fenix/fenix/cmd/filesystems"
+	"github.com/wtran29/fenix/fenix/cmd/filesystems/localfilesystem"
 )
 
+// UploadFile streams the file in the given form field straight to fs, into the destination folder.
+// Nothing is written to ./tmp on the way.
 func (f *Fenix) UploadFile(r *http.Request, destination, field string, fs filesystems.FS) error {
-	filename, err := f.getFileToUpload(r, field)
+	file, header, mimeType, err := f.getFileToUpload(r, field)
 	if err != nil {
 		f.ErrorLog.Println(err)
 		return err
 	}
+	defer file.Close()
 
-	if fs != nil {
-		err = fs.Put(filename, destination)
-		if err != nil {
-			f.ErrorLog.Println(err)
-			return err
-		}
-	} else {
-		err = os.Rename(filename, fmt.Sprintf("%s/%s", destination, path.Base(filename)))
-		if err != nil {
-			f.ErrorLog.Println(err)
-			return err
-		}
+	// no file system given: use the default disk, or local disk relative to the application root
+	if fs == nil {
+		fs = f.Disk("")
+	}
+	if fs == nil {
+		fs = &localfilesystem.Local{Root: f.RootPath}
 	}
 
-	defer func() {
-		_ = os.Remove(filename)
-	}()
+	key := path.Join(destination, path.Base(header.Filename))
+	err = fs.PutStream(key, file, header.Size, mimeType)
+	if err != nil {
+		f.ErrorLog.Println(err)
+		return err
+	}
 
 	return nil
 }
 
-func (f *Fenix) getFileToUpload(r *http.Request, fieldname string) (string, error) {
-	err := r.ParseMultipartForm(f.config.uploads.maxUploadSize)
+// getFileToUpload opens the uploaded file and checks its type; the file is rewound and ready to read
+func (f *Fenix) getFileToUpload(r *http.Request, fieldname string) (multipart.File, *multipart.FileHeader, string, error) {
+	err := r.ParseMultipartForm(f.config.Uploads.MaxUploadSize)
 	if err != nil {
-		fmt.Println("could not parse multipart form:", err)
+		f.Logger.WarnContext(r.Context(), "could not parse multipart form", "error", err)
 	}
 	file, header, err := r.FormFile(fieldname)
 	if err != nil {
-		return "", err
+		return nil, nil, "", err
 	}
 
-	defer file.Close()
 	mimeType, err := mimetype.DetectReader(file)
 	if err != nil {
-		return "", err
+		file.Close()
+		return nil, nil, "", err
 	}
 
 	// go back to start of file
 	_, err = file.Seek(0, 0)
 	if err != nil {
-		return "", err
-	}
-
-	if !inSlice(f.config.uploads.allowedMimeTypes, mimeType.String()) {
-		return "", errors.New("invalid file type uploaded")
-	}
-
-	dst, err := os.Create(fmt.Sprintf("./tmp/%s", header.Filename))
-	if err != nil {
-		return "", err
+		file.Close()
+		return nil, nil, "", err
 	}
 
-	defer dst.Close()
-
-	_, err = io.Copy(dst, file)
-	if err != nil {
-		return "", err
+	if !inSlice(f.config.Uploads.AllowedFileTypes, mimeType.String()) {
+		file.Close()
+		return nil, nil, "", errors.New("invalid file type uploaded")
 	}
 
-	return fmt.Sprintf("./tmp/%s", header.Filename), nil
+	return file, header, mimeType.String(), nil
 }
 
 func inSlice(slice []string, val string) bool {
@@ -41,3 +41,13 @@ func (s *Signer) Expired(token string, minTilExpire int) bool {
 
 	return time.Since(ts.Timestamp) > time.Duration(minTilExpire)*time.Minute
 }
+
+// DataFromToken returns the data token was generated from by GenerateTokenFromString, and false if
+// the token was not signed with Secret
+func (s *Signer) DataFromToken(token string) (string, bool) {
+	signer := goalone.New(s.Secret, goalone.Timestamp)
+	if _, err := signer.Unsign([]byte(token)); err != nil {
+		return "", false
+	}
+	return strings.TrimSuffix(string(signer.Parse([]byte(token)).Payload), "&hash="), true
+}
@@ -0,0 +1,18 @@
+package urlsigner
+
+import "testing"
+
+func TestSigner_DataFromToken(t *testing.T) {
+	s := Signer{Secret: []byte("a secret key of some length 1234")}
+
+	token := s.GenerateTokenFromString("device:7:abc")
+	data, ok := s.DataFromToken(token)
+	if !ok || data != "device:7:abc" {
+		t.Errorf("expected the signed data back, got %q, %v", data, ok)
+	}
+
+	other := Signer{Secret: []byte("another secret key of the length")}
+	if _, ok := other.DataFromToken(token); ok {
+		t.Error("expected a token signed with another secret to be rejected")
+	}
+}